@@ -23,22 +23,20 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/create": {
-            "post": {
-                "description": "Creates a new wallet with zero balance and returns its UUID",
-                "consumes": [
-                    "application/json"
-                ],
+        "/admin/clients": {
+            "get": {
+                "description": "Returns all API clients newest-first, including revoked ones. Keys are not returned, only their prefixes.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "Wallets"
+                    "Admin"
                 ],
-                "summary": "Create a new wallet",
+                "summary": "List API clients",
                 "responses": {
                     "200": {
-                        "description": "Wallet created successfully",
+                        "description": "Clients retrieved successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -48,9 +46,9 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "object",
-                                            "additionalProperties": {
-                                                "type": "string"
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/model.ApiClient"
                                             }
                                         }
                                     }
@@ -58,42 +56,70 @@ const docTemplate = `{
                             ]
                         }
                     },
+                    "401": {
+                        "description": "Invalid admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/model.Response"
                         }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
                     }
-                }
-            }
-        },
-        "/wallet": {
+                },
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ]
+            },
             "post": {
-                "description": "Deposits or withdraws funds from a wallet",
+                "description": "Issues a new API key for a client. The key is returned only in this response, the service stores just its hash.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "Wallets"
+                    "Admin"
                 ],
-                "summary": "Update wallet balance",
+                "summary": "Create an API client",
                 "parameters": [
                     {
-                        "description": "Update balance request",
+                        "description": "Create client request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.UpdateBalance"
+                            "$ref": "#/definitions/model.CreateApiClient"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Balance updated successfully",
+                    "201": {
+                        "description": "Client created successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -103,10 +129,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "object",
-                                            "additionalProperties": {
-                                                "type": "string"
-                                            }
+                                            "$ref": "#/definitions/model.CreatedApiClient"
                                         }
                                     }
                                 }
@@ -119,28 +142,147 @@ const docTemplate = `{
                             "$ref": "#/definitions/model.Response"
                         }
                     },
+                    "401": {
+                        "description": "Invalid admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/model.Response"
                         }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ]
             }
         },
-        "/wallets/{WALLET_UUID}": {
-            "get": {
-                "description": "Returns the current balance of a wallet by its UUID",
+        "/admin/clients/{CLIENT_ID}": {
+            "delete": {
+                "description": "Revokes the key of a client: requests with it get 401. Wallets of the client stay owned by it.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Revoke an API client",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "CLIENT_ID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Client revoked successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.ApiClient"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid client ID",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Client not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/admin/wallets/{WALLET_UUID}/owner": {
+            "put": {
+                "description": "Makes the client the owner of the wallet: only it can use the wallet afterwards.\nWallets created before API keys existed have no owner and cannot be used by any client until one is assigned here.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "Wallets"
+                    "Admin"
                 ],
-                "summary": "Get wallet balance",
+                "summary": "Assign the owner of a wallet",
                 "parameters": [
                     {
                         "type": "string",
@@ -148,11 +290,20 @@ const docTemplate = `{
                         "name": "WALLET_UUID",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "New owner",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.AssignWalletOwner"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Balance retrieved successfully",
+                        "description": "Owner assigned successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -162,8 +313,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "object",
-                                            "additionalProperties": true
+                                            "$ref": "#/definitions/model.WalletOwner"
                                         }
                                     }
                                 }
@@ -171,47 +321,2784 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Wallet UUID not provided",
+                        "description": "Invalid wallet UUID or request body",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API is disabled",
                         "schema": {
                             "$ref": "#/definitions/model.Response"
                         }
                     },
                     "404": {
-                        "description": "Wallet not found",
+                        "description": "Wallet not found, or client not found or revoked",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
                         "schema": {
                             "$ref": "#/definitions/model.Response"
                         }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ]
             }
-        }
-    },
-    "definitions": {
-        "model.Response": {
-            "type": "object",
-            "properties": {
-                "data": {},
-                "error": {
-                    "type": "string",
-                    "example": "Error message"
+        },
+        "/create": {
+            "post": {
+                "description": "Creates a new wallet with zero balance owned by the calling API client and returns it.\nThe body is optional: external owner id, display name, metadata (a JSON object) and labels are stored with the wallet.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Wallets"
+                ],
+                "summary": "Create a new wallet",
+                "parameters": [
+                    {
+                        "description": "Wallet details",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.CreateWallet"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Wallet created successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.Wallet"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
                 },
-                "success": {
-                    "type": "boolean",
-                    "example": true
-                }
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
-        "model.UpdateBalance": {
-            "type": "object",
+        "/ledger": {
+            "get": {
+                "description": "Returns balances of system accounts and the total of all wallets per currency. In every currency the sum of all accounts is always 0 and every wallet balance must match the sum of its postings.\nRequires the admin API key.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Ledger"
+                ],
+                "summary": "Reconcile the double-entry journal",
+                "responses": {
+                    "200": {
+                        "description": "Ledger summary retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.LedgerSummary"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin API key",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/quotes": {
+            "get": {
+                "description": "Returns how much of the target currency a conversion of amount (minor units of from) gives at the current rate minus the spread.\nThe quote id locks the rate for a short time: POST /wallets/{WALLET_UUID}/convert with it converts exactly these amounts, once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Conversions"
+                ],
+                "summary": "Quote a currency conversion",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Currency to convert from, e.g. USD",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency to convert to, e.g. EUR",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Amount in minor units of from",
+                        "name": "amount",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Quote created successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.Quote"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters, unsupported currency, no rate for the pair or amount too small",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Rate provider or database unavailable, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/transactions/{TRANSACTION_ID}/reverse": {
+            "post": {
+                "description": "Creates a compensating ledger entry for a DEPOSIT, WITHDRAW or transfer, fully or partially.\nA transfer is reversed by the id of either of its parts. The total reversed amount never exceeds the original amount.\nAll wallets of the transaction, for a transfer both of them, must belong to the calling client.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Transactions"
+                ],
+                "summary": "Reverse a transaction",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Transaction ID",
+                        "name": "TRANSACTION_ID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reversal request, without amount the whole remaining amount is reversed",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.ReverseTransaction"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Transaction reversed successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.ReversalResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request, transaction type cannot be reversed or amount exceeds remaining",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "A wallet of the transaction belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Transaction not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Transaction is already fully reversed, insufficient funds, balance overflow, wallet frozen or closed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/transfer": {
+            "post": {
+                "description": "Atomically withdraws amount from one wallet and deposits it to another in the same currency in a single database transaction.\nThe source wallet must belong to the calling client, the destination wallet may belong to anyone.\nThe credit leg with the destination balances is returned only when the destination wallet belongs to the calling client too.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Transfers"
+                ],
+                "summary": "Transfer funds between wallets",
+                "parameters": [
+                    {
+                        "description": "Transfer request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Transfer"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Transfer completed successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.TransferResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or unsupported currency",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Source wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Insufficient funds, the credit would overflow the receiving balance, or a wallet is frozen or closed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallet": {
+            "post": {
+                "description": "Deposits or withdraws funds from a wallet balance in the given currency. The amount is in minor units of the currency.\nWith Idempotency-Key a retry of an already applied request returns the original result instead of applying it again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Wallets"
+                ],
+                "summary": "Update wallet balance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Idempotency key, max 255 characters",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Update balance request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UpdateBalance"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Balance updated successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object",
+                                            "additionalProperties": {
+                                                "type": "string"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, operation type or unsupported currency",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Insufficient funds, the deposit would overflow the balance, or the wallet is frozen or closed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency key reused with a different request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallet/batch": {
+            "post": {
+                "description": "Applies up to 10000 DEPOSIT/WITHDRAW operations in one request. The whole batch takes a fixed number of database queries regardless of its size,\nso a payout run of tens of thousands of operations is sent as a few requests of up to 10000 items; with idempotency keys a failed request can be resent as is.\nWith atomic=true all operations run in one database transaction: if any fails, none is applied and the response has the status of that failure.\nOtherwise a failed operation does not affect the others and the response contains a result for each of them.\nidempotencyKey of an item works as for a single update: an already applied item is not applied again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Wallets"
+                ],
+                "summary": "Apply a batch of balance operations",
+                "parameters": [
+                    {
+                        "description": "Batch of balance operations",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UpdateBalanceBatch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Batch processed, see per-item results",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.BatchResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, or an invalid operation or unsupported currency in an atomic batch",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "A wallet of the batch belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet of an atomic batch item not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Insufficient funds, balance overflow, frozen or closed wallet for an atomic batch item",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency key of an atomic batch item reused with a different request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}": {
+            "get": {
+                "description": "Returns the wallet by its UUID: status, external owner id, display name, metadata and labels,\nand its balances in every currency it has operations in: total, available and held by active holds.\nWith currency only the balance in that currency is returned, zero if there were no operations in it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Wallets"
+                ],
+                "summary": "Get wallet details and balances",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency code, e.g. USD or BTC",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Wallet retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.WalletBalances"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid wallet UUID or unsupported currency",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "patch": {
+                "description": "Replaces the external owner id, display name, metadata or labels of a wallet. Omitted fields are left unchanged,\nan empty string or empty labels list clears the field, \"metadata\": null removes the metadata. Closed wallets cannot be updated.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Wallets"
+                ],
+                "summary": "Update wallet details",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to update",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UpdateWallet"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Wallet updated successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.Wallet"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid wallet UUID or request body",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Wallet is closed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}/close": {
+            "post": {
+                "description": "Closes an active wallet for good: every operation on it is rejected afterwards.\nBalances in all currencies must be zero. A frozen wallet has to be unfrozen first.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Wallet status"
+                ],
+                "summary": "Close a wallet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Close request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.WalletStatusReason"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Wallet closed",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.WalletStatusChange"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid wallet UUID or request body",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Wallet is not active or its balance is not zero",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}/convert": {
+            "post": {
+                "description": "Atomically debits one currency of the wallet (CONVERT_OUT) and credits another (CONVERT_IN).\nWith quoteId the conversion uses the locked rate and amounts of that quote, which must belong to the caller, be unexpired and unused.\nWithout it fromCurrency, toCurrency and amount are converted at the current rate minus the spread.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Conversions"
+                ],
+                "summary": "Convert between currencies of a wallet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Conversion request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Convert"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Conversion completed successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.ConversionResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, unsupported currency, no rate for the pair or amount too small",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet or quote not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Insufficient funds, balance overflow, wallet frozen or closed, quote expired or already used",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Rate provider or database unavailable, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}/freeze": {
+            "post": {
+                "description": "Rejects withdrawals, transfers from the wallet, holds and conversions until the wallet is unfrozen.\nDeposits and incoming transfers are still accepted unless blockDeposits is set. The change is recorded with actor and reason.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Wallet status"
+                ],
+                "summary": "Freeze a wallet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Freeze request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.FreezeWallet"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Wallet frozen",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.WalletStatusChange"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid wallet UUID or request body",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Wallet is not active",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}/holds": {
+            "post": {
+                "description": "Reserves amount in a currency on a wallet: available balance decreases, total balance does not.\nThe hold expires automatically if it is not captured or voided in time.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Holds"
+                ],
+                "summary": "Place a hold on wallet funds",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create hold request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CreateHold"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Hold created successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.Hold"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or unsupported currency",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Insufficient available funds, wallet frozen or closed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}/holds/{HOLD_ID}/capture": {
+            "post": {
+                "description": "Withdraws the held amount (or a part of it) from the wallet. The rest of a partially captured hold is released.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Holds"
+                ],
+                "summary": "Capture a hold",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hold ID",
+                        "name": "HOLD_ID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Capture request, without amount the whole hold is captured",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.CaptureHold"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Hold captured successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.CaptureResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or amount exceeds hold",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet or hold not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Hold is already captured, voided or expired, wallet frozen or closed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}/holds/{HOLD_ID}/void": {
+            "post": {
+                "description": "Releases the held amount back to the available balance",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Holds"
+                ],
+                "summary": "Void a hold",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hold ID",
+                        "name": "HOLD_ID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Hold voided successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.Hold"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid wallet UUID or hold ID",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet or hold not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Hold is already captured, voided or expired, or wallet closed",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}/status-history": {
+            "get": {
+                "description": "Returns every status change of the wallet, oldest first, with actor and reason.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Wallet status"
+                ],
+                "summary": "Get wallet status history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Status history",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/model.WalletStatusChange"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid wallet UUID",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}/transactions": {
+            "get": {
+                "description": "Returns wallet operations newest-first with cursor-based pagination and filters",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Transactions"
+                ],
+                "summary": "Get wallet transactions history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (1-500, default 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from nextCursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "DEPOSIT",
+                            "WITHDRAW",
+                            "TRANSFER_OUT",
+                            "TRANSFER_IN",
+                            "CAPTURE",
+                            "REVERSAL",
+                            "CONVERT_OUT",
+                            "CONVERT_IN"
+                        ],
+                        "type": "string",
+                        "description": "Operation type filter",
+                        "name": "operationType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency filter, e.g. USD",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum amount, inclusive",
+                        "name": "minAmount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum amount, inclusive",
+                        "name": "maxAmount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of time window, inclusive (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of time window, exclusive (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Transactions retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.TransactionsPage"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/wallets/{WALLET_UUID}/unfreeze": {
+            "post": {
+                "description": "Returns a frozen wallet to ACTIVE. The change is recorded with actor and reason.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "Wallet status"
+                ],
+                "summary": "Unfreeze a wallet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet UUID",
+                        "name": "WALLET_UUID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Unfreeze request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.WalletStatusReason"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Wallet unfrozen",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.WalletStatusChange"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid wallet UUID or request body",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid API key or bearer token",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Wallet belongs to another client or token lacks scope",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Wallet is not frozen",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit of the client or wallet exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Database timeout, retry later",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "default": {
+                        "description": "Any error as RFC 7807 problem details, when Accept prefers application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        }
+    },
+    "definitions": {
+        "model.ApiClient": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "9b2e6f1a-4c3d-4e5f-8a7b-1c2d3e4f5a6b"
+                },
+                "keyPrefix": {
+                    "type": "string",
+                    "example": "wk_Xy7pQ2a"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "billing-service"
+                },
+                "revokedAt": {
+                    "type": "string",
+                    "example": "2025-02-01T12:00:00Z"
+                }
+            }
+        },
+        "model.AssignWalletOwner": {
+            "type": "object",
+            "required": [
+                "clientId"
+            ],
+            "properties": {
+                "clientId": {
+                    "type": "string",
+                    "example": "9b2e6f1a-4c3d-4e5f-8a7b-1c2d3e4f5a6b"
+                }
+            }
+        },
+        "model.Balance": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "string",
+                    "example": "700"
+                },
+                "balance": {
+                    "type": "string",
+                    "example": "1000"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "held": {
+                    "type": "string",
+                    "example": "300"
+                },
+                "scale": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "model.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "INSUFFICIENT_FUNDS"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "Insufficient funds"
+                },
+                "index": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "replayed": {
+                    "description": "повтор по ключу идемпотентности, операция не выполнялась заново",
+                    "type": "boolean",
+                    "example": false
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 409
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "transactionId": {
+                    "type": "string",
+                    "example": "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+                }
+            }
+        },
+        "model.BatchResult": {
+            "type": "object",
+            "properties": {
+                "atomic": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "failed": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BatchItemResult"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "model.CaptureHold": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "250"
+                }
+            }
+        },
+        "model.CaptureResult": {
+            "type": "object",
+            "properties": {
+                "hold": {
+                    "$ref": "#/definitions/model.Hold"
+                },
+                "transaction": {
+                    "$ref": "#/definitions/model.Transaction"
+                }
+            }
+        },
+        "model.ConversionResult": {
+            "type": "object",
+            "properties": {
+                "conversionId": {
+                    "type": "string",
+                    "example": "8e7d6c5b-4a3f-4e2d-9c1b-0a9f8e7d6c5b"
+                },
+                "credit": {
+                    "$ref": "#/definitions/model.Transaction"
+                },
+                "debit": {
+                    "$ref": "#/definitions/model.Transaction"
+                },
+                "midRate": {
+                    "type": "string",
+                    "example": "0.92"
+                },
+                "quoteId": {
+                    "type": "string",
+                    "example": "2c5e8f1a-7b3d-4e9c-a1f2-3b4c5d6e7f80"
+                },
+                "rate": {
+                    "type": "string",
+                    "example": "0.9154"
+                },
+                "spread": {
+                    "type": "string",
+                    "example": "0.005"
+                }
+            }
+        },
+        "model.Convert": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "10000"
+                },
+                "fromCurrency": {
+                    "type": "string",
+                    "maxLength": 10,
+                    "minLength": 3,
+                    "example": "USD"
+                },
+                "quoteId": {
+                    "type": "string",
+                    "example": "2c5e8f1a-7b3d-4e9c-a1f2-3b4c5d6e7f80"
+                },
+                "toCurrency": {
+                    "type": "string",
+                    "maxLength": 10,
+                    "minLength": 3,
+                    "example": "EUR"
+                }
+            }
+        },
+        "model.CreateApiClient": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "billing-service"
+                }
+            }
+        },
+        "model.CreateHold": {
+            "type": "object",
+            "required": [
+                "amount",
+                "currency"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "300"
+                },
+                "currency": {
+                    "type": "string",
+                    "maxLength": 10,
+                    "minLength": 3,
+                    "example": "USD"
+                }
+            }
+        },
+        "model.CreateWallet": {
+            "type": "object",
+            "properties": {
+                "displayName": {
+                    "type": "string",
+                    "maxLength": 200,
+                    "example": "Main wallet"
+                },
+                "externalOwnerId": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "user-42"
+                },
+                "labels": {
+                    "type": "array",
+                    "maxItems": 50,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "eu",
+                        "vip"
+                    ]
+                },
+                "metadata": {
+                    "type": "object"
+                }
+            }
+        },
+        "model.CreatedApiClient": {
+            "type": "object",
+            "properties": {
+                "apiKey": {
+                    "type": "string",
+                    "example": "wk_Xy7pQ2aLm9vN4bR8tK1wE6sD3fG5hJ0zC2xV7nM"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "9b2e6f1a-4c3d-4e5f-8a7b-1c2d3e4f5a6b"
+                },
+                "keyPrefix": {
+                    "type": "string",
+                    "example": "wk_Xy7pQ2a"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "billing-service"
+                },
+                "revokedAt": {
+                    "type": "string",
+                    "example": "2025-02-01T12:00:00Z"
+                }
+            }
+        },
+        "model.FreezeWallet": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "blockDeposits": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "reason": {
+                    "type": "string",
+                    "maxLength": 500,
+                    "example": "AML review #1234"
+                }
+            }
+        },
+        "model.Hold": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "300"
+                },
+                "capturedAmount": {
+                    "type": "string",
+                    "example": "0"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:00Z"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "expiresAt": {
+                    "type": "string",
+                    "example": "2025-01-01T12:15:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "3f1c2b7a-8d4e-4c6f-9a1b-2c3d4e5f6a7b"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "ACTIVE",
+                        "CAPTURED",
+                        "VOIDED",
+                        "EXPIRED"
+                    ],
+                    "example": "ACTIVE"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:00Z"
+                },
+                "walletId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "model.LedgerAccount": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "string",
+                    "example": "-1000"
+                },
+                "code": {
+                    "type": "string",
+                    "example": "SYSTEM_CASH"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                }
+            }
+        },
+        "model.LedgerCurrency": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "total": {
+                    "type": "string",
+                    "example": "0"
+                },
+                "walletsTotal": {
+                    "type": "string",
+                    "example": "1000"
+                }
+            }
+        },
+        "model.LedgerSummary": {
+            "type": "object",
+            "properties": {
+                "balanced": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "currencies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.LedgerCurrency"
+                    }
+                },
+                "mismatchedWallets": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "systemAccounts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.LedgerAccount"
+                    }
+                }
+            }
+        },
+        "model.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "INSUFFICIENT_FUNDS"
+                },
+                "currentBalance": {
+                    "type": "string",
+                    "example": "500"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "Wallet 550e8400-e29b-41d4-a716-446655440000 has 500, requested 1000"
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/v1/wallet"
+                },
+                "requestedAmount": {
+                    "type": "string",
+                    "example": "1000"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 409
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Insufficient funds"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/insufficient-funds"
+                }
+            }
+        },
+        "model.Quote": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:00Z"
+                },
+                "expiresAt": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:30Z"
+                },
+                "fromAmount": {
+                    "type": "string",
+                    "example": "10000"
+                },
+                "fromCurrency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "2c5e8f1a-7b3d-4e9c-a1f2-3b4c5d6e7f80"
+                },
+                "midRate": {
+                    "type": "string",
+                    "example": "0.92"
+                },
+                "rate": {
+                    "type": "string",
+                    "example": "0.9154"
+                },
+                "spread": {
+                    "type": "string",
+                    "example": "0.005"
+                },
+                "toAmount": {
+                    "type": "string",
+                    "example": "9154"
+                },
+                "toCurrency": {
+                    "type": "string",
+                    "example": "EUR"
+                }
+            }
+        },
+        "model.Response": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "WALLET_NOT_FOUND"
+                },
+                "data": {},
+                "error": {
+                    "type": "string",
+                    "example": "Error message"
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "model.ReversalResult": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "400"
+                },
+                "originalTransactionId": {
+                    "type": "string",
+                    "example": "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+                },
+                "remaining": {
+                    "type": "string",
+                    "example": "600"
+                },
+                "reversalId": {
+                    "type": "string",
+                    "example": "5d4c3b2a-1f0e-4d9c-8b7a-6f5e4d3c2b1a"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Transaction"
+                    }
+                }
+            }
+        },
+        "model.ReverseTransaction": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "400"
+                }
+            }
+        },
+        "model.Transaction": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "1000"
+                },
+                "balanceAfter": {
+                    "type": "string",
+                    "example": "1000"
+                },
+                "balanceBefore": {
+                    "type": "string",
+                    "example": "0"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:00Z"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "entryId": {
+                    "description": "запись журнала двойной записи, у обмена - id обмена",
+                    "type": "string",
+                    "example": "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+                },
+                "holdId": {
+                    "description": "холд, по которому списаны средства",
+                    "type": "string",
+                    "example": "3f1c2b7a-8d4e-4c6f-9a1b-2c3d4e5f6a7b"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+                },
+                "operationType": {
+                    "type": "string",
+                    "enum": [
+                        "DEPOSIT",
+                        "WITHDRAW",
+                        "TRANSFER_OUT",
+                        "TRANSFER_IN",
+                        "CAPTURE",
+                        "REVERSAL",
+                        "CONVERT_OUT",
+                        "CONVERT_IN"
+                    ],
+                    "example": "DEPOSIT"
+                },
+                "reversalOf": {
+                    "description": "исходная операция, если это сторно",
+                    "type": "string",
+                    "example": "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+                },
+                "transferId": {
+                    "description": "общий id обеих частей перевода",
+                    "type": "string",
+                    "example": "9b2f4c1e-3d7a-4f8e-a5b6-1c2d3e4f5a6b"
+                },
+                "walletId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "model.TransactionsPage": {
+            "type": "object",
+            "properties": {
+                "nextCursor": {
+                    "type": "string",
+                    "example": "MjAyNS0wMS0wMVQxMjowMDowMFp8N2M5ZTY2Nzk"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Transaction"
+                    }
+                }
+            }
+        },
+        "model.Transfer": {
+            "type": "object",
+            "required": [
+                "amount",
+                "currency",
+                "fromWalletId",
+                "toWalletId"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "1000"
+                },
+                "currency": {
+                    "type": "string",
+                    "maxLength": 10,
+                    "minLength": 3,
+                    "example": "USD"
+                },
+                "fromWalletId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "toWalletId": {
+                    "type": "string",
+                    "example": "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+                }
+            }
+        },
+        "model.TransferResult": {
+            "type": "object",
+            "properties": {
+                "credit": {
+                    "$ref": "#/definitions/model.Transaction"
+                },
+                "debit": {
+                    "$ref": "#/definitions/model.Transaction"
+                },
+                "transferId": {
+                    "type": "string",
+                    "example": "9b2f4c1e-3d7a-4f8e-a5b6-1c2d3e4f5a6b"
+                }
+            }
+        },
+        "model.UpdateBalance": {
+            "type": "object",
             "required": [
                 "amount",
+                "currency",
                 "operationType",
                 "valletId"
             ],
             "properties": {
                 "amount": {
-                    "type": "integer",
-                    "example": 1000
+                    "type": "string",
+                    "example": "1000"
+                },
+                "currency": {
+                    "type": "string",
+                    "maxLength": 10,
+                    "minLength": 3,
+                    "example": "USD"
+                },
+                "idempotencyKey": {
+                    "description": "Необязательный, то же самое что заголовок Idempotency-Key (заголовок в приоритете)",
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "order-42-payout"
                 },
                 "operationType": {
                     "type": "string",
@@ -226,6 +3113,246 @@ const docTemplate = `{
                     "example": "550e8400-e29b-41d4-a716-446655440000"
                 }
             }
+        },
+        "model.UpdateBalanceBatch": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "atomic": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "items": {
+                    "type": "array",
+                    "maxItems": 10000,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/model.UpdateBalance"
+                    }
+                }
+            }
+        },
+        "model.UpdateWallet": {
+            "type": "object",
+            "properties": {
+                "displayName": {
+                    "type": "string",
+                    "maxLength": 200,
+                    "example": "Savings"
+                },
+                "externalOwnerId": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "user-42"
+                },
+                "labels": {
+                    "type": "array",
+                    "maxItems": 50,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "eu"
+                    ]
+                },
+                "metadata": {
+                    "type": "object"
+                }
+            }
+        },
+        "model.Wallet": {
+            "type": "object",
+            "properties": {
+                "depositsBlocked": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "displayName": {
+                    "type": "string",
+                    "example": "Main wallet"
+                },
+                "externalOwnerId": {
+                    "description": "id владельца в системе клиента",
+                    "type": "string",
+                    "example": "user-42"
+                },
+                "labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "eu",
+                        "vip"
+                    ]
+                },
+                "metadata": {
+                    "type": "object"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "ACTIVE",
+                        "FROZEN",
+                        "CLOSED"
+                    ],
+                    "example": "ACTIVE"
+                },
+                "walletId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "model.WalletBalances": {
+            "type": "object",
+            "properties": {
+                "balances": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Balance"
+                    }
+                },
+                "depositsBlocked": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "displayName": {
+                    "type": "string",
+                    "example": "Main wallet"
+                },
+                "externalOwnerId": {
+                    "description": "id владельца в системе клиента",
+                    "type": "string",
+                    "example": "user-42"
+                },
+                "labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "eu",
+                        "vip"
+                    ]
+                },
+                "metadata": {
+                    "type": "object"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "ACTIVE",
+                        "FROZEN",
+                        "CLOSED"
+                    ],
+                    "example": "ACTIVE"
+                },
+                "walletId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "model.WalletOwner": {
+            "type": "object",
+            "properties": {
+                "ownerId": {
+                    "type": "string",
+                    "example": "9b2e6f1a-4c3d-4e5f-8a7b-1c2d3e4f5a6b"
+                },
+                "previousOwnerId": {
+                    "type": "string",
+                    "example": ""
+                },
+                "walletId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "model.WalletStatusChange": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "id клиента API, сменившего статус",
+                    "type": "string",
+                    "example": "9b2e6f1a-4c3d-4e5f-8a7b-1c2d3e4f5a6b"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2025-01-01T12:00:00Z"
+                },
+                "depositsBlocked": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "id": {
+                    "type": "string",
+                    "example": "4b3a2c1d-5e6f-4a7b-8c9d-0e1f2a3b4c5d"
+                },
+                "previousStatus": {
+                    "type": "string",
+                    "enum": [
+                        "ACTIVE",
+                        "FROZEN",
+                        "CLOSED"
+                    ],
+                    "example": "ACTIVE"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "AML review #1234"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "ACTIVE",
+                        "FROZEN",
+                        "CLOSED"
+                    ],
+                    "example": "FROZEN"
+                },
+                "walletId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "model.WalletStatusReason": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "maxLength": 500,
+                    "example": "Review completed"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminKeyAuth": {
+            "description": "Admin API key from the AdminApiKey setting",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "ApiKeyAuth": {
+            "description": "API key of a client, issued by POST /admin/clients",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT as \"Bearer \u003ctoken\u003e\": sub is the API client id, scope lists wallet:read, wallet:deposit, wallet:withdraw, wallet:create, wallet:manage",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Не чаще этого перечитываем JWKS из-за неизвестного kid, чтобы токены с мусорным kid не нагружали IdP
+const jwksMinRefetch = time.Minute
+
+// JWKS (RFC 7517), из него берутся только RSA ключи
+type jwkSet struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// Публичные ключи RS256 по kid из файла или URL. Ключи по URL обновляются раз в refresh
+// и при токене с неизвестным kid: так подхватывается ротация ключей у IdP
+type jwksSource struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func newJWKSSource(ctx context.Context, file, url string, refresh time.Duration) (*jwksSource, error) {
+	s := &jwksSource{url: url, refresh: refresh, client: &http.Client{Timeout: 5 * time.Second}}
+
+	if file != "" {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading JWKS file: %v", err)
+		}
+		if s.keys, err = parseJWKS(raw); err != nil {
+			return nil, fmt.Errorf("error parsing JWKS file %s: %v", file, err)
+		}
+		return s, nil
+	}
+
+	if err := s.fetch(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Ключ по kid, при необходимости JWKS по URL перечитывается
+func (s *jwksSource) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[kid]
+	if s.url != "" {
+		stale := s.refresh > 0 && time.Since(s.fetchedAt) > s.refresh
+		unknown := !ok && time.Since(s.fetchedAt) > jwksMinRefetch
+		if stale || unknown {
+			// при недоступном IdP продолжаем с ключами, загруженными раньше
+			if err := s.fetch(ctx); err != nil && !ok {
+				return nil, err
+			}
+			key, ok = s.keys[kid]
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// Загружает JWKS по URL, вызывается под mu или до того, как source стал доступен
+func (s *jwksSource) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating JWKS request: %v", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching JWKS: status %d", resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("error reading JWKS: %v", err)
+	}
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return fmt.Errorf("error parsing JWKS from %s: %v", s.url, err)
+	}
+
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+// RSA ключи для подписи из JWKS, ключи шифрования и других типов пропускаются
+func parseJWKS(raw []byte) (map[string]*rsa.PublicKey, error) {
+	var set jwkSet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid n: %v", jwk.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid e: %v", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA signing keys")
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Права токена, проверяются на каждом маршруте
+const (
+	ScopeRead     = "wallet:read"     // баланс и история операций
+	ScopeDeposit  = "wallet:deposit"  // пополнение
+	ScopeWithdraw = "wallet:withdraw" // снятие, холды, списание переводом
+	ScopeCreate   = "wallet:create"   // создание кошелька
+	ScopeManage   = "wallet:manage"   // заморозка, разморозка и закрытие кошелька
+)
+
+// Все права: они есть у статических ключей API
+var AllScopes = []string{ScopeRead, ScopeDeposit, ScopeWithdraw, ScopeCreate, ScopeManage}
+
+// Настройки проверки JWT, заполняются из конфига. Хотя бы один источник ключей обязателен
+type Options struct {
+	HMACSecret  string        // секрет для HS256
+	JWKSFile    string        // JWKS с публичными ключами для RS256
+	JWKSURL     string        // или URL, с которого JWKS загружается и периодически обновляется
+	JWKSRefresh time.Duration // как часто перечитывать JWKS по URL
+	Issuer      string        // ожидаемый iss, пустой - не проверяется
+	Audience    string        // ожидаемый aud, пустой - не проверяется
+}
+
+// Включена ли аутентификация по JWT: задан хотя бы один источник ключей
+func (o Options) Enabled() bool {
+	return o.HMACSecret != "" || o.JWKSFile != "" || o.JWKSURL != ""
+}
+
+// Claims токена: sub - id клиента API, scope - права через пробел (RFC 8693)
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+// Права токена списком
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+/*
+Проверка JWT: подпись HS256 секретом или RS256 ключом из JWKS по kid, срок действия, iss и aud
+
+Алгоритм берётся только из разрешённых конфигом: токен с alg=none или HS256 без секрета
+не пройдёт, даже если в JWKS есть ключи.
+*/
+type Verifier struct {
+	hmacSecret []byte
+	jwks       *jwksSource
+	parser     *jwt.Parser
+}
+
+/*
+Конструктор Verifier
+
+Принимает:
+
+options Options - ключи и ожидаемые iss/aud
+
+Возвращает:
+
+verifier *Verifier - verifier, JWKS по файлу или URL уже загружен
+
+error - error
+*/
+func NewVerifier(ctx context.Context, options Options) (*Verifier, error) {
+	if !options.Enabled() {
+		return nil, errors.New("no JWT keys configured: set HMAC secret, JWKS file or JWKS URL")
+	}
+	if options.JWKSFile != "" && options.JWKSURL != "" {
+		return nil, errors.New("JWKS file and JWKS URL are mutually exclusive")
+	}
+
+	v := &Verifier{}
+	var methods []string
+	if options.HMACSecret != "" {
+		v.hmacSecret = []byte(options.HMACSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if options.JWKSFile != "" || options.JWKSURL != "" {
+		jwks, err := newJWKSSource(ctx, options.JWKSFile, options.JWKSURL, options.JWKSRefresh)
+		if err != nil {
+			return nil, err
+		}
+		v.jwks = jwks
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+	if options.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(options.Audience))
+	}
+	v.parser = jwt.NewParser(parserOptions...)
+
+	return v, nil
+}
+
+/*
+Проверяет токен и возвращает его claims
+
+Принимает:
+
+token string - JWT без префикса Bearer
+
+Возвращает:
+
+claims *Claims - claims проверенного токена, sub не пустой
+
+error - error, причина отказа для логов
+*/
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		switch t.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			return v.hmacSecret, nil
+		case jwt.SigningMethodRS256.Alg():
+			kid, _ := t.Header["kid"].(string)
+			return v.jwks.key(ctx, kid)
+		}
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no sub claim")
+	}
+
+	return &claims, nil
+}
+
+// Есть ли в списке прав все требуемые
+func HasScopes(granted []string, required ...string) bool {
+	for _, scope := range required {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,20 +1,104 @@
 package config
 
 import (
+	"WalletAPI/m/internal/logging"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Logger      *log.Logger
+	Logger      *slog.Logger
 	PostgresURL string `env:"PostgresUrl"`
 
+	// Уровень логов: debug, info, warn или error
+	LogLevel string `env:"LogLevel" envDefault:"info"`
+
+	// Куда писать логи: stdout (для контейнеров) или file (LogFile с ротацией)
+	LogOutput string `env:"LogOutput" envDefault:"file"`
+
+	// Файл логов для LogOutput=file и его ротация
+	LogFile       string `env:"LogFile" envDefault:"logs/walletapi.log"`
+	LogMaxSizeMB  int    `env:"LogMaxSizeMb" envDefault:"100"`
+	LogMaxBackups int    `env:"LogMaxBackups" envDefault:"5"`
+	LogMaxAgeDays int    `env:"LogMaxAgeDays" envDefault:"30"`
+
+	// Сколько хранится ключ идемпотентности, после этого ключ можно использовать заново
+	IdempotencyTTL time.Duration `env:"IdempotencyTtl" envDefault:"24h"`
+
+	// Через сколько неподтверждённый холд истекает и перестаёт резервировать средства
+	HoldTTL time.Duration `env:"HoldTtl" envDefault:"15m"`
+
+	// Код системного счёта журнала, против которого проводятся DEPOSIT и WITHDRAW
+	SystemAccount string `env:"SystemAccount" envDefault:"SYSTEM_CASH"`
+
+	// Код системного счёта журнала, против которого проводится обмен валют
+	FxAccount string `env:"FxAccount" envDefault:"SYSTEM_FX"`
+
+	// JSON файл с курсами валют вида {"USD/EUR": "0.92"}, пустой - обмен выключен
+	RatesFile string `env:"RatesFile"`
+
+	// Доля, на которую курс обмена для клиента хуже среднего, десятичной строкой
+	ConvertSpread string `env:"ConvertSpread" envDefault:"0.005"`
+
+	// Сколько действует котировка обмена
+	QuoteTTL time.Duration `env:"QuoteTtl" envDefault:"30s"`
+
+	// Сколько после SIGINT/SIGTERM ждём завершения запросов, которые уже выполняются
+	ShutdownTimeout time.Duration `env:"ShutdownTimeout" envDefault:"30s"`
+
+	// Сколько перед остановкой приёма соединений readiness отвечает ошибкой,
+	// чтобы балансировщик успел убрать инстанс
+	ShutdownDelay time.Duration `env:"ShutdownDelay" envDefault:"5s"`
+
+	// Доля занятых соединений пула, начиная с которой /readyz отвечает 503
+	PoolSaturationThreshold float64 `env:"PoolSaturationThreshold" envDefault:"0.9"`
+
+	// Куда писать трейсы OpenTelemetry: none, otlp (коллектор по TracingEndpoint), stdout или file (TracingFile)
+	TracingExporter string `env:"TracingExporter" envDefault:"none"`
+
+	// URL OTLP/HTTP коллектора для TracingExporter=otlp
+	TracingEndpoint string `env:"TracingEndpoint" envDefault:"http://localhost:4318"`
+
+	// Файл для TracingExporter=file
+	TracingFile string `env:"TracingFile" envDefault:"logs/traces.json"`
+
+	// Доля запросов, которые трейсятся, если вызывающий не передал решение в traceparent
+	TracingSampleRatio float64 `env:"TracingSampleRatio" envDefault:"1"`
+
+	// Ключ администратора для управления клиентами API и /v1/ledger, пустой - админские ручки выключены
+	AdminApiKey string `env:"AdminApiKey"`
+
+	// Секрет для JWT с HS256, пустой - HS256 не принимается
+	JwtHmacSecret string `env:"JwtHmacSecret"`
+
+	// Публичные ключи для JWT с RS256: JWKS в файле или по URL, не оба сразу
+	JwtJwksFile string `env:"JwtJwksFile"`
+	JwtJwksUrl  string `env:"JwtJwksUrl"`
+
+	// Как часто перечитывать JWKS по URL, чтобы подхватить ротацию ключей
+	JwtJwksRefresh time.Duration `env:"JwtJwksRefresh" envDefault:"10m"`
+
+	// Ожидаемые iss и aud токена, пустые - не проверяются
+	JwtIssuer   string `env:"JwtIssuer"`
+	JwtAudience string `env:"JwtAudience"`
+
+	// Лимит запросов одного клиента API (token bucket): в среднем в секунду и разом, 0 - без ограничения
+	RateLimitClientRps   float64 `env:"RateLimitClientRps" envDefault:"2000"`
+	RateLimitClientBurst int     `env:"RateLimitClientBurst" envDefault:"4000"`
+
+	// Лимит запросов к одному кошельку, 0 - без ограничения
+	RateLimitWalletRps   float64 `env:"RateLimitWalletRps" envDefault:"1000"`
+	RateLimitWalletBurst int     `env:"RateLimitWalletBurst" envDefault:"2000"`
+
+	logCloser io.Closer
+
 	// Redis struct {
 	// 	Addr     string `yaml:"Addr"`
 	// 	Password string `yaml:"Password"`
@@ -27,31 +111,15 @@ var (
 	once     sync.Once
 )
 
-func InitConfig(logPrefix string) (*Config, error) {
+func InitConfig() (*Config, error) {
 	var initErr error
 	once.Do(func() {
-		instance, initErr = initializeConfig(logPrefix)
+		instance, initErr = initializeConfig()
 	})
 	return instance, initErr
 }
 
-func initializeConfig(logPrefix string) (*Config, error) {
-	logDir := "logs"
-	if _, err := os.Stat(logDir); os.IsNotExist(err) {
-		os.Mkdir(logDir, 0755)
-	}
-
-	// Создаем файл для логов аутентификации
-	logFile, err := os.OpenFile(
-		filepath.Join("logs", fmt.Sprintf("%s.log", logPrefix)),
-		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
-		0666,
-	)
-
-	if err != nil {
-		log.Fatalf("couldn't open log file: %v", err)
-	}
-
+func initializeConfig() (*Config, error) {
 	configPaths := []string{
 		"../../config.env",
 		"config.env",
@@ -73,7 +141,7 @@ func initializeConfig(logPrefix string) (*Config, error) {
 
 	fmt.Printf("Config found at: %s\n", foundPath)
 
-	err = godotenv.Load(foundPath)
+	err := godotenv.Load(foundPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config file from %s: %v", foundPath, err)
 	}
@@ -84,6 +152,21 @@ func initializeConfig(logPrefix string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse environment variables: %v", err)
 	}
 
-	config.Logger = log.New(logFile, logPrefix, log.Ldate|log.Ltime)
+	config.Logger, config.logCloser, err = logging.New(logging.Options{
+		Level:      config.LogLevel,
+		Output:     config.LogOutput,
+		File:       config.LogFile,
+		MaxSizeMB:  config.LogMaxSizeMB,
+		MaxBackups: config.LogMaxBackups,
+		MaxAgeDays: config.LogMaxAgeDays,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %v", err)
+	}
 	return &config, nil
 }
+
+// Закрывает файл логов, вызывается последним при остановке
+func (c *Config) CloseLog() error {
+	return c.logCloser.Close()
+}
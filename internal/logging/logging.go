@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Куда писать логи
+const (
+	OutputStdout = "stdout" // для контейнеров, логи собирает docker
+	OutputFile   = "file"   // в файл с ротацией
+)
+
+// Настройки логгера, заполняются из конфига
+type Options struct {
+	Level      string // debug, info, warn или error
+	Output     string
+	File       string // путь к файлу для OutputFile
+	MaxSizeMB  int    // размер файла, после которого он ротируется
+	MaxBackups int    // сколько ротированных файлов хранить
+	MaxAgeDays int    // сколько дней хранить ротированные файлы
+}
+
+/*
+JSON логгер: каждая строка - JSON объект, request_id и trace_id добавляются из context
+
+Принимает:
+
+options Options - уровень и куда писать
+
+Возвращает:
+
+logger *slog.Logger - логгер
+
+closer io.Closer - закрывает файл логов, вызывается последним при остановке
+
+error - error
+*/
+func New(options Options) (*slog.Logger, io.Closer, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(options.Level)); err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q: %v", options.Level, err)
+	}
+
+	var out io.WriteCloser
+	switch options.Output {
+	case OutputStdout:
+		out = nopCloser{os.Stdout}
+	case OutputFile:
+		if err := os.MkdirAll(filepath.Dir(options.File), 0755); err != nil {
+			return nil, nil, fmt.Errorf("error creating log directory: %v", err)
+		}
+		out = &lumberjack.Logger{
+			Filename:   options.File,
+			MaxSize:    options.MaxSizeMB,
+			MaxBackups: options.MaxBackups,
+			MaxAge:     options.MaxAgeDays,
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown log output %q, expected stdout or file", options.Output)
+	}
+
+	return slog.New(NewHandler(out, level)), out, nil
+}
+
+// JSON handler, который добавляет в записи request_id и trace_id из context
+func NewHandler(w io.Writer, level slog.Level) slog.Handler {
+	return contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})}
+}
+
+type requestIDKey struct{}
+
+// Сохраняет id запроса в context, дальше он попадает во все строки лога с этим context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// Id запроса из context, пустая строка, если его нет
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Добавляет в запись request_id и trace_id из context, если они есть
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// stdout не закрываем
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,324 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Ключ advisory lock, под которым идут миграции: реплики, стартующие одновременно,
+// применяют миграции по очереди, а не наперегонки
+const advisoryLockKey int64 = 0x77616c6c6574 // "wallet"
+
+// NN_name.up.sql или NN_name.down.sql
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Миграция схемы, Down пустой, если откатить её нельзя
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Состояние миграции: AppliedAt == nil - ещё не применена
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Применяет миграции и ведёт их учёт в таблице schema_migrations
+type Migrator struct {
+	pool       *pgxpool.Pool
+	logger     *slog.Logger
+	migrations []Migration // по возрастанию версии
+}
+
+// Конструктор Migrator, миграции читаются из fsys (обычно migrations.FS)
+func NewMigrator(pool *pgxpool.Pool, logger *slog.Logger, fsys fs.FS) (*Migrator, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		match := fileName.FindStringSubmatch(file.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", file.Name(), err)
+		}
+		sql, err := fs.ReadFile(fsys, file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %v", file.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(sql)
+		} else {
+			migration.Down = string(sql)
+		}
+	}
+
+	migrator := &Migrator{pool: pool, logger: logger}
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrator.migrations = append(migrator.migrations, *migration)
+	}
+	sort.Slice(migrator.migrations, func(i, j int) bool {
+		return migrator.migrations[i].Version < migrator.migrations[j].Version
+	})
+
+	return migrator, nil
+}
+
+/*
+Применение всех ещё не применённых миграций, вызывается из main при старте
+
+Каждая миграция применяется в своей транзакции вместе с записью в schema_migrations,
+поэтому упавшая миграция не остаётся применённой наполовину
+
+Возвращает:
+
+applied int - сколько миграций применено
+
+error - error
+*/
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `
+                    INSERT INTO schema_migrations (version, name)
+                    VALUES ($1, $2)`,
+					migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("error applying migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+
+			m.logger.InfoContext(ctx, "Migration applied", "version", migration.Version, "name", migration.Name)
+			applied++
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+/*
+Откат последних применённых миграций
+
+Принимает:
+
+steps int - сколько миграций откатить
+
+Возвращает:
+
+reverted int - сколько миграций откачено
+
+error - error
+*/
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `
+                    DELETE FROM schema_migrations
+                    WHERE version = $1`,
+					migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("error reverting migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+
+			m.logger.InfoContext(ctx, "Migration reverted", "version", migration.Version, "name", migration.Name)
+			reverted++
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+/*
+Состояние всех известных миграций
+
+Применённые версии, которых нет в этом бинарнике (их применила более новая версия),
+тоже попадают в список с именем из schema_migrations
+
+Возвращает:
+
+statuses []Status - миграции по возрастанию версии
+
+error - error
+*/
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if record, ok := done[migration.Version]; ok {
+				status.AppliedAt = record.AppliedAt
+				delete(done, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for _, record := range done {
+			statuses = append(statuses, record)
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, err
+}
+
+/*
+Сколько миграций этого бинарника ещё не применено, для проверки готовности
+
+В отличие от Status не берёт advisory lock, поэтому не ждёт миграций, которые идут
+прямо сейчас в другой реплике
+
+Возвращает:
+
+pending int - количество неприменённых миграций
+
+error - error
+*/
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error acquiring connection for migrations: %v", err)
+	}
+	defer conn.Release()
+
+	done, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, migration := range m.migrations {
+		if _, ok := done[migration.Version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Выполняет fn на отдельном соединении под advisory lock. Блокировка сессионная, поэтому
+// и захват, и все миграции, и освобождение идут через одно и то же соединение
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection for migrations: %v", err)
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+		return fmt.Errorf("error taking migrations lock: %v", err)
+	}
+	defer func() {
+		// ctx мог уже истечь, а блокировку нужно снять в любом случае
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockKey); err != nil {
+			m.logger.Error("Failed to release migrations lock", "error", err)
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version BIGINT PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        )`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations: %v", err)
+	}
+
+	return fn(conn)
+}
+
+// Применённые миграции по версиям
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]Status, error) {
+	rows, err := conn.Query(ctx, `
+        SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int64]Status)
+	for rows.Next() {
+		var record Status
+		if err = rows.Scan(&record.Version, &record.Name, &record.AppliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning applied migration: %v", err)
+		}
+		done[record.Version] = record
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting applied migrations: %v", err)
+	}
+
+	return done, nil
+}
@@ -1,16 +1,401 @@
 package model
 
-// Минималистичная и удобная модель ответа от сервера, всегда использую
+import (
+	"encoding/json"
+	"time"
+)
+
+// Минималистичная и удобная модель ответа от сервера, всегда использую.
+// Code - машиночитаемый код ошибки, по нему клиенты ветвятся вместо разбора текста Error
 type Response struct {
 	Success bool   `json:"success" example:"true"`
+	Code    string `json:"code,omitempty" example:"WALLET_NOT_FOUND"`
 	Error   string `json:"error,omitempty" example:"Error message"`
 	Data    any    `json:"data,omitempty"`
 }
 
-// Модель для обновления баланса, все поля нужные, также есть примеры и
-// прописаны базовые требования к полям тела запроса
+// Тело ошибки по RFC 7807 (application/problem+json). Отдаётся вместо Response,
+// если клиент просит этот формат в заголовке Accept. Code, CurrentBalance и
+// RequestedAmount - расширения RFC, последние два только у INSUFFICIENT_FUNDS
+type Problem struct {
+	Type            string `json:"type" example:"/problems/insufficient-funds"`
+	Title           string `json:"title" example:"Insufficient funds"`
+	Status          int    `json:"status" example:"409"`
+	Detail          string `json:"detail,omitempty" example:"Wallet 550e8400-e29b-41d4-a716-446655440000 has 500, requested 1000"`
+	Instance        string `json:"instance,omitempty" example:"/v1/wallet"`
+	Code            string `json:"code" example:"INSUFFICIENT_FUNDS"`
+	CurrentBalance  *Money `json:"currentBalance,omitempty" swaggertype:"string" example:"500"`
+	RequestedAmount *Money `json:"requestedAmount,omitempty" swaggertype:"string" example:"1000"`
+}
+
+// Коды ошибок в Response.Code, стабильны между версиями API
+const (
+	ErrCodeInvalidRequest       = "INVALID_REQUEST"
+	ErrCodeInvalidWalletId      = "INVALID_WALLET_ID"
+	ErrCodeWalletNotFound       = "WALLET_NOT_FOUND"
+	ErrCodeInsufficientFunds    = "INSUFFICIENT_FUNDS"
+	ErrCodeBalanceOverflow      = "BALANCE_OVERFLOW"
+	ErrCodeWalletFrozen         = "WALLET_FROZEN"
+	ErrCodeWalletClosed         = "WALLET_CLOSED"
+	ErrCodeWalletNotEmpty       = "WALLET_NOT_EMPTY"
+	ErrCodeInvalidTransition    = "INVALID_STATUS_TRANSITION"
+	ErrCodeInvalidOperation     = "INVALID_OPERATION"
+	ErrCodeUnsupportedCurrency  = "UNSUPPORTED_CURRENCY"
+	ErrCodeInvalidCursor        = "INVALID_CURSOR"
+	ErrCodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	ErrCodeHoldNotFound         = "HOLD_NOT_FOUND"
+	ErrCodeHoldNotActive        = "HOLD_NOT_ACTIVE"
+	ErrCodeTransactionNotFound  = "TRANSACTION_NOT_FOUND"
+	ErrCodeAlreadyReversed      = "ALREADY_REVERSED"
+	ErrCodeTimeout              = "TIMEOUT"
+	ErrCodeNotReady             = "NOT_READY"
+	ErrCodeUnauthorized         = "UNAUTHORIZED"
+	ErrCodeInsufficientScope    = "INSUFFICIENT_SCOPE"
+	ErrCodeForbidden            = "FORBIDDEN"
+	ErrCodeClientNotFound       = "CLIENT_NOT_FOUND"
+	ErrCodeRateLimited          = "RATE_LIMITED"
+	ErrCodeRateNotFound         = "RATE_NOT_FOUND"
+	ErrCodeRateUnavailable      = "RATE_UNAVAILABLE"
+	ErrCodeQuoteNotFound        = "QUOTE_NOT_FOUND"
+	ErrCodeQuoteExpired         = "QUOTE_EXPIRED"
+	ErrCodeQuoteUsed            = "QUOTE_USED"
+	ErrCodeInternal             = "INTERNAL_ERROR"
+)
+
+// Модель для обновления баланса, все поля кроме idempotencyKey нужные, также есть примеры и
+// прописаны базовые требования к полям тела запроса. Amount - в минимальных единицах Currency
 type UpdateBalance struct {
-	WalletId      string `json:"valletId" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required"`
+	WalletId      string `json:"valletId" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required,uuid"`
 	OperationType string `json:"operationType" example:"DEPOSIT" enums:"DEPOSIT,WITHDRAW" binding:"required"`
-	Amount        int64  `json:"amount" example:"1000" binding:"required,gt=0"`
+	Currency      string `json:"currency" example:"USD" binding:"required,uppercase,min=3,max=10"`
+	Amount        Money  `json:"amount" swaggertype:"string" example:"1000" binding:"required,gt=0"`
+	// Необязательный, то же самое что заголовок Idempotency-Key (заголовок в приоритете)
+	IdempotencyKey string `json:"idempotencyKey,omitempty" example:"order-42-payout" binding:"omitempty,max=255"`
+}
+
+// Пакет пополнений и списаний. Atomic - все операции в одной транзакции (ошибка любой откатывает все),
+// иначе каждая выполняется отдельно и результат возвращается по каждой.
+// Выплаты в десятки тысяч операций отправляются несколькими пакетами до 10000 операций
+type UpdateBalanceBatch struct {
+	Atomic bool            `json:"atomic" example:"true"`
+	Items  []UpdateBalance `json:"items" binding:"required,min=1,max=10000,dive"`
+}
+
+// Результат одной операции пакета, Index - её позиция в items
+type BatchItemResult struct {
+	Index         int    `json:"index" example:"0"`
+	Success       bool   `json:"success" example:"true"`
+	TransactionId string `json:"transactionId,omitempty" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`
+	Replayed      bool   `json:"replayed,omitempty" example:"false"` // повтор по ключу идемпотентности, операция не выполнялась заново
+	Status        int    `json:"status,omitempty" example:"409"`
+	Code          string `json:"code,omitempty" example:"INSUFFICIENT_FUNDS"`
+	Error         string `json:"error,omitempty" example:"Insufficient funds"`
+}
+
+// Результат пакета в порядке items
+type BatchResult struct {
+	Atomic    bool              `json:"atomic" example:"false"`
+	Succeeded int               `json:"succeeded" example:"2"`
+	Failed    int               `json:"failed" example:"1"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// Ключ идемпотентности операции и хеш тела запроса, с которым он был использован впервые.
+// Ключи разных клиентов не пересекаются, поэтому ключ всегда ищется вместе с ClientId
+type Idempotency struct {
+	ClientId    string
+	Key         string
+	RequestHash string
+}
+
+// Запись журнала операций - создаётся на каждое изменение баланса,
+// по ней всегда можно объяснить, откуда взялся текущий баланс
+type Transaction struct {
+	Id            string    `json:"id" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`
+	WalletId      string    `json:"walletId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	OperationType string    `json:"operationType" example:"DEPOSIT" enums:"DEPOSIT,WITHDRAW,TRANSFER_OUT,TRANSFER_IN,CAPTURE,REVERSAL,CONVERT_OUT,CONVERT_IN"`
+	Currency      string    `json:"currency" example:"USD"`
+	Amount        Money     `json:"amount" swaggertype:"string" example:"1000"`
+	BalanceBefore Money     `json:"balanceBefore" swaggertype:"string" example:"0"`
+	BalanceAfter  Money     `json:"balanceAfter" swaggertype:"string" example:"1000"`
+	TransferId    *string   `json:"transferId,omitempty" example:"9b2f4c1e-3d7a-4f8e-a5b6-1c2d3e4f5a6b"` // общий id обеих частей перевода
+	HoldId        *string   `json:"holdId,omitempty" example:"3f1c2b7a-8d4e-4c6f-9a1b-2c3d4e5f6a7b"`     // холд, по которому списаны средства
+	EntryId       *string   `json:"entryId,omitempty" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`    // запись журнала двойной записи, у обмена - id обмена
+	ReversalOf    *string   `json:"reversalOf,omitempty" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"` // исходная операция, если это сторно
+	CreatedAt     time.Time `json:"createdAt" example:"2025-01-01T12:00:00Z"`
+}
+
+// Модель перевода между кошельками, кошельки должны различаться, валюта с обеих сторон одна
+type Transfer struct {
+	FromWalletId string `json:"fromWalletId" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required,uuid"`
+	ToWalletId   string `json:"toWalletId" example:"6ba7b810-9dad-11d1-80b4-00c04fd430c8" binding:"required,uuid,nefield=FromWalletId"`
+	Currency     string `json:"currency" example:"USD" binding:"required,uppercase,min=3,max=10"`
+	Amount       Money  `json:"amount" swaggertype:"string" example:"1000" binding:"required,gt=0"`
+}
+
+// Результат перевода: списание с одного кошелька и зачисление на другой.
+// Credit в ответе API есть, только если кошелёк получателя принадлежит тому же клиенту:
+// иначе его балансы видел бы чужой клиент
+type TransferResult struct {
+	TransferId string       `json:"transferId" example:"9b2f4c1e-3d7a-4f8e-a5b6-1c2d3e4f5a6b"`
+	Debit      Transaction  `json:"debit"`
+	Credit     *Transaction `json:"credit,omitempty"`
+}
+
+// Параметры запроса истории операций кошелька, все поля необязательные.
+// Cursor - непрозрачная строка из nextCursor предыдущей страницы
+type TransactionsQuery struct {
+	Limit         int        `form:"limit" example:"50" binding:"omitempty,gt=0,lte=500"`
+	Cursor        string     `form:"cursor"`
+	OperationType string     `form:"operationType" example:"DEPOSIT" binding:"omitempty,oneof=DEPOSIT WITHDRAW TRANSFER_OUT TRANSFER_IN CAPTURE REVERSAL CONVERT_OUT CONVERT_IN"`
+	Currency      string     `form:"currency" example:"USD" binding:"omitempty,uppercase,min=3,max=10"`
+	MinAmount     *Money     `form:"minAmount" example:"100" binding:"omitempty,gt=0"`
+	MaxAmount     *Money     `form:"maxAmount" example:"10000" binding:"omitempty,gt=0"`
+	From          *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To            *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// Страница истории операций, от новых к старым. NextCursor пустой на последней странице
+type TransactionsPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"nextCursor,omitempty" example:"MjAyNS0wMS0wMVQxMjowMDowMFp8N2M5ZTY2Nzk"`
+}
+
+// Валюта и её масштаб: сколько знаков после запятой у минимальной единицы (USD - 2, BTC - 8)
+type Currency struct {
+	Code  string `json:"code" example:"USD"`
+	Scale int    `json:"scale" example:"2"`
+}
+
+// Баланс кошелька в одной валюте: Balance - все средства, Available - то, что можно потратить,
+// Held - зарезервировано активными холдами. Суммы в минимальных единицах валюты
+type Balance struct {
+	Currency  string `json:"currency" example:"USD"`
+	Scale     int    `json:"scale" example:"2"`
+	Balance   Money  `json:"balance" swaggertype:"string" example:"1000"`
+	Available Money  `json:"available" swaggertype:"string" example:"700"`
+	Held      Money  `json:"held" swaggertype:"string" example:"300"`
+}
+
+// Кошелёк: статус и данные, которые клиент хранит вместе с ним. Metadata - произвольный JSON объект,
+// null если не задан
+type Wallet struct {
+	WalletId        string          `json:"walletId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Status          string          `json:"status" example:"ACTIVE" enums:"ACTIVE,FROZEN,CLOSED"`
+	DepositsBlocked bool            `json:"depositsBlocked" example:"false"`
+	ExternalOwnerId string          `json:"externalOwnerId" example:"user-42"` // id владельца в системе клиента
+	DisplayName     string          `json:"displayName" example:"Main wallet"`
+	Metadata        json.RawMessage `json:"metadata" swaggertype:"object"`
+	Labels          []string        `json:"labels" example:"eu,vip"`
+}
+
+// Кошелёк с балансами по валютам, в которых по нему были операции
+type WalletBalances struct {
+	Wallet
+	Balances []Balance `json:"balances"`
+}
+
+// Модель создания кошелька, тело необязательное и все поля тоже
+type CreateWallet struct {
+	ExternalOwnerId string          `json:"externalOwnerId" example:"user-42" binding:"max=255"`
+	DisplayName     string          `json:"displayName" example:"Main wallet" binding:"max=200"`
+	Metadata        json.RawMessage `json:"metadata" swaggertype:"object"`
+	Labels          []string        `json:"labels" example:"eu,vip" binding:"max=50,dive,min=1,max=64"`
+}
+
+// Модель изменения кошелька (PATCH): переданные поля заменяются целиком, не переданные не меняются.
+// Пустая строка, пустой список labels и "metadata": null очищают поле
+type UpdateWallet struct {
+	ExternalOwnerId *string         `json:"externalOwnerId" example:"user-42" binding:"omitempty,max=255"`
+	DisplayName     *string         `json:"displayName" example:"Savings" binding:"omitempty,max=200"`
+	Metadata        json.RawMessage `json:"metadata" swaggertype:"object"`
+	Labels          *[]string       `json:"labels" example:"eu" binding:"omitempty,max=50,dive,min=1,max=64"`
+}
+
+// Модель заморозки кошелька. Замороженный кошелёк не списывает средства, с blockDeposits - и не получает
+type FreezeWallet struct {
+	Reason        string `json:"reason" example:"AML review #1234" binding:"required,max=500"`
+	BlockDeposits bool   `json:"blockDeposits" example:"false"`
+}
+
+// Модель разморозки и закрытия кошелька, причина обязательна - она попадает в историю статусов
+type WalletStatusReason struct {
+	Reason string `json:"reason" example:"Review completed" binding:"required,max=500"`
+}
+
+// Смена статуса кошелька: кто, когда и почему. Записи только добавляются, это история для комплаенса
+type WalletStatusChange struct {
+	Id              string    `json:"id" example:"4b3a2c1d-5e6f-4a7b-8c9d-0e1f2a3b4c5d"`
+	WalletId        string    `json:"walletId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	PreviousStatus  string    `json:"previousStatus" example:"ACTIVE" enums:"ACTIVE,FROZEN,CLOSED"`
+	Status          string    `json:"status" example:"FROZEN" enums:"ACTIVE,FROZEN,CLOSED"`
+	DepositsBlocked bool      `json:"depositsBlocked" example:"false"`
+	Actor           string    `json:"actor" example:"9b2e6f1a-4c3d-4e5f-8a7b-1c2d3e4f5a6b"` // id клиента API, сменившего статус
+	Reason          string    `json:"reason" example:"AML review #1234"`
+	CreatedAt       time.Time `json:"createdAt" example:"2025-01-01T12:00:00Z"`
+}
+
+// Параметры запроса баланса: с currency возвращается баланс только в этой валюте, даже нулевой
+type BalanceQuery struct {
+	Currency string `form:"currency" example:"USD" binding:"omitempty,uppercase,min=3,max=10"`
+}
+
+// Холд - резерв суммы на кошельке до подтверждения (capture) или отмены (void)
+type Hold struct {
+	Id             string    `json:"id" example:"3f1c2b7a-8d4e-4c6f-9a1b-2c3d4e5f6a7b"`
+	WalletId       string    `json:"walletId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Currency       string    `json:"currency" example:"USD"`
+	Amount         Money     `json:"amount" swaggertype:"string" example:"300"`
+	CapturedAmount Money     `json:"capturedAmount" swaggertype:"string" example:"0"`
+	Status         string    `json:"status" example:"ACTIVE" enums:"ACTIVE,CAPTURED,VOIDED,EXPIRED"`
+	ExpiresAt      time.Time `json:"expiresAt" example:"2025-01-01T12:15:00Z"`
+	CreatedAt      time.Time `json:"createdAt" example:"2025-01-01T12:00:00Z"`
+	UpdatedAt      time.Time `json:"updatedAt" example:"2025-01-01T12:00:00Z"`
+}
+
+// Модель создания холда
+type CreateHold struct {
+	Currency string `json:"currency" example:"USD" binding:"required,uppercase,min=3,max=10"`
+	Amount   Money  `json:"amount" swaggertype:"string" example:"300" binding:"required,gt=0"`
+}
+
+// Модель подтверждения холда, без amount списывается вся зарезервированная сумма
+type CaptureHold struct {
+	Amount Money `json:"amount,omitempty" swaggertype:"string" example:"250" binding:"omitempty,gt=0"`
+}
+
+// Результат подтверждения холда: сам холд и запись журнала о списании
+type CaptureResult struct {
+	Hold        Hold        `json:"hold"`
+	Transaction Transaction `json:"transaction"`
+}
+
+// Модель сторно операции, без amount сторнируется весь ещё не сторнированный остаток
+type ReverseTransaction struct {
+	Amount Money `json:"amount,omitempty" swaggertype:"string" example:"400" binding:"omitempty,gt=0"`
+}
+
+// Результат сторно: операции REVERSAL по каждому затронутому кошельку (две для перевода)
+// и сколько ещё можно сторнировать по исходной операции
+type ReversalResult struct {
+	ReversalId            string        `json:"reversalId" example:"5d4c3b2a-1f0e-4d9c-8b7a-6f5e4d3c2b1a"`
+	OriginalTransactionId string        `json:"originalTransactionId" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`
+	Amount                Money         `json:"amount" swaggertype:"string" example:"400"`
+	Remaining             Money         `json:"remaining" swaggertype:"string" example:"600"`
+	Transactions          []Transaction `json:"transactions"`
+}
+
+// Параметры запроса котировки: сколько валюты To получится за Amount минимальных единиц From
+type QuoteQuery struct {
+	From   string `form:"from" example:"USD" binding:"required,uppercase,min=3,max=10"`
+	To     string `form:"to" example:"EUR" binding:"required,uppercase,min=3,max=10,nefield=From"`
+	Amount Money  `form:"amount" example:"10000" binding:"required,gt=0"`
+}
+
+// Котировка обмена. Rate - курс для клиента, это MidRate за вычетом доли Spread. Курсы - десятичные
+// строки, суммы - в минимальных единицах своих валют. До ExpiresAt по Id можно один раз выполнить обмен
+// именно по этой котировке
+type Quote struct {
+	Id           string    `json:"id" example:"2c5e8f1a-7b3d-4e9c-a1f2-3b4c5d6e7f80"`
+	ClientId     string    `json:"-"` // клиент, запросивший котировку, только он может по ней обменять
+	FromCurrency string    `json:"fromCurrency" example:"USD"`
+	ToCurrency   string    `json:"toCurrency" example:"EUR"`
+	FromAmount   Money     `json:"fromAmount" swaggertype:"string" example:"10000"`
+	ToAmount     Money     `json:"toAmount" swaggertype:"string" example:"9154"`
+	Rate         string    `json:"rate" example:"0.9154"`
+	MidRate      string    `json:"midRate" example:"0.92"`
+	Spread       string    `json:"spread" example:"0.005"`
+	ExpiresAt    time.Time `json:"expiresAt" example:"2025-01-01T12:00:30Z"`
+	CreatedAt    time.Time `json:"createdAt" example:"2025-01-01T12:00:00Z"`
+}
+
+// Модель обмена между балансами кошелька: либо quoteId котировки, либо fromCurrency, toCurrency
+// и amount для обмена по текущему курсу, одновременно нельзя
+type Convert struct {
+	QuoteId      string `json:"quoteId,omitempty" example:"2c5e8f1a-7b3d-4e9c-a1f2-3b4c5d6e7f80" binding:"omitempty,uuid"`
+	FromCurrency string `json:"fromCurrency,omitempty" example:"USD" binding:"omitempty,uppercase,min=3,max=10"`
+	ToCurrency   string `json:"toCurrency,omitempty" example:"EUR" binding:"omitempty,uppercase,min=3,max=10"`
+	Amount       Money  `json:"amount,omitempty" swaggertype:"string" example:"10000" binding:"omitempty,gt=0"`
+}
+
+// Результат обмена: списание CONVERT_OUT в одной валюте и зачисление CONVERT_IN в другой
+// с курсом, по которому он выполнен. QuoteId пустой у обмена по текущему курсу
+type ConversionResult struct {
+	ConversionId string      `json:"conversionId" example:"8e7d6c5b-4a3f-4e2d-9c1b-0a9f8e7d6c5b"`
+	QuoteId      *string     `json:"quoteId,omitempty" example:"2c5e8f1a-7b3d-4e9c-a1f2-3b4c5d6e7f80"`
+	Rate         string      `json:"rate" example:"0.9154"`
+	MidRate      string      `json:"midRate" example:"0.92"`
+	Spread       string      `json:"spread" example:"0.005"`
+	Debit        Transaction `json:"debit"`
+	Credit       Transaction `json:"credit"`
+}
+
+// Баланс системного счёта журнала в одной валюте
+type LedgerAccount struct {
+	Code     string `json:"code" example:"SYSTEM_CASH"`
+	Currency string `json:"currency" example:"USD"`
+	Balance  Money  `json:"balance" swaggertype:"string" example:"-1000"`
+}
+
+// Итоги журнала в одной валюте. Total - сумма балансов всех счетов в этой валюте, всегда должна быть 0.
+// Итоги могут выйти за int64, даже когда баланс каждого счёта в него помещается
+type LedgerCurrency struct {
+	Currency     string     `json:"currency" example:"USD"`
+	WalletsTotal MoneyTotal `json:"walletsTotal" swaggertype:"string" example:"1000"`
+	Total        MoneyTotal `json:"total" swaggertype:"string" example:"0"`
+}
+
+// Сверка журнала двойной записи, валюты сверяются каждая отдельно.
+// MismatchedWallets - балансы кошельков, которые не совпадают с суммой проводок в своей валюте
+type LedgerSummary struct {
+	SystemAccounts    []LedgerAccount  `json:"systemAccounts"`
+	Currencies        []LedgerCurrency `json:"currencies"`
+	MismatchedWallets int64            `json:"mismatchedWallets" example:"0"`
+	Balanced          bool             `json:"balanced" example:"true"`
+}
+
+// Результат проверки одной зависимости в /readyz
+type HealthCheck struct {
+	Status  string         `json:"status" example:"ok" enums:"ok,fail"`
+	Error   string         `json:"error,omitempty" example:"context deadline exceeded"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Ответ /healthz и /readyz: Status ok, только если все проверки ok
+type HealthReport struct {
+	Status string                 `json:"status" example:"ok" enums:"ok,fail"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// Клиент API, от его имени создаются кошельки. Сам ключ не хранится, KeyPrefix - его начало,
+// чтобы отличать ключи в списке
+type ApiClient struct {
+	Id        string     `json:"id" example:"9b2e6f1a-4c3d-4e5f-8a7b-1c2d3e4f5a6b"`
+	Name      string     `json:"name" example:"billing-service"`
+	KeyPrefix string     `json:"keyPrefix" example:"wk_Xy7pQ2a"`
+	CreatedAt time.Time  `json:"createdAt" example:"2025-01-01T12:00:00Z"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty" example:"2025-02-01T12:00:00Z"`
+}
+
+// Модель создания клиента API
+type CreateApiClient struct {
+	Name string `json:"name" example:"billing-service" binding:"required,max=100"`
+}
+
+// Модель назначения владельца кошелька администратором
+type AssignWalletOwner struct {
+	ClientId string `json:"clientId" example:"9b2e6f1a-4c3d-4e5f-8a7b-1c2d3e4f5a6b" binding:"required,uuid"`
+}
+
+// Владелец кошелька после назначения. PreviousOwnerId пустой, если владельца не было
+type WalletOwner struct {
+	WalletId        string `json:"walletId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	OwnerId         string `json:"ownerId" example:"9b2e6f1a-4c3d-4e5f-8a7b-1c2d3e4f5a6b"`
+	PreviousOwnerId string `json:"previousOwnerId,omitempty" example:""`
+}
+
+// Созданный клиент API с ключом. Ключ возвращается только здесь, потом его не узнать
+type CreatedApiClient struct {
+	ApiClient
+	ApiKey string `json:"apiKey" example:"wk_Xy7pQ2aLm9vN4bR8tK1wE6sD3fG5hJ0zC2xV7nM"`
 }
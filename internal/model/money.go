@@ -0,0 +1,138 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Сумма не помещается в int64 минимальных единиц
+var ErrMoneyOverflow = errors.New("amount overflows int64 minor units")
+
+/*
+Денежная сумма - целое число минимальных единиц валюты: 1000 в USD (scale 2) это 10.00 USD
+
+Валюта суммы - поле Currency того, чему сумма принадлежит (операции, баланса, холда),
+масштаб валюты - в таблице currencies. В БД суммы хранятся в BIGINT.
+
+В JSON сумма - строка из цифр ("1000"): float64 в JavaScript и многих JSON библиотеках
+теряет точность после 2^53, а у BTC в минимальных единицах это всего 90 млн монет.
+На вход принимается и строка, и целое число
+*/
+type Money int64
+
+// Сумма a + b, ErrMoneyOverflow при выходе за int64
+func (m Money) Add(other Money) (Money, error) {
+	if other > 0 && m > math.MaxInt64-other || other < 0 && m < math.MinInt64-other {
+		return 0, fmt.Errorf("%d + %d: %w", m, other, ErrMoneyOverflow)
+	}
+	return m + other, nil
+}
+
+func (m Money) String() string {
+	return strconv.FormatInt(int64(m), 10)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, m.String()), nil
+}
+
+// Строка или число JSON, только целое: "10.5", 1e3 и суммы вне int64 отклоняются
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	text := data
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		text = data[1 : len(data)-1]
+	}
+	value, err := strconv.ParseInt(string(text), 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return fmt.Errorf("amount %s: %w", text, ErrMoneyOverflow)
+		}
+		return fmt.Errorf("invalid amount %s: expected integer minor units", data)
+	}
+
+	*m = Money(value)
+	return nil
+}
+
+/*
+Сумма по многим счетам, например итог журнала по всем кошелькам в валюте
+
+Баланс каждого счёта помещается в Money, а сумма балансов - не обязательно, поэтому итоги
+считаются в big.Int и никогда не переполняются. Нулевое значение - 0. В JSON - строка из цифр, как Money
+*/
+type MoneyTotal struct {
+	value *big.Int
+}
+
+// Итог плюс сумма m, исходный итог не меняется
+func (t MoneyTotal) Add(m Money) MoneyTotal {
+	return t.Plus(MoneyTotal{value: big.NewInt(int64(m))})
+}
+
+// Сумма двух итогов, исходные не меняются
+func (t MoneyTotal) Plus(other MoneyTotal) MoneyTotal {
+	return MoneyTotal{value: new(big.Int).Add(t.int(), other.int())}
+}
+
+func (t MoneyTotal) IsZero() bool {
+	return t.int().Sign() == 0
+}
+
+// Итог как Money, ErrMoneyOverflow если он не помещается в int64
+func (t MoneyTotal) Money() (Money, error) {
+	if !t.int().IsInt64() {
+		return 0, fmt.Errorf("%s: %w", t, ErrMoneyOverflow)
+	}
+	return Money(t.int().Int64()), nil
+}
+
+func (t MoneyTotal) int() *big.Int {
+	if t.value == nil {
+		return new(big.Int)
+	}
+	return t.value
+}
+
+func (t MoneyTotal) String() string {
+	return t.int().String()
+}
+
+func (t MoneyTotal) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, t.String()), nil
+}
+
+// Строка или число JSON, только целое, любой длины
+func (t *MoneyTotal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	text := data
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		text = data[1 : len(data)-1]
+	}
+	value, ok := new(big.Int).SetString(string(text), 10)
+	if !ok {
+		return fmt.Errorf("invalid total %s: expected integer minor units", data)
+	}
+
+	t.value = value
+	return nil
+}
+
+// Итог из десятичной строки, например SUM(...)::text из PostgreSQL
+func ParseMoneyTotal(text string) (MoneyTotal, error) {
+	value, ok := new(big.Int).SetString(text, 10)
+	if !ok {
+		return MoneyTotal{}, fmt.Errorf("invalid total %q", text)
+	}
+	return MoneyTotal{value: value}, nil
+}
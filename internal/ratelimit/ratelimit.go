@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Как часто удаляются корзины ключей, от которых давно не было запросов
+const sweepInterval = time.Minute
+
+// Лимит для одного ключа, заполняется из конфига
+type Options struct {
+	RPS   float64 // запросов в секунду в среднем, 0 - без ограничения
+	Burst int     // сколько запросов можно сделать разом, 0 - столько же, сколько RPS
+}
+
+/*
+Token bucket на каждый ключ (клиента или кошелёк): корзина на Burst запросов пополняется
+со скоростью RPS, запрос без токена отклоняется
+
+nil *Limiter пропускает все запросы, так лимит выключается в конфиге
+*/
+type Limiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration // после такого простоя корзина снова полная и её можно удалить
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Конструктор Limiter, nil при RPS <= 0
+func New(options Options) *Limiter {
+	if options.RPS <= 0 {
+		return nil
+	}
+
+	burst := options.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(options.RPS))
+	}
+
+	return &Limiter{
+		limit:     rate.Limit(options.RPS),
+		burst:     burst,
+		idle:      max(time.Duration(float64(burst)/options.RPS*float64(time.Second)), sweepInterval),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+/*
+Забирает токен из корзины ключа
+
+Принимает:
+
+key string - id клиента или UUID кошелька
+
+Возвращает:
+
+ok bool - запрос укладывается в лимит
+
+retryAfter time.Duration - при !ok через сколько появится токен
+*/
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	l.mu.Unlock()
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// токен не ждём: отменяем резерв, чтобы отклонённый запрос не занимал токен следующего
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Удаляет корзины, простоявшие дольше idle: новая корзина для ключа будет такой же полной
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > l.idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
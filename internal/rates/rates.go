@@ -0,0 +1,140 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Сколько знаков после запятой у курса: курс с учётом спреда округляется до них вниз,
+// с округлённым курсом и считается сумма обмена, чтобы её можно было повторить по записи
+const Precision = 18
+
+var (
+	ErrRateNotFound   = errors.New("rate not found")
+	ErrAmountTooSmall = errors.New("amount is too small to convert")
+	ErrAmountOverflow = errors.New("converted amount overflows int64")
+)
+
+/*
+Курсы из файла или конфига, для локального запуска и тестов
+
+Курс пары FROM/TO - сколько основных единиц TO дают за одну основную единицу FROM.
+Если задана только обратная пара, курс считается как 1 / обратный
+*/
+type Static struct {
+	rates map[string]*big.Rat
+}
+
+// Конструктор Static, rates - курсы десятичными строками по парам вида "USD/EUR"
+func NewStatic(rates map[string]string) (*Static, error) {
+	s := &Static{rates: make(map[string]*big.Rat, len(rates))}
+	for pair, value := range rates {
+		from, to, ok := strings.Cut(pair, "/")
+		if !ok || from == "" || to == "" || from == to {
+			return nil, fmt.Errorf("invalid currency pair %q, expected FROM/TO", pair)
+		}
+		rate, err := Parse(value)
+		if err != nil || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q for %s", value, pair)
+		}
+		s.rates[strings.ToUpper(from)+"/"+strings.ToUpper(to)] = rate
+	}
+	return s, nil
+}
+
+// Курсы из JSON файла вида {"USD/EUR": "0.92", "BTC/USD": "65000"}
+func LoadFile(path string) (*Static, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rates file: %v", err)
+	}
+
+	var rates map[string]string
+	if err = json.Unmarshal(raw, &rates); err != nil {
+		return nil, fmt.Errorf("error parsing rates file %s: %v", path, err)
+	}
+	return NewStatic(rates)
+}
+
+// Средний курс пары, ErrRateNotFound - если нет ни её, ни обратной
+func (s *Static) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	if rate, ok := s.rates[from+"/"+to]; ok {
+		return new(big.Rat).Set(rate), nil
+	}
+	if rate, ok := s.rates[to+"/"+from]; ok {
+		return new(big.Rat).Inv(rate), nil
+	}
+	return nil, fmt.Errorf("%s/%s: %w", from, to, ErrRateNotFound)
+}
+
+// Курс или спред из десятичной строки
+func Parse(value string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", value)
+	}
+	return rate, nil
+}
+
+// Десятичная строка без лишних нулей, дробная часть не длиннее Precision
+func Format(value *big.Rat) string {
+	text := value.FloatString(Precision)
+	text = strings.TrimRight(text, "0")
+	return strings.TrimSuffix(text, ".")
+}
+
+// Курс для клиента: средний курс за вычетом доли spread, округлённый вниз до Precision знаков
+func WithSpread(mid, spread *big.Rat) *big.Rat {
+	rate := new(big.Rat).Sub(big.NewRat(1, 1), spread)
+	rate.Mul(rate, mid)
+	return floor(rate, Precision)
+}
+
+/*
+Сумма обмена в минимальных единицах валюты TO, округлённая вниз
+
+Принимает:
+
+amount int64 - сумма в минимальных единицах валюты FROM
+
+fromScale, toScale int - масштабы валют
+
+rate *big.Rat - курс в основных единицах
+
+Возвращает:
+
+int64 - сумма в минимальных единицах TO
+
+error - ErrAmountTooSmall, если после округления получается 0, ErrAmountOverflow, если не влезает в int64
+*/
+func Convert(amount int64, fromScale, toScale int, rate *big.Rat) (int64, error) {
+	converted := new(big.Rat).SetInt64(amount)
+	converted.Mul(converted, rate)
+	converted.Mul(converted, new(big.Rat).SetFrac(pow10(toScale), pow10(fromScale)))
+
+	result := new(big.Int).Quo(converted.Num(), converted.Denom())
+	switch {
+	case !result.IsInt64():
+		return 0, ErrAmountOverflow
+	case result.Sign() <= 0:
+		return 0, ErrAmountTooSmall
+	}
+	return result.Int64(), nil
+}
+
+// Округление положительного числа вниз до digits знаков после запятой
+func floor(value *big.Rat, digits int) *big.Rat {
+	scale := pow10(digits)
+	scaled := new(big.Int).Mul(value.Num(), scale)
+	scaled.Quo(scaled, value.Denom())
+	return new(big.Rat).SetFrac(scaled, scale)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
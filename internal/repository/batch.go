@@ -0,0 +1,487 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/metrics"
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/tracing"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Пакет выполняется одним и тем же числом запросов, сколько бы в нём ни было операций:
+// кошельки блокируются и читаются вместе с балансами и холдами несколькими запросами на весь пакет,
+// операции рассчитываются в памяти, а балансы, журнал двойной записи, журнал операций и ключи
+// идемпотентности пишутся многострочными INSERT по unnest массивов
+
+// Кошелёк и валюта - ключ баланса в расчёте пакета
+type balanceKey struct {
+	walletUUID string
+	currency   string
+}
+
+// Исход операции пакета без атомарности: Transaction при успехе, иначе Err
+type BatchOutcome struct {
+	Transaction *model.Transaction
+	Err         error
+}
+
+// Состояние кошельков пакета, прочитанное под блокировкой их строк
+type batchState struct {
+	wallets   map[string]walletState
+	supported map[string]bool
+	balances  map[balanceKey]model.Money
+	held      map[balanceKey]model.Money
+	system    map[string]model.Money // баланс системного счёта по валютам
+}
+
+// Рассчитанные операции пакета, которые ещё предстоит записать. Балансы - с учётом уже
+// рассчитанных операций, поэтому следующая операция того же кошелька видит результат предыдущей
+type batchWrites struct {
+	transactions []*model.Transaction
+	entries      []journalEntry
+	idempotency  []*model.Idempotency // по индексам transactions, nil у операций без ключа
+	indexes      []int                // индекс операции в пакете по индексам transactions
+	balances     map[balanceKey]model.Money
+	system       map[string]model.Money
+	keys         map[string]bool
+}
+
+func newBatchWrites(size int) *batchWrites {
+	return &batchWrites{
+		transactions: make([]*model.Transaction, 0, size),
+		entries:      make([]journalEntry, 0, size),
+		idempotency:  make([]*model.Idempotency, 0, size),
+		indexes:      make([]int, 0, size),
+		balances:     make(map[balanceKey]model.Money),
+		system:       make(map[string]model.Money),
+		keys:         make(map[string]bool),
+	}
+}
+
+/*
+Атомарный пакет DEPOSIT/WITHDRAW - все операции в одной транзакции: либо выполняются все, либо ни одна
+
+Кошельки пакета блокируются заранее одним запросом в порядке возрастания UUID, как в Transfer, поэтому
+параллельные пакеты с общими кошельками не приводят к дедлокам
+
+Принимает:
+
+items []model.UpdateBalance - операции пакета
+
+idempotency []*model.Idempotency - ключи идемпотентности по индексам items, nil у операций без ключа
+
+Возвращает:
+
+transactions []model.Transaction - записи журнала операций в порядке items
+
+error - error, ошибка операции оборачивается в *BatchItemError с её индексом
+*/
+func (r *WalletRepo) UpdateBatch(ctx context.Context, items []model.UpdateBalance, idempotency []*model.Idempotency) ([]model.Transaction, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.UpdateBatch", attribute.Int("batch.size", len(items)))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	state, err := r.loadBatch(ctx, tx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	writes := newBatchWrites(len(items))
+	for i, item := range items {
+		if err = r.planUpdate(state, writes, i, item, idempotency[i]); err != nil {
+			return nil, &BatchItemError{Index: i, Err: err}
+		}
+	}
+
+	taken, err := r.writeBatch(ctx, tx, writes)
+	if err != nil {
+		return nil, err
+	}
+	if len(taken) > 0 {
+		return nil, &BatchItemError{Index: taken[0], Err: ErrIdempotencyKeyExists}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	transactions := make([]model.Transaction, 0, len(writes.transactions))
+	for _, transaction := range writes.transactions {
+		transactions = append(transactions, *transaction)
+	}
+
+	r.logger.InfoContext(ctx, "Atomic batch applied", "operations", len(items), "wallets", len(state.wallets))
+	return transactions, nil
+}
+
+/*
+Пакет DEPOSIT/WITHDRAW без атомарности: ошибка одной операции не влияет на остальные
+
+Операции рассчитываются и записываются так же, как в UpdateBatch, одной транзакцией, но упавшая
+операция пропускается, а не откатывает пакет. Ключ идемпотентности, который параллельный запрос
+занял уже после расчёта, выясняется только при записи - тогда транзакция откатывается и пакет
+рассчитывается заново, а операция с этим ключом получает ErrIdempotencyKeyExists. Каждый повтор
+исключает хотя бы одну операцию, так что повторов не больше, чем ключей в пакете
+
+Принимает:
+
+items []model.UpdateBalance - операции пакета
+
+idempotency []*model.Idempotency - ключи идемпотентности по индексам items, nil у операций без ключа
+
+Возвращает:
+
+outcomes []BatchOutcome - исход каждой операции в порядке items
+
+error - error, если не удалось записать пакет целиком
+*/
+func (r *WalletRepo) UpdateBatchBestEffort(ctx context.Context, items []model.UpdateBalance, idempotency []*model.Idempotency) ([]BatchOutcome, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.UpdateBatchBestEffort", attribute.Int("batch.size", len(items)))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	taken := make(map[int]bool)
+	for {
+		outcomes, conflicts, err := r.tryBatchBestEffort(ctx, items, idempotency, taken)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) == 0 {
+			return outcomes, nil
+		}
+		r.logger.WarnContext(ctx, "Idempotency keys of batch taken concurrently, recalculating batch", "keys", len(conflicts))
+		for _, i := range conflicts {
+			taken[i] = true
+		}
+	}
+}
+
+// Одна попытка UpdateBatchBestEffort. Операции из taken сразу получают ErrIdempotencyKeyExists.
+// Если ключи каких-то операций оказались заняты при записи, транзакция откатывается и возвращаются их индексы
+func (r *WalletRepo) tryBatchBestEffort(ctx context.Context, items []model.UpdateBalance, idempotency []*model.Idempotency, taken map[int]bool) ([]BatchOutcome, []int, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	state, err := r.loadBatch(ctx, tx, items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outcomes := make([]BatchOutcome, len(items))
+	writes := newBatchWrites(len(items))
+	failed := 0
+	for i, item := range items {
+		if taken[i] {
+			outcomes[i].Err = ErrIdempotencyKeyExists
+		} else {
+			outcomes[i].Err = r.planUpdate(state, writes, i, item, idempotency[i])
+		}
+		if outcomes[i].Err != nil {
+			failed++
+		}
+	}
+
+	conflicts, err := r.writeBatch(ctx, tx, writes)
+	if err != nil || len(conflicts) > 0 {
+		return nil, conflicts, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, nil, dbError(err, "error committing transaction")
+	}
+
+	for j, transaction := range writes.transactions {
+		outcomes[writes.indexes[j]].Transaction = transaction
+	}
+
+	r.logger.InfoContext(ctx, "Batch applied", "operations", len(items), "failed", failed, "wallets", len(state.wallets))
+	return outcomes, nil, nil
+}
+
+// Блокировка кошельков пакета в порядке возрастания UUID и чтение всего, что нужно для расчёта его операций:
+// статусов кошельков, поддерживаемых валют, балансов, сумм холдов и баланса системного счёта.
+// Строки системного счёта блокируются после кошельков, в том же порядке, что и при одиночных операциях.
+// Кошельков, которых нет, в batchState нет, их операции получают ErrWalletNotFound при расчёте
+func (r *WalletRepo) loadBatch(ctx context.Context, tx pgx.Tx, items []model.UpdateBalance) (*batchState, error) {
+	seenWallets := make(map[string]bool, len(items))
+	seenCurrencies := make(map[string]bool)
+	var walletUUIDs, currencies []string
+	for _, item := range items {
+		walletUUID := strings.ToLower(item.WalletId)
+		if !seenWallets[walletUUID] {
+			seenWallets[walletUUID] = true
+			walletUUIDs = append(walletUUIDs, walletUUID)
+		}
+		if !seenCurrencies[item.Currency] {
+			seenCurrencies[item.Currency] = true
+			currencies = append(currencies, item.Currency)
+		}
+	}
+	sort.Strings(walletUUIDs)
+
+	state := &batchState{
+		wallets:   make(map[string]walletState, len(walletUUIDs)),
+		supported: make(map[string]bool, len(currencies)),
+		balances:  make(map[balanceKey]model.Money),
+		held:      make(map[balanceKey]model.Money),
+		system:    make(map[string]model.Money, len(currencies)),
+	}
+
+	lockStart := time.Now()
+	rows, err := tx.Query(ctx, `
+        SELECT uuid, status, deposits_blocked FROM wallets
+        WHERE uuid = ANY($1::uuid[])
+        ORDER BY uuid
+        FOR UPDATE`, // предотвращает race conditions и дедлоки между пакетами
+		walletUUIDs)
+	if err != nil {
+		return nil, dbError(err, "error locking batch wallets")
+	}
+	for rows.Next() {
+		var walletUUID string
+		var wallet walletState
+		if err = rows.Scan(&walletUUID, &wallet.status, &wallet.depositsBlocked); err != nil {
+			rows.Close()
+			return nil, dbError(err, "error scanning batch wallet")
+		}
+		state.wallets[walletUUID] = wallet
+	}
+	rows.Close()
+	metrics.LockWait.WithLabelValues("BATCH").Observe(time.Since(lockStart).Seconds())
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error locking batch wallets")
+	}
+
+	rows, err = tx.Query(ctx, `
+        SELECT code FROM currencies
+        WHERE code = ANY($1)`,
+		currencies)
+	if err != nil {
+		return nil, dbError(err, "error getting batch currencies")
+	}
+	for rows.Next() {
+		var currency string
+		if err = rows.Scan(&currency); err != nil {
+			rows.Close()
+			return nil, dbError(err, "error scanning batch currency")
+		}
+		state.supported[currency] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting batch currencies")
+	}
+
+	if err = scanBatchAmounts(ctx, tx, state.balances, `
+        SELECT wallet_uuid, currency, balance FROM wallet_balances
+        WHERE wallet_uuid = ANY($1::uuid[])
+          AND currency = ANY($2)`,
+		walletUUIDs, currencies); err != nil {
+		return nil, dbError(err, "error getting batch balances")
+	}
+
+	// как heldAmount: холды меняются только под блокировкой кошелька, так что суммы не устареют
+	if err = scanBatchAmounts(ctx, tx, state.held, `
+        SELECT wallet_uuid, currency, SUM(amount) FROM holds
+        WHERE wallet_uuid = ANY($1::uuid[])
+          AND currency = ANY($2)
+          AND status = 'ACTIVE'
+          AND expires_at > now()
+        GROUP BY wallet_uuid, currency`,
+		walletUUIDs, currencies); err != nil {
+		return nil, dbError(err, "error getting batch held amounts")
+	}
+
+	rows, err = tx.Query(ctx, `
+        SELECT currency, balance FROM account_balances
+        WHERE account_id = $1
+          AND currency = ANY($2)
+        ORDER BY currency
+        FOR UPDATE`,
+		r.systemAccountId, currencies)
+	if err != nil {
+		return nil, dbError(err, "error locking system account balances")
+	}
+	for rows.Next() {
+		var currency string
+		var balance model.Money
+		if err = rows.Scan(&currency, &balance); err != nil {
+			rows.Close()
+			return nil, dbError(err, "error scanning system account balance")
+		}
+		state.system[currency] = balance
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error locking system account balances")
+	}
+
+	return state, nil
+}
+
+// Суммы по кошельку и валюте из запроса вида SELECT wallet_uuid, currency, amount
+func scanBatchAmounts(ctx context.Context, tx pgx.Tx, amounts map[balanceKey]model.Money, sql string, args ...any) error {
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key balanceKey
+		var amount model.Money
+		if err = rows.Scan(&key.walletUUID, &key.currency, &amount); err != nil {
+			return err
+		}
+		amounts[key] = amount
+	}
+	return rows.Err()
+}
+
+// Расчёт одной операции пакета с теми же проверками, что и в applyUpdate. Успешная операция
+// добавляется в writes, упавшая ничего в нём не меняет
+func (r *WalletRepo) planUpdate(state *batchState, writes *batchWrites, index int, item model.UpdateBalance, idempotency *model.Idempotency) error {
+	walletUUID := strings.ToLower(item.WalletId)
+	wallet, ok := state.wallets[walletUUID]
+	if !ok {
+		return fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+	if !state.supported[item.Currency] {
+		return fmt.Errorf("currency %s: %w", item.Currency, ErrUnsupportedCurrency)
+	}
+
+	key := balanceKey{walletUUID: walletUUID, currency: item.Currency}
+	currentBalance, ok := writes.balances[key]
+	if !ok {
+		currentBalance = state.balances[key]
+	}
+
+	var delta model.Money
+	switch item.OperationType {
+	case "DEPOSIT":
+		if err := wallet.checkCredit(walletUUID); err != nil {
+			return err
+		}
+		delta = item.Amount
+	case "WITHDRAW":
+		if err := wallet.checkDebit(walletUUID); err != nil {
+			return err
+		}
+		held := state.held[key]
+		if item.Amount > currentBalance-held {
+			return &InsufficientFundsError{WalletId: walletUUID, Currency: item.Currency, Balance: currentBalance - held, Requested: item.Amount}
+		}
+		delta = -item.Amount
+	default:
+		return fmt.Errorf("%w: unknown operation type %s", ErrInvalidOperation, item.OperationType)
+	}
+
+	newBalance, err := addBalance(walletUUID, item.Currency, currentBalance, delta)
+	if err != nil {
+		return err
+	}
+
+	// системный счёт проверяется по каждой операции, а не по итогу пакета, как если бы они шли по одной
+	systemBalance, ok := writes.system[item.Currency]
+	if !ok {
+		systemBalance = state.system[item.Currency]
+	}
+	newSystemBalance, err := systemBalance.Add(-delta)
+	if err != nil {
+		return fmt.Errorf("account %s %s: %w: %v", r.systemAccountId, item.Currency, ErrBalanceOverflow, err)
+	}
+
+	// ключ, уже использованный операцией этого же пакета, занят, как если бы операции шли по одной
+	if idempotency != nil && writes.keys[idempotency.Key] {
+		return ErrIdempotencyKeyExists
+	}
+
+	transaction := &model.Transaction{
+		Id:            uuid.New().String(),
+		WalletId:      walletUUID,
+		OperationType: item.OperationType,
+		Currency:      item.Currency,
+		Amount:        item.Amount,
+		BalanceBefore: currentBalance,
+		BalanceAfter:  newBalance,
+	}
+	transaction.EntryId = &transaction.Id
+
+	writes.transactions = append(writes.transactions, transaction)
+	writes.entries = append(writes.entries, journalEntry{
+		id:            transaction.Id,
+		operationType: item.OperationType,
+		postings: []posting{
+			{accountId: walletUUID, currency: item.Currency, amount: delta},
+			{accountId: r.systemAccountId, currency: item.Currency, amount: -delta},
+		},
+	})
+	writes.idempotency = append(writes.idempotency, idempotency)
+	writes.indexes = append(writes.indexes, index)
+	writes.balances[key] = newBalance
+	writes.system[item.Currency] = newSystemBalance
+	if idempotency != nil {
+		writes.keys[idempotency.Key] = true
+	}
+	return nil
+}
+
+// Запись рассчитанных операций пакета: балансы, журнал двойной записи, журнал операций и ключи
+// идемпотентности, каждое одним запросом на весь пакет. Возвращает индексы операций в пакете,
+// ключи которых успел занять параллельный запрос - тогда транзакцию нужно откатить
+func (r *WalletRepo) writeBatch(ctx context.Context, tx pgx.Tx, writes *batchWrites) ([]int, error) {
+	if len(writes.transactions) == 0 {
+		return nil, nil
+	}
+
+	if err := setBalances(ctx, tx, writes.balances); err != nil {
+		return nil, err
+	}
+	if err := postEntries(ctx, tx, writes.entries...); err != nil {
+		return nil, err
+	}
+	if err := insertTransactions(ctx, tx, writes.transactions); err != nil {
+		return nil, err
+	}
+
+	var keys []*model.Idempotency
+	var transactionIds []string
+	var keyIndexes []int
+	for j, idempotency := range writes.idempotency {
+		if idempotency != nil {
+			keys = append(keys, idempotency)
+			transactionIds = append(transactionIds, writes.transactions[j].Id)
+			keyIndexes = append(keyIndexes, writes.indexes[j])
+		}
+	}
+	taken, err := r.saveIdempotencyKeys(ctx, tx, keys, transactionIds)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := make([]int, 0, len(taken))
+	for _, k := range taken {
+		conflicts = append(conflicts, keyIndexes[k])
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Колонки клиента API в порядке clientDest
+const clientColumns = `id, name, key_prefix, created_at, revoked_at`
+
+func clientDest(c *model.ApiClient) []any {
+	return []any{&c.Id, &c.Name, &c.KeyPrefix, &c.CreatedAt, &c.RevokedAt}
+}
+
+/*
+Создание клиента API
+
+Принимает:
+
+name string - имя клиента, например сервиса, которому выдаётся ключ
+
+keyHash string - SHA-256 ключа, сам ключ не хранится
+
+keyPrefix string - начало ключа, чтобы отличать ключи в списке
+
+Возвращает:
+
+client *model.ApiClient - созданный клиент
+
+error - error
+*/
+func (r *WalletRepo) CreateClient(ctx context.Context, name, keyHash, keyPrefix string) (*model.ApiClient, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var client model.ApiClient
+	err := r.DB.QueryRow(ctx, `
+        INSERT INTO api_clients (id, name, key_hash, key_prefix)
+        VALUES ($1, $2, $3, $4)
+        RETURNING `+clientColumns,
+		uuid.New().String(), name, keyHash, keyPrefix).Scan(clientDest(&client)...)
+	if err != nil {
+		return nil, dbError(err, "error creating api client")
+	}
+
+	r.logger.InfoContext(ctx, "API client created", "client_id", client.Id, "name", name)
+	return &client, nil
+}
+
+/*
+Активный (не отозванный) клиент по хешу ключа, для аутентификации запроса
+
+Принимает:
+
+keyHash string - SHA-256 ключа из запроса
+
+Возвращает:
+
+client *model.ApiClient - клиент, ErrClientNotFound если ключа нет или он отозван
+
+error - error
+*/
+func (r *WalletRepo) ClientByKeyHash(ctx context.Context, keyHash string) (*model.ApiClient, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var client model.ApiClient
+	err := r.DB.QueryRow(ctx, `
+        SELECT `+clientColumns+` FROM api_clients
+        WHERE key_hash = $1 AND revoked_at IS NULL`,
+		keyHash).Scan(clientDest(&client)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		return nil, dbError(err, "error getting api client")
+	}
+
+	return &client, nil
+}
+
+/*
+Активный клиент по id, для аутентификации по JWT: id клиента передаётся в sub
+
+Принимает:
+
+clientId string - id клиента
+
+Возвращает:
+
+client *model.ApiClient - клиент, ErrClientNotFound если его нет или ключ отозван
+
+error - error
+*/
+func (r *WalletRepo) ClientById(ctx context.Context, clientId string) (*model.ApiClient, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var client model.ApiClient
+	err := r.DB.QueryRow(ctx, `
+        SELECT `+clientColumns+` FROM api_clients
+        WHERE id = $1 AND revoked_at IS NULL`,
+		clientId).Scan(clientDest(&client)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		return nil, dbError(err, "error getting api client %s", clientId)
+	}
+
+	return &client, nil
+}
+
+// Все клиенты API, включая отозванных, от новых к старым
+func (r *WalletRepo) Clients(ctx context.Context) ([]model.ApiClient, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.Query(ctx, `
+        SELECT `+clientColumns+` FROM api_clients
+        ORDER BY created_at DESC, id`)
+	if err != nil {
+		return nil, dbError(err, "error getting api clients")
+	}
+	defer rows.Close()
+
+	clients := make([]model.ApiClient, 0)
+	for rows.Next() {
+		var client model.ApiClient
+		if err = rows.Scan(clientDest(&client)...); err != nil {
+			return nil, dbError(err, "error scanning api client")
+		}
+		clients = append(clients, client)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting api clients")
+	}
+
+	return clients, nil
+}
+
+/*
+Отзыв ключа клиента: с ним больше нельзя аутентифицироваться, кошельки клиента остаются за ним
+
+Принимает:
+
+clientId string - id клиента
+
+Возвращает:
+
+client *model.ApiClient - клиент с RevokedAt, повторный отзыв не меняет RevokedAt
+
+error - error
+*/
+func (r *WalletRepo) RevokeClient(ctx context.Context, clientId string) (*model.ApiClient, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var client model.ApiClient
+	err := r.DB.QueryRow(ctx, `
+        UPDATE api_clients SET revoked_at = COALESCE(revoked_at, now())
+        WHERE id = $1
+        RETURNING `+clientColumns,
+		clientId).Scan(clientDest(&client)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("client %s: %w", clientId, ErrClientNotFound)
+	}
+	if err != nil {
+		return nil, dbError(err, "error revoking api client %s", clientId)
+	}
+
+	r.logger.InfoContext(ctx, "API client revoked", "client_id", clientId)
+	return &client, nil
+}
+
+/*
+Владельцы кошельков, одним запросом для всех кошельков операции
+
+Принимает:
+
+walletUUIDs []string - UUID кошельков
+
+Возвращает:
+
+owners map[string]string - id клиента-владельца по UUID кошелька, пустая строка у кошелька
+без владельца. Несуществующих кошельков в map нет
+
+error - error
+*/
+func (r *WalletRepo) WalletOwners(ctx context.Context, walletUUIDs []string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.Query(ctx, `
+        SELECT uuid, COALESCE(owner_id::text, '') FROM wallets
+        WHERE uuid = ANY($1::uuid[])`,
+		walletUUIDs)
+	if err != nil {
+		return nil, dbError(err, "error getting wallet owners")
+	}
+	defer rows.Close()
+
+	owners := make(map[string]string, len(walletUUIDs))
+	for rows.Next() {
+		var walletUUID, owner string
+		if err = rows.Scan(&walletUUID, &owner); err != nil {
+			return nil, dbError(err, "error scanning wallet owner")
+		}
+		owners[walletUUID] = owner
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting wallet owners")
+	}
+
+	return owners, nil
+}
+
+/*
+Назначение владельца кошелька администратором
+
+Нужно прежде всего для кошельков, созданных до появления ключей: без владельца ими не может
+пользоваться ни один клиент. Владелец может быть и заменён, прежний попадает в ответ и в лог
+
+Принимает:
+
+walletUUID string - UUID кошелька
+
+clientId string - id нового владельца, клиент должен существовать и не быть отозван
+
+Возвращает:
+
+owner *model.WalletOwner - новый и прежний владелец
+
+error - error, ErrWalletNotFound или ErrClientNotFound
+*/
+func (r *WalletRepo) SetWalletOwner(ctx context.Context, walletUUID, clientId string) (*model.WalletOwner, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	// FOR SHARE: клиента нельзя отозвать, пока ему передаётся кошелёк
+	var active bool
+	err = tx.QueryRow(ctx, `
+        SELECT revoked_at IS NULL FROM api_clients
+        WHERE id = $1
+        FOR SHARE`,
+		clientId).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !active) {
+		return nil, fmt.Errorf("client %s: %w", clientId, ErrClientNotFound)
+	}
+	if err != nil {
+		return nil, dbError(err, "error checking api client %s", clientId)
+	}
+
+	owner := model.WalletOwner{WalletId: walletUUID, OwnerId: clientId}
+	err = tx.QueryRow(ctx, `
+        SELECT COALESCE(owner_id::text, '') FROM wallets
+        WHERE uuid = $1
+        FOR UPDATE`,
+		walletUUID).Scan(&owner.PreviousOwnerId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+	if err != nil {
+		return nil, dbError(err, "error locking wallet %s", walletUUID)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE wallets SET owner_id = $2
+        WHERE uuid = $1`,
+		walletUUID, clientId)
+	if err != nil {
+		return nil, dbError(err, "error setting wallet %s owner", walletUUID)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Wallet owner assigned", "wallet_id", walletUUID, "owner_id", clientId, "previous_owner_id", owner.PreviousOwnerId)
+	return &owner, nil
+}
+
+// Кошельки, которые затрагивает операция: один у DEPOSIT/WITHDRAW, оба у частей перевода
+func (r *WalletRepo) TransactionWallets(ctx context.Context, transactionId string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.Query(ctx, `
+        SELECT DISTINCT t.wallet_uuid FROM transactions t
+        JOIN transactions o ON o.id = $1
+        WHERE t.id = o.id OR (o.transfer_id IS NOT NULL AND t.transfer_id = o.transfer_id)`,
+		transactionId)
+	if err != nil {
+		return nil, dbError(err, "error getting wallets of transaction %s", transactionId)
+	}
+	defer rows.Close()
+
+	walletUUIDs := make([]string, 0, 2)
+	for rows.Next() {
+		var walletUUID string
+		if err = rows.Scan(&walletUUID); err != nil {
+			return nil, dbError(err, "error scanning wallet of transaction")
+		}
+		walletUUIDs = append(walletUUIDs, walletUUID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting wallets of transaction %s", transactionId)
+	}
+	if len(walletUUIDs) == 0 {
+		return nil, fmt.Errorf("transaction %s: %w", transactionId, ErrTransactionNotFound)
+	}
+
+	return walletUUIDs, nil
+}
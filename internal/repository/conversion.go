@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Колонки котировки в порядке quoteDest
+const quoteColumns = `id, client_id, from_currency, to_currency, from_amount, to_amount, rate, mid_rate, spread, expires_at, created_at`
+
+func quoteDest(q *model.Quote) []any {
+	return []any{&q.Id, &q.ClientId, &q.FromCurrency, &q.ToCurrency, &q.FromAmount, &q.ToAmount, &q.Rate, &q.MidRate, &q.Spread, &q.ExpiresAt, &q.CreatedAt}
+}
+
+/*
+Сохранение котировки обмена, курс по ней действует QuoteTTL
+
+Принимает:
+
+quote *model.Quote - рассчитанная котировка без Id, ExpiresAt и CreatedAt, их заполняет БД
+
+Возвращает:
+
+quote *model.Quote - сохранённая котировка
+
+error - error
+*/
+func (r *WalletRepo) CreateQuote(ctx context.Context, quote *model.Quote) (*model.Quote, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var saved model.Quote
+	err := r.DB.QueryRow(ctx, `
+        INSERT INTO quotes (id, client_id, from_currency, to_currency, from_amount, to_amount, rate, mid_rate, spread, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now() + make_interval(secs => $10))
+        RETURNING `+quoteColumns,
+		uuid.New().String(), quote.ClientId, quote.FromCurrency, quote.ToCurrency, quote.FromAmount, quote.ToAmount,
+		quote.Rate, quote.MidRate, quote.Spread, r.options.QuoteTTL.Seconds()).Scan(quoteDest(&saved)...)
+	if err != nil {
+		return nil, dbError(err, "error creating quote")
+	}
+
+	r.logger.InfoContext(ctx, "Quote created", "quote_id", saved.Id, "from_currency", saved.FromCurrency, "to_currency", saved.ToCurrency,
+		"rate", saved.Rate, "expires_at", saved.ExpiresAt)
+	return &saved, nil
+}
+
+/*
+Получение котировки по id, в том числе истёкшей или использованной
+
+Принимает:
+
+quoteId string - id котировки
+
+Возвращает:
+
+quote *model.Quote - котировка
+
+error - error, ErrQuoteNotFound если котировки нет
+*/
+func (r *WalletRepo) Quote(ctx context.Context, quoteId string) (*model.Quote, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var quote model.Quote
+	err := r.DB.QueryRow(ctx, `
+        SELECT `+quoteColumns+` FROM quotes
+        WHERE id = $1`,
+		quoteId).Scan(quoteDest(&quote)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("quote %s: %w", quoteId, ErrQuoteNotFound)
+	}
+	if err != nil {
+		return nil, dbError(err, "error getting quote %s", quoteId)
+	}
+
+	return &quote, nil
+}
+
+/*
+Обмен между балансами кошелька в одной транзакции
+
+Списывает quote.FromAmount в валюте FromCurrency и зачисляет quote.ToAmount в валюте ToCurrency.
+В журнал идёт одна запись: в каждой валюте кошелёк проводится против системного счёта обмена,
+так что на нём копится позиция по валютам и доход от спреда. Курс и спред сохраняются в conversions
+
+Принимает:
+
+walletUUID string - UUID кошелька
+
+quote *model.Quote - котировка. С Id она должна быть действующей и ещё не использованной,
+без Id - рассчитанная по текущему курсу и нигде не сохранённая
+
+Возвращает:
+
+result *model.ConversionResult - обе части обмена и курс
+
+error - error
+*/
+func (r *WalletRepo) Convert(ctx context.Context, walletUUID string, quote *model.Quote) (*model.ConversionResult, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.Convert",
+		attribute.String("wallet.id", walletUUID),
+		attribute.String("wallet.operation_type", "CONVERT"),
+		attribute.String("wallet.currency", quote.FromCurrency),
+		attribute.String("conversion.to_currency", quote.ToCurrency),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	// сначала кошелёк, потом котировка, как с холдами
+	state, err := lockWallet(ctx, tx, walletUUID)
+	if err != nil {
+		return nil, err
+	}
+	// обмен списывает с кошелька, а замороженному или закрытому списывать нельзя при любых зачислениях
+	if err = state.checkDebit(walletUUID); err != nil {
+		return nil, err
+	}
+
+	var quoteId *string
+	if quote.Id != "" {
+		if err = lockQuote(ctx, tx, quote.Id); err != nil {
+			return nil, err
+		}
+		quoteId = &quote.Id
+	}
+
+	fromBalance, _, err := lockBalance(ctx, tx, walletUUID, quote.FromCurrency)
+	if err != nil {
+		return nil, err
+	}
+	toBalance, _, err := lockBalance(ctx, tx, walletUUID, quote.ToCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	held, err := heldAmount(ctx, tx, walletUUID, quote.FromCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if quote.FromAmount > fromBalance-held {
+		return nil, &InsufficientFundsError{WalletId: walletUUID, Currency: quote.FromCurrency, Balance: fromBalance - held, Requested: quote.FromAmount}
+	}
+
+	conversionId := uuid.New().String()
+	result, err := newConversionResult(conversionId, walletUUID, quoteId, quote, fromBalance, toBalance)
+	if err != nil {
+		return nil, err
+	}
+
+	err = postEntry(ctx, tx, conversionId, "CONVERT",
+		posting{accountId: walletUUID, currency: quote.FromCurrency, amount: -quote.FromAmount},
+		posting{accountId: r.fxAccountId, currency: quote.FromCurrency, amount: quote.FromAmount},
+		posting{accountId: r.fxAccountId, currency: quote.ToCurrency, amount: -quote.ToAmount},
+		posting{accountId: walletUUID, currency: quote.ToCurrency, amount: quote.ToAmount})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO conversions (id, wallet_uuid, quote_id, from_currency, to_currency, from_amount, to_amount, rate, mid_rate, spread)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		conversionId, walletUUID, quoteId, quote.FromCurrency, quote.ToCurrency, quote.FromAmount, quote.ToAmount,
+		quote.Rate, quote.MidRate, quote.Spread)
+	if isQuoteUsedViolation(err) {
+		return nil, fmt.Errorf("quote %s: %w", quote.Id, ErrQuoteUsed)
+	}
+	if err != nil {
+		return nil, dbError(err, "error recording conversion %s", conversionId)
+	}
+
+	for _, t := range []*model.Transaction{&result.Debit, &result.Credit} {
+		if err = setBalance(ctx, tx, walletUUID, t.Currency, t.BalanceAfter); err != nil {
+			return nil, err
+		}
+		if err = insertTransaction(ctx, tx, t); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Currencies converted", "conversion_id", conversionId, "wallet_id", walletUUID,
+		"from_currency", quote.FromCurrency, "from_amount", quote.FromAmount, "to_currency", quote.ToCurrency, "to_amount", quote.ToAmount,
+		"rate", quote.Rate, "quote_id", quote.Id)
+	return result, nil
+}
+
+// Части обмена CONVERT_OUT и CONVERT_IN, связанные id обмена через EntryId,
+// ErrBalanceOverflow если зачисление не помещается в баланс
+func newConversionResult(conversionId, walletUUID string, quoteId *string, quote *model.Quote, fromBalance, toBalance model.Money) (*model.ConversionResult, error) {
+	toBalanceAfter, err := addBalance(walletUUID, quote.ToCurrency, toBalance, quote.ToAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ConversionResult{
+		ConversionId: conversionId,
+		QuoteId:      quoteId,
+		Rate:         quote.Rate,
+		MidRate:      quote.MidRate,
+		Spread:       quote.Spread,
+		Debit: model.Transaction{
+			Id:            uuid.New().String(),
+			WalletId:      walletUUID,
+			OperationType: "CONVERT_OUT",
+			Currency:      quote.FromCurrency,
+			Amount:        quote.FromAmount,
+			BalanceBefore: fromBalance,
+			BalanceAfter:  fromBalance - quote.FromAmount,
+			EntryId:       &conversionId,
+		},
+		Credit: model.Transaction{
+			Id:            uuid.New().String(),
+			WalletId:      walletUUID,
+			OperationType: "CONVERT_IN",
+			Currency:      quote.ToCurrency,
+			Amount:        quote.ToAmount,
+			BalanceBefore: toBalance,
+			BalanceAfter:  toBalanceAfter,
+			EntryId:       &conversionId,
+		},
+	}, nil
+}
+
+/*
+Блокировка котировки для обмена: она должна быть действующей и ещё не использованной
+
+Использование проверяется отдельным запросом уже после блокировки строки: в READ COMMITTED
+у каждого запроса свой снимок, а подзапрос в SELECT ... FOR UPDATE видел бы снимок до ожидания
+блокировки и не заметил бы обмен, закоммиченный параллельной транзакцией
+*/
+func lockQuote(ctx context.Context, tx pgx.Tx, quoteId string) error {
+	var expired bool
+	// срок сверяем по часам БД, как и у холдов
+	err := tx.QueryRow(ctx, `
+        SELECT expires_at <= now() FROM quotes
+        WHERE id = $1
+        FOR UPDATE`,
+		quoteId).Scan(&expired)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("quote %s: %w", quoteId, ErrQuoteNotFound)
+	}
+	if err != nil {
+		return dbError(err, "error locking quote %s", quoteId)
+	}
+
+	var used bool
+	err = tx.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM conversions WHERE quote_id = $1)`,
+		quoteId).Scan(&used)
+	if err != nil {
+		return dbError(err, "error checking quote %s usage", quoteId)
+	}
+
+	switch {
+	case used:
+		return fmt.Errorf("quote %s: %w", quoteId, ErrQuoteUsed)
+	case expired:
+		return fmt.Errorf("quote %s: %w", quoteId, ErrQuoteExpired)
+	}
+	return nil
+}
+
+// Нарушение уникальности quote_id в conversions: котировку уже использовал другой обмен.
+// После lockQuote такого быть не должно, это страховка, чтобы гонка не превратилась в 500
+func isQuoteUsedViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "conversions_quote_id_key"
+}
+
+/*
+Удаление давно истёкших котировок, по которым не было обмена, вызывается периодически из main
+
+Возвращает:
+
+deleted int64 - сколько котировок удалено
+
+error - error
+*/
+func (r *WalletRepo) PurgeQuotes(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// час после истечения котировка ещё видна, чтобы на неё отвечать QUOTE_EXPIRED, а не QUOTE_NOT_FOUND
+	tag, err := r.DB.Exec(ctx, `
+        DELETE FROM quotes q
+        WHERE q.expires_at < now() - interval '1 hour'
+          AND NOT EXISTS (SELECT 1 FROM conversions c WHERE c.quote_id = q.id)`)
+	if err != nil {
+		return 0, dbError(err, "error purging quotes")
+	}
+
+	return tag.RowsAffected(), nil
+}
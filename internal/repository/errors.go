@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Доменные ошибки репозитория, проверяются через errors.Is
+var (
+	ErrWalletNotFound      = errors.New("wallet not found")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrBalanceOverflow     = errors.New("balance overflow")
+	ErrWalletFrozen        = errors.New("wallet is frozen")
+	ErrWalletClosed        = errors.New("wallet is closed")
+	ErrWalletNotEmpty      = errors.New("wallet balance is not zero")
+	ErrInvalidTransition   = errors.New("invalid wallet status transition")
+	ErrInvalidOperation    = errors.New("invalid operation")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrTimeout             = errors.New("operation timed out")
+	ErrInvalidCursor       = errors.New("invalid cursor")
+	ErrHoldNotFound        = errors.New("hold not found")
+	ErrHoldNotActive       = errors.New("hold is not active")
+	ErrQuoteNotFound       = errors.New("quote not found")
+	ErrQuoteExpired        = errors.New("quote expired")
+	ErrQuoteUsed           = errors.New("quote is already used")
+
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrAlreadyReversed     = errors.New("transaction is already fully reversed")
+	ErrClientNotFound      = errors.New("api client not found")
+
+	// Ключ уже использован другой (возможно ещё не завершённой) операцией
+	ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+)
+
+// Недостаточно средств для списания, errors.Is(err, ErrInsufficientFunds) == true
+type InsufficientFundsError struct {
+	WalletId  string
+	Currency  string
+	Balance   model.Money
+	Requested model.Money
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("insufficient funds on wallet %s: have %d %s, need %d", e.WalletId, e.Balance, e.Currency, e.Requested)
+}
+
+func (e *InsufficientFundsError) Is(target error) bool {
+	return target == ErrInsufficientFunds
+}
+
+// Ошибка одной из операций атомарного пакета, весь пакет откатывается.
+// errors.Is и errors.As видят исходную ошибку операции
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("batch item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// SQLSTATE нарушения уникальности и выхода числа за пределы типа (переполнения BIGINT)
+const (
+	uniqueViolation   = "23505"
+	numericOutOfRange = "22003"
+)
+
+// Переполнение BIGINT в запросе
+func isOutOfRange(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == numericOutOfRange
+}
+
+/*
+Обёртка ошибок БД
+
+Истёкший таймаут превращается в ErrTimeout, pgx.ErrNoRows оборачивается без изменений:
+чьей строки нет, знает только вызывающий, он и переводит её в свою ошибку (ErrWalletNotFound, ErrHoldNotFound...).
+Остальные ошибки оборачиваются как есть
+
+Принимает:
+
+err error - ошибка pgx
+
+format string, args ...any - что делали, попадает в текст ошибки
+*/
+func dbError(err error, format string, args ...any) error {
+	action := fmt.Sprintf(format, args...)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err):
+		return fmt.Errorf("%s: %w", action, ErrTimeout)
+	case errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("%s: %w", action, err)
+	default:
+		return fmt.Errorf("%s: %v", action, err)
+	}
+}
+
+// Ошибка запроса строки кошелька: pgx.ErrNoRows превращается в ErrWalletNotFound, остальное - как в dbError
+func walletError(err error, walletUUID string, format string, args ...any) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+	return dbError(err, format, args...)
+}
@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Статусы холда
+const (
+	HoldActive   = "ACTIVE"
+	HoldCaptured = "CAPTURED"
+	HoldVoided   = "VOIDED"
+	HoldExpired  = "EXPIRED"
+)
+
+// Колонки холда в порядке holdDest
+const holdColumns = `id, wallet_uuid, currency, amount, captured_amount, status, expires_at, created_at, updated_at`
+
+func holdDest(h *model.Hold) []any {
+	return []any{&h.Id, &h.WalletId, &h.Currency, &h.Amount, &h.CapturedAmount, &h.Status, &h.ExpiresAt, &h.CreatedAt, &h.UpdatedAt}
+}
+
+/*
+Создание холда - резерв суммы на кошельке
+
+Баланс кошелька не меняется, уменьшается только доступный баланс. Холд истекает
+через HoldTTL, если его не подтвердили и не отменили
+
+Принимает:
+
+walletUUID string - UUID кошелька
+
+currency string - код валюты холда
+
+amount model.Money - резервируемая сумма, не больше доступного баланса в этой валюте
+
+Возвращает:
+
+hold *model.Hold - созданный холд
+
+error - error
+*/
+func (r *WalletRepo) CreateHold(ctx context.Context, walletUUID, currency string, amount model.Money) (*model.Hold, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.CreateHold",
+		attribute.String("wallet.id", walletUUID),
+		attribute.String("wallet.operation_type", "HOLD"),
+		attribute.String("wallet.currency", currency),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	balance, state, err := lockBalance(ctx, tx, walletUUID, currency)
+	if err != nil {
+		return nil, err
+	}
+	if err = state.checkDebit(walletUUID); err != nil {
+		return nil, err
+	}
+
+	held, err := heldAmount(ctx, tx, walletUUID, currency)
+	if err != nil {
+		return nil, err
+	}
+	if amount > balance-held {
+		return nil, &InsufficientFundsError{WalletId: walletUUID, Currency: currency, Balance: balance - held, Requested: amount}
+	}
+
+	var hold model.Hold
+	err = tx.QueryRow(ctx, `
+        INSERT INTO holds (id, wallet_uuid, currency, amount, expires_at)
+        VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5))
+        RETURNING `+holdColumns,
+		uuid.New().String(), walletUUID, currency, amount, r.options.HoldTTL.Seconds()).Scan(holdDest(&hold)...)
+	if err != nil {
+		return nil, dbError(err, "error creating hold on wallet %s", walletUUID)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Hold created", "hold_id", hold.Id, "wallet_id", walletUUID, "currency", currency, "amount", amount, "expires_at", hold.ExpiresAt)
+	return &hold, nil
+}
+
+/*
+Подтверждение холда - списание зарезервированной суммы полностью или частично
+
+Остаток холда после частичного подтверждения освобождается. Повторно подтвердить холд нельзя.
+
+Принимает:
+
+walletUUID string - UUID кошелька
+
+holdId string - id холда
+
+amount model.Money - списываемая сумма, 0 - вся сумма холда
+
+Возвращает:
+
+result *model.CaptureResult - холд после подтверждения и запись журнала о списании
+
+error - error
+*/
+func (r *WalletRepo) CaptureHold(ctx context.Context, walletUUID, holdId string, amount model.Money) (*model.CaptureResult, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.CaptureHold",
+		attribute.String("wallet.id", walletUUID),
+		attribute.String("hold.id", holdId),
+		attribute.String("wallet.operation_type", "CAPTURE"),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	// сначала кошелёк, потом холд - тот же порядок блокировок, что и везде
+	state, err := lockWallet(ctx, tx, walletUUID)
+	if err != nil {
+		return nil, err
+	}
+	// холд выдан до заморозки, но списание по нему - всё равно списание
+	if err = state.checkDebit(walletUUID); err != nil {
+		return nil, err
+	}
+
+	hold, err := lockActiveHold(ctx, tx, walletUUID, holdId)
+	if err != nil {
+		return nil, err
+	}
+
+	// кошелёк уже заблокирован, здесь только баланс в валюте холда
+	balance, _, err := lockBalance(ctx, tx, walletUUID, hold.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount > hold.Amount {
+		return nil, fmt.Errorf("%w: capture of %d exceeds hold %s amount %d", ErrInvalidOperation, amount, holdId, hold.Amount)
+	}
+
+	err = tx.QueryRow(ctx, `
+        UPDATE holds
+        SET status = $1, captured_amount = $2, updated_at = now()
+        WHERE id = $3
+        RETURNING `+holdColumns,
+		HoldCaptured, amount, holdId).Scan(holdDest(hold)...)
+	if err != nil {
+		return nil, dbError(err, "error capturing hold %s", holdId)
+	}
+
+	// холд уже гарантирует, что средств хватает: пока он активен, их нельзя было потратить
+	if err = setBalance(ctx, tx, walletUUID, hold.Currency, balance-amount); err != nil {
+		return nil, err
+	}
+
+	transactionId := uuid.New().String()
+	transaction := model.Transaction{
+		Id:            transactionId,
+		WalletId:      walletUUID,
+		OperationType: "CAPTURE",
+		Currency:      hold.Currency,
+		Amount:        amount,
+		BalanceBefore: balance,
+		BalanceAfter:  balance - amount,
+		HoldId:        &hold.Id,
+		EntryId:       &transactionId,
+	}
+	err = postEntry(ctx, tx, transactionId, "CAPTURE",
+		posting{accountId: walletUUID, currency: hold.Currency, amount: -amount},
+		posting{accountId: r.systemAccountId, currency: hold.Currency, amount: amount})
+	if err != nil {
+		return nil, err
+	}
+	if err = insertTransaction(ctx, tx, &transaction); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Hold captured", "hold_id", holdId, "wallet_id", walletUUID,
+		"currency", hold.Currency, "amount", amount, "hold_amount", hold.Amount, "balance", balance-amount)
+	return &model.CaptureResult{Hold: *hold, Transaction: transaction}, nil
+}
+
+/*
+Отмена холда - зарезервированная сумма снова становится доступной
+
+Принимает:
+
+walletUUID string - UUID кошелька
+
+holdId string - id холда
+
+Возвращает:
+
+hold *model.Hold - холд после отмены
+
+error - error
+*/
+func (r *WalletRepo) VoidHold(ctx context.Context, walletUUID, holdId string) (*model.Hold, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.VoidHold",
+		attribute.String("wallet.id", walletUUID),
+		attribute.String("hold.id", holdId),
+		attribute.String("wallet.operation_type", "VOID"),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	state, err := lockWallet(ctx, tx, walletUUID)
+	if err != nil {
+		return nil, err
+	}
+	// отмена только освобождает резерв, замороженному кошельку она не запрещена
+	if err = state.checkOpen(walletUUID); err != nil {
+		return nil, err
+	}
+
+	hold, err := lockActiveHold(ctx, tx, walletUUID, holdId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(ctx, `
+        UPDATE holds
+        SET status = $1, updated_at = now()
+        WHERE id = $2
+        RETURNING `+holdColumns,
+		HoldVoided, holdId).Scan(holdDest(hold)...)
+	if err != nil {
+		return nil, dbError(err, "error voiding hold %s", holdId)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Hold voided", "hold_id", holdId, "wallet_id", walletUUID, "released", hold.Amount)
+	return hold, nil
+}
+
+/*
+Перевод просроченных холдов в статус EXPIRED, вызывается периодически из main
+
+На доступный баланс не влияет - просроченные холды и так не учитываются,
+статус нужен, чтобы по холду было видно, чем он закончился
+
+Возвращает:
+
+expired int64 - сколько холдов истекло
+
+error - error
+*/
+func (r *WalletRepo) ExpireHolds(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := r.DB.Exec(ctx, `
+        UPDATE holds
+        SET status = $1, updated_at = now()
+        WHERE status = $2 AND expires_at <= now()`,
+		HoldExpired, HoldActive)
+	if err != nil {
+		return 0, dbError(err, "error expiring holds")
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Блокировка холда для подтверждения или отмены, холд должен быть активным и непросроченным
+func lockActiveHold(ctx context.Context, tx pgx.Tx, walletUUID, holdId string) (*model.Hold, error) {
+	var hold model.Hold
+	var expired bool
+	// срок сверяем по часам БД, как и в heldAmount
+	err := tx.QueryRow(ctx, `
+        SELECT `+holdColumns+`, expires_at <= now() FROM holds
+        WHERE id = $1 AND wallet_uuid = $2
+        FOR UPDATE`,
+		holdId, walletUUID).Scan(append(holdDest(&hold), &expired)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("hold %s on wallet %s: %w", holdId, walletUUID, ErrHoldNotFound)
+	}
+	if err != nil {
+		return nil, dbError(err, "error getting hold %s", holdId)
+	}
+
+	if hold.Status == HoldActive && expired {
+		hold.Status = HoldExpired
+	}
+	if hold.Status != HoldActive {
+		return nil, fmt.Errorf("hold %s is %s: %w", holdId, hold.Status, ErrHoldNotActive)
+	}
+
+	return &hold, nil
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+/*
+Поиск операции, уже выполненной с этим ключом идемпотентности
+
+Ключи старше idempotencyTTL не учитываются, как будто их нет. Ключи других клиентов не видны.
+
+Принимает:
+
+clientId string - клиент API, которому принадлежит ключ
+
+key string - ключ идемпотентности
+
+Возвращает:
+
+requestHash string - хеш тела запроса, с которым ключ был использован
+
+transaction *model.Transaction - операция, выполненная с этим ключом, nil если ключа нет
+
+error - error
+*/
+func (r *WalletRepo) IdempotentTransaction(ctx context.Context, clientId, key string) (string, *model.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var requestHash string
+	var t model.Transaction
+	err := r.DB.QueryRow(ctx, `
+        WITH k AS (
+            SELECT request_hash, transaction_id FROM idempotency_keys
+            WHERE client_id = $1 AND key = $2
+              AND created_at > now() - make_interval(secs => $3)
+        )
+        SELECT k.request_hash, `+transactionColumns+`
+        FROM k
+        JOIN transactions ON transactions.id = k.transaction_id`,
+		clientId, key, r.options.IdempotencyTTL.Seconds()).Scan(append([]any{&requestHash}, transactionDest(&t)...)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, dbError(err, "error getting idempotency key %s", key)
+	}
+
+	return requestHash, &t, nil
+}
+
+// Операция, выполненная с ключом идемпотентности, и хеш тела запроса, с которым ключ был использован
+type IdempotentRecord struct {
+	RequestHash string
+	Transaction model.Transaction
+}
+
+/*
+Поиск операций, уже выполненных с этими ключами, одним запросом - для пакетных операций
+
+Принимает:
+
+clientId string - клиент API, которому принадлежат ключи
+
+keys []string - ключи идемпотентности
+
+Возвращает:
+
+records map[string]IdempotentRecord - найденные операции по ключам, ключей без операций в нём нет
+
+error - error
+*/
+func (r *WalletRepo) IdempotentTransactions(ctx context.Context, clientId string, keys []string) (map[string]IdempotentRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	records := make(map[string]IdempotentRecord)
+	if len(keys) == 0 {
+		return records, nil
+	}
+
+	rows, err := r.DB.Query(ctx, `
+        WITH k AS (
+            SELECT key, request_hash, transaction_id FROM idempotency_keys
+            WHERE client_id = $1 AND key = ANY($2)
+              AND created_at > now() - make_interval(secs => $3)
+        )
+        SELECT k.key, k.request_hash, `+transactionColumns+`
+        FROM k
+        JOIN transactions ON transactions.id = k.transaction_id`,
+		clientId, keys, r.options.IdempotencyTTL.Seconds())
+	if err != nil {
+		return nil, dbError(err, "error getting idempotency keys")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var record IdempotentRecord
+		err = rows.Scan(append([]any{&key, &record.RequestHash}, transactionDest(&record.Transaction)...)...)
+		if err != nil {
+			return nil, dbError(err, "error scanning idempotency key")
+		}
+		records[key] = record
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting idempotency keys")
+	}
+
+	return records, nil
+}
+
+// Сохраняет ключ клиента внутри транзакции операции. Просроченный ключ перезаписывается,
+// живой - нет: параллельный запрос с тем же ключом ждёт коммита первого и получает ErrIdempotencyKeyExists
+func (r *WalletRepo) saveIdempotencyKey(ctx context.Context, tx pgx.Tx, idempotency *model.Idempotency, transactionId string) error {
+	tag, err := tx.Exec(ctx, `
+        INSERT INTO idempotency_keys (client_id, key, request_hash, transaction_id)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (client_id, key) DO UPDATE
+        SET request_hash = EXCLUDED.request_hash,
+            transaction_id = EXCLUDED.transaction_id,
+            created_at = now()
+        WHERE idempotency_keys.created_at <= now() - make_interval(secs => $5)`,
+		idempotency.ClientId, idempotency.Key, idempotency.RequestHash, transactionId, r.options.IdempotencyTTL.Seconds())
+	if err != nil {
+		return dbError(err, "error saving idempotency key %s", idempotency.Key)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrIdempotencyKeyExists
+	}
+
+	return nil
+}
+
+// Как saveIdempotencyKey, но для ключей пакета одним запросом. Ключи в keys не повторяются.
+// Возвращает индексы в keys тех ключей, которые уже заняты живыми ключами других запросов
+func (r *WalletRepo) saveIdempotencyKeys(ctx context.Context, tx pgx.Tx, keys []*model.Idempotency, transactionIds []string) ([]int, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	clientIds := make([]string, 0, len(keys))
+	names := make([]string, 0, len(keys))
+	requestHashes := make([]string, 0, len(keys))
+	for _, idempotency := range keys {
+		clientIds = append(clientIds, idempotency.ClientId)
+		names = append(names, idempotency.Key)
+		requestHashes = append(requestHashes, idempotency.RequestHash)
+	}
+
+	rows, err := tx.Query(ctx, `
+        INSERT INTO idempotency_keys (client_id, key, request_hash, transaction_id)
+        SELECT client_id, key, request_hash, transaction_id
+        FROM unnest($1::uuid[], $2::text[], $3::text[], $4::uuid[]) AS k (client_id, key, request_hash, transaction_id)
+        ON CONFLICT (client_id, key) DO UPDATE
+        SET request_hash = EXCLUDED.request_hash,
+            transaction_id = EXCLUDED.transaction_id,
+            created_at = now()
+        WHERE idempotency_keys.created_at <= now() - make_interval(secs => $5)
+        RETURNING client_id, key`,
+		clientIds, names, requestHashes, transactionIds, r.options.IdempotencyTTL.Seconds())
+	if err != nil {
+		return nil, dbError(err, "error saving %d idempotency keys", len(keys))
+	}
+	defer rows.Close()
+
+	saved := make(map[[2]string]bool, len(keys))
+	for rows.Next() {
+		var clientId, key string
+		if err = rows.Scan(&clientId, &key); err != nil {
+			return nil, dbError(err, "error scanning saved idempotency key")
+		}
+		saved[[2]string{clientId, key}] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error saving %d idempotency keys", len(keys))
+	}
+
+	var taken []int
+	for i, idempotency := range keys {
+		if !saved[[2]string{idempotency.ClientId, idempotency.Key}] {
+			taken = append(taken, i)
+		}
+	}
+	return taken, nil
+}
+
+/*
+Удаление просроченных ключей идемпотентности, вызывается периодически из main
+
+Возвращает:
+
+deleted int64 - сколько ключей удалено
+
+error - error
+*/
+func (r *WalletRepo) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := r.DB.Exec(ctx, `
+        DELETE FROM idempotency_keys
+        WHERE created_at <= now() - make_interval(secs => $1)`,
+		r.options.IdempotencyTTL.Seconds())
+	if err != nil {
+		return 0, dbError(err, "error purging idempotency keys")
+	}
+
+	return tag.RowsAffected(), nil
+}
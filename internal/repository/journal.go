@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Проводка по счёту в валюте: amount со знаком, плюс увеличивает баланс счёта, минус уменьшает
+type posting struct {
+	accountId string
+	currency  string
+	amount    model.Money
+}
+
+/*
+Инициализация плана счетов, вызывается один раз при старте из main
+
+Создаёт системные счета с кодами Options.SystemAccount и Options.FXAccount, если их ещё нет,
+и запоминает их id: против первого проводятся DEPOSIT, WITHDRAW и подтверждение холдов,
+против второго - обмен валют
+
+Возвращает:
+
+error - error
+*/
+func (r *WalletRepo) InitAccounts(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var err error
+	if r.systemAccountId, err = r.initSystemAccount(ctx, r.options.SystemAccount); err != nil {
+		return err
+	}
+	if r.fxAccountId, err = r.initSystemAccount(ctx, r.options.FXAccount); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Системный счёт по коду, создаётся, если его ещё нет
+func (r *WalletRepo) initSystemAccount(ctx context.Context, code string) (string, error) {
+	_, err := r.DB.Exec(ctx, `
+        INSERT INTO accounts (id, kind, code)
+        VALUES ($1, 'SYSTEM', $2)
+        ON CONFLICT (code) DO NOTHING`,
+		uuid.New().String(), code)
+	if err != nil {
+		return "", dbError(err, "error creating system account %s", code)
+	}
+
+	var accountId string
+	err = r.DB.QueryRow(ctx, `
+        SELECT id FROM accounts
+        WHERE code = $1`,
+		code).Scan(&accountId)
+	if err != nil {
+		return "", dbError(err, "error getting system account %s", code)
+	}
+
+	r.logger.InfoContext(ctx, "System account initialized", "code", code, "account_id", accountId)
+	return accountId, nil
+}
+
+// Запись журнала с её проводками, для postEntries
+type journalEntry struct {
+	id            string
+	operationType string
+	postings      []posting
+}
+
+// Запись в журнал двойной записи внутри уже открытой транзакции, см. postEntries
+func postEntry(ctx context.Context, tx pgx.Tx, entryId, operationType string, postings ...posting) error {
+	return postEntries(ctx, tx, journalEntry{id: entryId, operationType: operationType, postings: postings})
+}
+
+/*
+Запись в журнал двойной записи внутри уже открытой транзакции, все записи - тремя запросами,
+сколько бы их ни было
+
+Сумма проводок каждой записи в каждой валюте должна быть равна нулю. В БД это проверяет отложенный триггер
+при COMMIT, здесь проверяем заранее, чтобы не гонять заведомо несбалансированную запись.
+
+Балансы системных счетов ведутся в account_balances: баланс кошелька ограничен BIGINT в wallet_balances,
+а через системный счёт проходят деньги всех кошельков, и без этой проверки его баланс мог бы
+выйти за int64. Такие проводки отклоняются с ErrBalanceOverflow
+
+Принимает:
+
+entries ...journalEntry - записи журнала с проводками
+*/
+func postEntries(ctx context.Context, tx pgx.Tx, entries ...journalEntry) error {
+	entryIds := make([]string, 0, len(entries))
+	operationTypes := make([]string, 0, len(entries))
+	var postingEntryIds, accountIds, currencies []string
+	var amounts []int64
+	for _, entry := range entries {
+		if err := checkBalanced(entry.id, entry.postings); err != nil {
+			return err
+		}
+		entryIds = append(entryIds, entry.id)
+		operationTypes = append(operationTypes, entry.operationType)
+		for _, p := range entry.postings {
+			postingEntryIds = append(postingEntryIds, entry.id)
+			accountIds = append(accountIds, p.accountId)
+			currencies = append(currencies, p.currency)
+			amounts = append(amounts, int64(p.amount))
+		}
+	}
+	// в ошибках id записи, если она одна, иначе число записей
+	entriesName := fmt.Sprintf("%d journal entries", len(entries))
+	if len(entries) == 1 {
+		entriesName = "journal entry " + entries[0].id
+	}
+
+	_, err := tx.Exec(ctx, `
+        INSERT INTO journal_entries (id, operation_type)
+        SELECT id, operation_type
+        FROM unnest($1::uuid[], $2::text[]) AS e (id, operation_type)`,
+		entryIds, operationTypes)
+	if err != nil {
+		return dbError(err, "error recording %s", entriesName)
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO journal_postings (entry_id, account_id, currency, amount)
+        SELECT entry_id, account_id, currency, amount
+        FROM unnest($1::uuid[], $2::uuid[], $3::text[], $4::bigint[]) AS p (entry_id, account_id, currency, amount)`,
+		postingEntryIds, accountIds, currencies, amounts)
+	if err != nil {
+		return dbError(err, "error recording postings of %s", entriesName)
+	}
+
+	// строки блокируются по возрастанию счёта и валюты, поэтому записи с несколькими
+	// системными проводками (обмен валют, пакеты) не приводят к дедлокам
+	_, err = tx.Exec(ctx, `
+        INSERT INTO account_balances (account_id, currency, balance)
+        SELECT p.account_id, p.currency, SUM(p.amount)
+        FROM unnest($1::uuid[], $2::text[], $3::bigint[]) AS p (account_id, currency, amount)
+        JOIN accounts a ON a.id = p.account_id AND a.kind = 'SYSTEM'
+        GROUP BY p.account_id, p.currency
+        ORDER BY p.account_id, p.currency
+        ON CONFLICT (account_id, currency) DO UPDATE
+        SET balance = account_balances.balance + EXCLUDED.balance`,
+		accountIds, currencies, amounts)
+	if isOutOfRange(err) {
+		return fmt.Errorf("%s: %w: system account balance out of int64", entriesName, ErrBalanceOverflow)
+	}
+	if err != nil {
+		return dbError(err, "error updating system account balances for %s", entriesName)
+	}
+
+	return nil
+}
+
+// Проверка, что сумма проводок записи в каждой валюте равна нулю
+func checkBalanced(entryId string, postings []posting) error {
+	sums := make(map[string]model.Money, 1)
+	for _, p := range postings {
+		sums[p.currency] += p.amount
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return fmt.Errorf("journal entry %s is not balanced: sum of %s postings is %d", entryId, currency, sum)
+		}
+	}
+	return nil
+}
+
+/*
+Сверка журнала для финансов
+
+Балансы системных счетов, сумма по всем кошелькам и проверки по каждой валюте: сумма всех
+проводок равна нулю и баланс каждого кошелька совпадает с суммой его проводок
+
+Возвращает:
+
+summary *model.LedgerSummary - сводка по журналу
+
+error - error
+*/
+func (r *WalletRepo) LedgerSummary(ctx context.Context) (*model.LedgerSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.Query(ctx, `
+        SELECT a.code, p.currency, SUM(p.amount)
+        FROM accounts a
+        JOIN journal_postings p ON p.account_id = a.id
+        WHERE a.kind = 'SYSTEM'
+        GROUP BY a.code, p.currency
+        ORDER BY a.code, p.currency`)
+	if err != nil {
+		return nil, dbError(err, "error getting system accounts balances")
+	}
+	defer rows.Close()
+
+	summary := &model.LedgerSummary{SystemAccounts: []model.LedgerAccount{}}
+	totals := make(map[string]*model.LedgerCurrency)
+	total := func(currency string) *model.LedgerCurrency {
+		if _, ok := totals[currency]; !ok {
+			totals[currency] = &model.LedgerCurrency{Currency: currency}
+		}
+		return totals[currency]
+	}
+
+	// баланс каждого системного счёта помещается в BIGINT: postEntry ведёт его в account_balances
+	// и отклоняет проводку, которая вывела бы его за int64
+	for rows.Next() {
+		var account model.LedgerAccount
+		if err = rows.Scan(&account.Code, &account.Currency, &account.Balance); err != nil {
+			return nil, dbError(err, "error scanning system account balance")
+		}
+		summary.SystemAccounts = append(summary.SystemAccounts, account)
+		total(account.Currency).Total = total(account.Currency).Total.Add(account.Balance)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting system accounts balances")
+	}
+
+	// балансы кошельков сравниваются с проводками по парам (кошелёк, валюта) с обеих сторон:
+	// проводки без строки баланса - тоже расхождение
+	rows, err = r.DB.Query(ctx, `
+        WITH posted AS (
+            SELECT p.account_id, p.currency, SUM(p.amount) AS balance
+            FROM journal_postings p
+            JOIN accounts a ON a.id = p.account_id
+            WHERE a.kind = 'WALLET'
+            GROUP BY p.account_id, p.currency
+        )
+        SELECT COALESCE(b.currency, p.currency),
+               COALESCE(SUM(p.balance), 0)::text,
+               COUNT(*) FILTER (WHERE COALESCE(b.balance, 0) <> COALESCE(p.balance, 0))
+        FROM wallet_balances b
+        FULL JOIN posted p ON p.account_id = b.wallet_uuid AND p.currency = b.currency
+        GROUP BY 1
+        ORDER BY 1`)
+	if err != nil {
+		return nil, dbError(err, "error getting wallets totals")
+	}
+	defer rows.Close()
+
+	// сумма по всем кошелькам может не поместиться в BIGINT, поэтому читается как NUMERIC в тексте
+	for rows.Next() {
+		var currency, walletsTotal string
+		var mismatched int64
+		if err = rows.Scan(&currency, &walletsTotal, &mismatched); err != nil {
+			return nil, dbError(err, "error scanning wallets total")
+		}
+		if total(currency).WalletsTotal, err = model.ParseMoneyTotal(walletsTotal); err != nil {
+			return nil, fmt.Errorf("error scanning wallets total: %v", err)
+		}
+		summary.MismatchedWallets += mismatched
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting wallets totals")
+	}
+
+	summary.Currencies, summary.Balanced = ledgerTotals(totals, summary.MismatchedWallets)
+	return summary, nil
+}
+
+// Итоги по валютам в порядке кодов; журнал сбалансирован, если сумма в каждой валюте 0 и расхождений нет
+func ledgerTotals(totals map[string]*model.LedgerCurrency, mismatched int64) ([]model.LedgerCurrency, bool) {
+	currencies := make([]model.LedgerCurrency, 0, len(totals))
+	balanced := mismatched == 0
+	for _, total := range totals {
+		total.Total = total.Total.Plus(total.WalletsTotal)
+		balanced = balanced && total.Total.IsZero()
+		currencies = append(currencies, *total)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Currency < currencies[j].Currency })
+	return currencies, balanced
+}
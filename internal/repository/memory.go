@@ -0,0 +1,1407 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Валюты MemoryStore, те же, что добавляет migrations/10_add_currencies.up.sql
+var memoryCurrencies = map[string]int{"USD": 2, "EUR": 2, "GBP": 2, "RUB": 2, "JPY": 0, "USDT": 6, "BTC": 8}
+
+// Кошелёк в памяти, mu - аналог блокировки строки кошелька FOR UPDATE
+type memWallet struct {
+	mu       sync.Mutex
+	balances map[string]model.Money // по коду валюты
+	state    walletState
+	info     model.Wallet // внешний id владельца, название, metadata и labels, статус - в state
+	owner    string       // под MemoryStore.mu, а не mu: меняется только администратором
+}
+
+// Счёт журнала в одной валюте: UUID кошелька или код системного счёта
+type memAccount struct {
+	id       string
+	currency string
+}
+
+// Ключ идемпотентности вместе с клиентом, как первичный ключ idempotency_keys
+type memKey struct {
+	clientId string
+	key      string
+}
+
+type memIdempotencyKey struct {
+	requestHash   string
+	transactionId string
+	createdAt     time.Time
+}
+
+/*
+Хранилище кошельков в памяти с той же семантикой, что и WalletRepo, для тестов обработчиков без Postgres
+
+Операции над кошельком идут под его мьютексом, несколько кошельков блокируются в порядке
+возрастания UUID, как в Postgres. Изменения операции копятся в memTx и применяются разом
+при commit, поэтому операция с ошибкой ничего не меняет. Порядок блокировок: сначала
+мьютексы кошельков, потом mu, никогда наоборот
+*/
+type MemoryStore struct {
+	options Options
+
+	mu           sync.RWMutex // защищает всё ниже, кроме балансов - они под мьютексами кошельков
+	wallets      map[string]*memWallet
+	transactions []model.Transaction
+	byId         map[string]int             // индекс записи в transactions
+	posted       map[memAccount]model.Money // балансы счетов журнала двойной записи по валютам
+	holds        map[string]model.Hold
+	keys         map[memKey]memIdempotencyKey
+	clients      map[string]memClient // по id клиента
+	quotes       map[string]model.Quote
+	usedQuotes   map[string]string // id котировки -> id обмена по ней
+	statusLog    []model.WalletStatusChange
+}
+
+type memClient struct {
+	client  model.ApiClient
+	keyHash string
+}
+
+// Конструктор MemoryStore
+func NewMemoryStore(options Options) *MemoryStore {
+	if options.SystemAccount == "" {
+		options.SystemAccount = "SYSTEM_CASH"
+	}
+	if options.FXAccount == "" {
+		options.FXAccount = "SYSTEM_FX"
+	}
+
+	return &MemoryStore{
+		options:    options,
+		wallets:    make(map[string]*memWallet),
+		byId:       make(map[string]int),
+		posted:     make(map[memAccount]model.Money),
+		holds:      make(map[string]model.Hold),
+		keys:       make(map[memKey]memIdempotencyKey),
+		clients:    make(map[string]memClient),
+		quotes:     make(map[string]model.Quote),
+		usedQuotes: make(map[string]string),
+	}
+}
+
+// Транзакция в памяти: заблокированные кошельки и изменения, которые применятся при commit
+type memTx struct {
+	s            *MemoryStore
+	locked       map[string]*memWallet
+	balances     map[memAccount]model.Money
+	transactions []model.Transaction
+	postings     []posting
+	posted       map[memAccount]model.Money // балансы счетов журнала с учётом postings
+	holds        map[string]model.Hold
+	keys         map[memKey]memIdempotencyKey
+	usedQuotes   map[string]string
+	states       map[string]walletState
+	statusLog    []model.WalletStatusChange
+}
+
+func (s *MemoryStore) begin() *memTx {
+	return &memTx{
+		s:          s,
+		locked:     make(map[string]*memWallet),
+		balances:   make(map[memAccount]model.Money),
+		posted:     make(map[memAccount]model.Money),
+		holds:      make(map[string]model.Hold),
+		keys:       make(map[memKey]memIdempotencyKey),
+		usedQuotes: make(map[string]string),
+		states:     make(map[string]walletState),
+	}
+}
+
+// Аналог lockWallet: блокирует кошелёк до release
+func (tx *memTx) lock(walletUUID string) error {
+	walletUUID = strings.ToLower(walletUUID)
+	if _, ok := tx.locked[walletUUID]; ok {
+		return nil
+	}
+
+	tx.s.mu.RLock()
+	wallet, ok := tx.s.wallets[walletUUID]
+	tx.s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+
+	wallet.mu.Lock()
+	tx.locked[walletUUID] = wallet
+	return nil
+}
+
+// Аналог lockBalance: блокирует кошелёк и возвращает его баланс в валюте с учётом изменений транзакции
+func (tx *memTx) lockBalance(walletUUID, currency string) (model.Money, error) {
+	if err := tx.lock(walletUUID); err != nil {
+		return 0, err
+	}
+	if _, ok := memoryCurrencies[currency]; !ok {
+		return 0, fmt.Errorf("currency %s: %w", currency, ErrUnsupportedCurrency)
+	}
+	return tx.balance(strings.ToLower(walletUUID), currency), nil
+}
+
+// Снимает блокировки, вызывается через defer, как Rollback
+func (tx *memTx) release() {
+	for _, wallet := range tx.locked {
+		wallet.mu.Unlock()
+	}
+	tx.locked = nil
+}
+
+func (tx *memTx) balance(walletUUID, currency string) model.Money {
+	if balance, ok := tx.balances[memAccount{walletUUID, currency}]; ok {
+		return balance
+	}
+	return tx.locked[walletUUID].balances[currency]
+}
+
+// Статус заблокированного кошелька с учётом изменений транзакции
+func (tx *memTx) state(walletUUID string) walletState {
+	if state, ok := tx.states[walletUUID]; ok {
+		return state
+	}
+	return tx.locked[walletUUID].state
+}
+
+func (tx *memTx) setBalance(walletUUID, currency string, balance model.Money) {
+	tx.balances[memAccount{walletUUID, currency}] = balance
+}
+
+// Холд с учётом изменений транзакции
+func (tx *memTx) hold(holdId string) (model.Hold, bool) {
+	if hold, ok := tx.holds[holdId]; ok {
+		return hold, true
+	}
+
+	tx.s.mu.RLock()
+	defer tx.s.mu.RUnlock()
+	hold, ok := tx.s.holds[holdId]
+	return hold, ok
+}
+
+// Аналог heldAmount, вызывать под блокировкой кошелька
+func (tx *memTx) heldAmount(walletUUID, currency string) model.Money {
+	tx.s.mu.RLock()
+	defer tx.s.mu.RUnlock()
+	return tx.s.heldAmounts(walletUUID, tx.holds)[currency]
+}
+
+// Суммы активных непросроченных холдов кошелька по валютам, staged - изменённые, но ещё не применённые холды
+func (s *MemoryStore) heldAmounts(walletUUID string, staged map[string]model.Hold) map[string]model.Money {
+	now := time.Now()
+	held := make(map[string]model.Money)
+	for id, hold := range s.holds {
+		if stagedHold, ok := staged[id]; ok {
+			hold = stagedHold
+		}
+		if hold.WalletId == walletUUID && hold.Status == HoldActive && hold.ExpiresAt.After(now) {
+			held[hold.Currency] += hold.Amount
+		}
+	}
+	for id, hold := range staged {
+		if _, ok := s.holds[id]; !ok && hold.WalletId == walletUUID && hold.Status == HoldActive {
+			held[hold.Currency] += hold.Amount
+		}
+	}
+	return held
+}
+
+// Как postEntries: проводка, после которой баланс счёта журнала не помещается в int64, отклоняется
+// сразу, чтобы в пакете ошибку получила именно эта операция. При commit это проверяется ещё раз
+func (tx *memTx) postEntry(entryId string, postings ...posting) error {
+	if err := checkBalanced(entryId, postings); err != nil {
+		return err
+	}
+
+	tx.s.mu.RLock()
+	defer tx.s.mu.RUnlock()
+	next := make(map[memAccount]model.Money, len(postings))
+	for _, p := range postings {
+		account := memAccount{p.accountId, p.currency}
+		balance, ok := next[account]
+		if !ok {
+			balance, ok = tx.posted[account]
+		}
+		if !ok {
+			balance = tx.s.posted[account]
+		}
+		balance, err := balance.Add(p.amount)
+		if err != nil {
+			return fmt.Errorf("account %s %s: %w: %v", p.accountId, p.currency, ErrBalanceOverflow, err)
+		}
+		next[account] = balance
+	}
+
+	for account, balance := range next {
+		tx.posted[account] = balance
+	}
+	tx.postings = append(tx.postings, postings...)
+	return nil
+}
+
+func (tx *memTx) insertTransaction(t *model.Transaction) {
+	t.CreatedAt = time.Now()
+	tx.transactions = append(tx.transactions, *t)
+}
+
+// Аналог saveIdempotencyKey: живой ключ не перезаписывается
+func (tx *memTx) saveIdempotencyKey(idempotency *model.Idempotency, transactionId string) error {
+	key := memKey{clientId: idempotency.ClientId, key: idempotency.Key}
+	if _, ok := tx.keys[key]; ok {
+		return ErrIdempotencyKeyExists
+	}
+
+	tx.s.mu.RLock()
+	_, exists := tx.s.liveKey(key)
+	tx.s.mu.RUnlock()
+	if exists {
+		return ErrIdempotencyKeyExists
+	}
+
+	tx.keys[key] = memIdempotencyKey{
+		requestHash:   idempotency.RequestHash,
+		transactionId: transactionId,
+		createdAt:     time.Now(),
+	}
+	return nil
+}
+
+func (s *MemoryStore) liveKey(key memKey) (memIdempotencyKey, bool) {
+	record, ok := s.keys[key]
+	if !ok || !record.createdAt.After(time.Now().Add(-s.options.IdempotencyTTL)) {
+		return memIdempotencyKey{}, false
+	}
+	return record, true
+}
+
+// Применяет изменения транзакции. Ключи и котировки проверяются ещё раз: параллельная операция
+// на другом кошельке могла их занять, в Postgres её бы остановила блокировка или уникальный индекс
+func (tx *memTx) commit() error {
+	s := tx.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range tx.keys {
+		if _, exists := s.liveKey(key); exists {
+			return ErrIdempotencyKeyExists
+		}
+	}
+	for quoteId := range tx.usedQuotes {
+		if _, used := s.usedQuotes[quoteId]; used {
+			return fmt.Errorf("quote %s: %w", quoteId, ErrQuoteUsed)
+		}
+	}
+
+	// как account_balances в Postgres: проводка, после которой баланс счёта журнала не помещается
+	// в int64, отклоняется. Для кошельков это уже проверено, остаются системные счета
+	posted := make(map[memAccount]model.Money, len(tx.postings))
+	for _, p := range tx.postings {
+		account := memAccount{p.accountId, p.currency}
+		balance, ok := posted[account]
+		if !ok {
+			balance = s.posted[account]
+		}
+		balance, err := balance.Add(p.amount)
+		if err != nil {
+			return fmt.Errorf("account %s %s: %w: %v", p.accountId, p.currency, ErrBalanceOverflow, err)
+		}
+		posted[account] = balance
+	}
+
+	for account, balance := range tx.balances {
+		tx.locked[account.id].balances[account.currency] = balance
+	}
+	for walletUUID, state := range tx.states {
+		tx.locked[walletUUID].state = state
+	}
+	s.statusLog = append(s.statusLog, tx.statusLog...)
+	for _, t := range tx.transactions {
+		s.byId[t.Id] = len(s.transactions)
+		s.transactions = append(s.transactions, t)
+	}
+	for account, balance := range posted {
+		s.posted[account] = balance
+	}
+	for id, hold := range tx.holds {
+		s.holds[id] = hold
+	}
+	for key, record := range tx.keys {
+		s.keys[key] = record
+	}
+	for quoteId, conversionId := range tx.usedQuotes {
+		s.usedQuotes[quoteId] = conversionId
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) CreateWallet(ctx context.Context, ownerId string, params model.CreateWallet) (*model.Wallet, error) {
+	walletUUID := uuid.New().String()
+	wallet := &memWallet{
+		balances: make(map[string]model.Money),
+		state:    walletState{status: WalletActive},
+		info: model.Wallet{
+			ExternalOwnerId: params.ExternalOwnerId,
+			DisplayName:     params.DisplayName,
+			Metadata:        nullableMetadata(params.Metadata),
+			Labels:          slices.Clone(labelsOrEmpty(params.Labels)),
+		},
+		owner: ownerId,
+	}
+
+	s.mu.Lock()
+	s.wallets[walletUUID] = wallet
+	s.mu.Unlock()
+
+	return memWalletInfo(walletUUID, wallet), nil
+}
+
+// Копия кошелька для ответа, вызывается под мьютексом кошелька
+func memWalletInfo(walletUUID string, wallet *memWallet) *model.Wallet {
+	info := wallet.info
+	info.WalletId = walletUUID
+	info.Status = wallet.state.status
+	info.DepositsBlocked = wallet.state.depositsBlocked
+	info.Metadata = slices.Clone(info.Metadata)
+	info.Labels = slices.Clone(info.Labels)
+	return &info
+}
+
+func (s *MemoryStore) Wallet(ctx context.Context, walletUUID string) (*model.Wallet, error) {
+	walletUUID = strings.ToLower(walletUUID)
+
+	tx := s.begin()
+	defer tx.release()
+
+	if err := tx.lock(walletUUID); err != nil {
+		return nil, err
+	}
+	return memWalletInfo(walletUUID, tx.locked[walletUUID]), nil
+}
+
+func (s *MemoryStore) UpdateWallet(ctx context.Context, walletUUID string, patch model.UpdateWallet) (*model.Wallet, error) {
+	walletUUID = strings.ToLower(walletUUID)
+
+	tx := s.begin()
+	defer tx.release()
+
+	if err := tx.lock(walletUUID); err != nil {
+		return nil, err
+	}
+	wallet := tx.locked[walletUUID]
+	if err := wallet.state.checkOpen(walletUUID); err != nil {
+		return nil, err
+	}
+
+	if patch.ExternalOwnerId != nil {
+		wallet.info.ExternalOwnerId = *patch.ExternalOwnerId
+	}
+	if patch.DisplayName != nil {
+		wallet.info.DisplayName = *patch.DisplayName
+	}
+	if patch.Metadata != nil {
+		wallet.info.Metadata = slices.Clone(nullableMetadata(patch.Metadata))
+	}
+	if patch.Labels != nil {
+		wallet.info.Labels = slices.Clone(labelsOrEmpty(*patch.Labels))
+	}
+
+	return memWalletInfo(walletUUID, wallet), nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, walletUUID, operationType, currency string, amount model.Money, idempotency *model.Idempotency) (*model.Transaction, error) {
+	tx := s.begin()
+	defer tx.release()
+
+	transaction, err := s.applyUpdate(tx, walletUUID, operationType, currency, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if idempotency != nil {
+		if err = tx.saveIdempotencyKey(idempotency, transaction.Id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+func (s *MemoryStore) applyUpdate(tx *memTx, walletUUID, operationType, currency string, amount model.Money) (*model.Transaction, error) {
+	walletUUID = strings.ToLower(walletUUID)
+	currentBalance, err := tx.lockBalance(walletUUID, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var delta model.Money
+	switch operationType {
+	case "DEPOSIT":
+		if err = tx.state(walletUUID).checkCredit(walletUUID); err != nil {
+			return nil, err
+		}
+		delta = amount
+	case "WITHDRAW":
+		if err = tx.state(walletUUID).checkDebit(walletUUID); err != nil {
+			return nil, err
+		}
+		held := tx.heldAmount(walletUUID, currency)
+		if amount > currentBalance-held {
+			return nil, &InsufficientFundsError{WalletId: walletUUID, Currency: currency, Balance: currentBalance - held, Requested: amount}
+		}
+		delta = -amount
+	default:
+		return nil, fmt.Errorf("%w: unknown operation type %s", ErrInvalidOperation, operationType)
+	}
+
+	newBalance, err := addBalance(walletUUID, currency, currentBalance, delta)
+	if err != nil {
+		return nil, err
+	}
+	tx.setBalance(walletUUID, currency, newBalance)
+
+	transaction := &model.Transaction{
+		Id:            uuid.New().String(),
+		WalletId:      walletUUID,
+		OperationType: operationType,
+		Currency:      currency,
+		Amount:        amount,
+		BalanceBefore: currentBalance,
+		BalanceAfter:  newBalance,
+	}
+	transaction.EntryId = &transaction.Id
+	err = tx.postEntry(transaction.Id,
+		posting{accountId: walletUUID, currency: currency, amount: delta},
+		posting{accountId: s.options.SystemAccount, currency: currency, amount: -delta})
+	if err != nil {
+		return nil, err
+	}
+	tx.insertTransaction(transaction)
+
+	return transaction, nil
+}
+
+func (s *MemoryStore) Balance(ctx context.Context, walletUUID, currency string) ([]model.Balance, error) {
+	tx := s.begin()
+	defer tx.release()
+
+	walletUUID = strings.ToLower(walletUUID)
+	if err := tx.lock(walletUUID); err != nil {
+		return nil, err
+	}
+
+	currencies := []string{currency}
+	if currency == "" {
+		currencies = make([]string, 0, len(tx.locked[walletUUID].balances))
+		for code := range tx.locked[walletUUID].balances {
+			currencies = append(currencies, code)
+		}
+		sort.Strings(currencies)
+	} else if _, ok := memoryCurrencies[currency]; !ok {
+		return nil, fmt.Errorf("currency %s: %w", currency, ErrUnsupportedCurrency)
+	}
+
+	s.mu.RLock()
+	held := s.heldAmounts(walletUUID, nil)
+	s.mu.RUnlock()
+
+	balances := make([]model.Balance, 0, len(currencies))
+	for _, code := range currencies {
+		balance := model.Balance{Currency: code, Scale: memoryCurrencies[code], Balance: tx.balance(walletUUID, code), Held: held[code]}
+		balance.Available = balance.Balance - balance.Held
+		balances = append(balances, balance)
+	}
+	return balances, nil
+}
+
+func (s *MemoryStore) Currency(ctx context.Context, code string) (*model.Currency, error) {
+	scale, ok := memoryCurrencies[code]
+	if !ok {
+		return nil, fmt.Errorf("currency %s: %w", code, ErrUnsupportedCurrency)
+	}
+	return &model.Currency{Code: code, Scale: scale}, nil
+}
+
+func (s *MemoryStore) Transactions(ctx context.Context, walletUUID string, query model.TransactionsQuery) (*model.TransactionsPage, error) {
+	walletUUID = strings.ToLower(walletUUID)
+
+	var cursorTime time.Time
+	var cursorId string
+	if query.Cursor != "" {
+		var err error
+		if cursorTime, cursorId, err = decodeCursor(query.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.wallets[walletUUID]; !ok {
+		return nil, fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+
+	matched := make([]model.Transaction, 0)
+	for _, t := range s.transactions {
+		switch {
+		case t.WalletId != walletUUID:
+		case query.Cursor != "" && !(t.CreatedAt.Before(cursorTime) || t.CreatedAt.Equal(cursorTime) && t.Id < cursorId):
+		case query.OperationType != "" && t.OperationType != query.OperationType:
+		case query.Currency != "" && t.Currency != query.Currency:
+		case query.MinAmount != nil && t.Amount < *query.MinAmount:
+		case query.MaxAmount != nil && t.Amount > *query.MaxAmount:
+		case query.From != nil && t.CreatedAt.Before(*query.From):
+		case query.To != nil && !t.CreatedAt.Before(*query.To):
+		default:
+			matched = append(matched, t)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].Id > matched[j].Id
+	})
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultTransactionsLimit
+	}
+
+	page := &model.TransactionsPage{Transactions: matched}
+	if len(matched) > limit {
+		page.Transactions = matched[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.Id)
+	}
+
+	return page, nil
+}
+
+func (s *MemoryStore) IdempotentTransaction(ctx context.Context, clientId, key string) (string, *model.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.liveKey(memKey{clientId: clientId, key: key})
+	if !ok {
+		return "", nil, nil
+	}
+
+	t := s.transactions[s.byId[record.transactionId]]
+	return record.requestHash, &t, nil
+}
+
+func (s *MemoryStore) IdempotentTransactions(ctx context.Context, clientId string, keys []string) (map[string]IdempotentRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make(map[string]IdempotentRecord)
+	for _, key := range keys {
+		if record, ok := s.liveKey(memKey{clientId: clientId, key: key}); ok {
+			records[key] = IdempotentRecord{
+				RequestHash: record.requestHash,
+				Transaction: s.transactions[s.byId[record.transactionId]],
+			}
+		}
+	}
+
+	return records, nil
+}
+
+func (s *MemoryStore) UpdateBatch(ctx context.Context, items []model.UpdateBalance, idempotency []*model.Idempotency) ([]model.Transaction, error) {
+	tx := s.begin()
+	defer tx.release()
+
+	firstIndex := make(map[string]int, len(items))
+	for i, item := range items {
+		walletUUID := strings.ToLower(item.WalletId)
+		if _, ok := firstIndex[walletUUID]; !ok {
+			firstIndex[walletUUID] = i
+		}
+	}
+	walletUUIDs := make([]string, 0, len(firstIndex))
+	for walletUUID := range firstIndex {
+		walletUUIDs = append(walletUUIDs, walletUUID)
+	}
+	sort.Strings(walletUUIDs)
+
+	for _, walletUUID := range walletUUIDs {
+		if err := tx.lock(walletUUID); err != nil {
+			return nil, &BatchItemError{Index: firstIndex[walletUUID], Err: err}
+		}
+	}
+
+	transactions := make([]model.Transaction, 0, len(items))
+	for i, item := range items {
+		transaction, err := s.applyUpdate(tx, item.WalletId, item.OperationType, item.Currency, item.Amount)
+		if err != nil {
+			return nil, &BatchItemError{Index: i, Err: err}
+		}
+		if idempotency[i] != nil {
+			if err = tx.saveIdempotencyKey(idempotency[i], transaction.Id); err != nil {
+				return nil, &BatchItemError{Index: i, Err: err}
+			}
+		}
+		transactions = append(transactions, *transaction)
+	}
+
+	if err := tx.commit(); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// Как в Postgres, операции без атомарности рассчитываются по одной с теми же проверками,
+// так что каждая просто выполняется своей транзакцией
+func (s *MemoryStore) UpdateBatchBestEffort(ctx context.Context, items []model.UpdateBalance, idempotency []*model.Idempotency) ([]BatchOutcome, error) {
+	outcomes := make([]BatchOutcome, len(items))
+	for i, item := range items {
+		outcomes[i].Transaction, outcomes[i].Err = s.Update(ctx, item.WalletId, item.OperationType, item.Currency, item.Amount, idempotency[i])
+	}
+	return outcomes, nil
+}
+
+func (s *MemoryStore) Transfer(ctx context.Context, fromUUID, toUUID, currency string, amount model.Money) (*model.TransferResult, error) {
+	fromUUID, toUUID = strings.ToLower(fromUUID), strings.ToLower(toUUID)
+	if fromUUID == toUUID {
+		return nil, fmt.Errorf("%w: transfer to the same wallet %s", ErrInvalidOperation, fromUUID)
+	}
+
+	tx := s.begin()
+	defer tx.release()
+
+	first, second := fromUUID, toUUID
+	if second < first {
+		first, second = second, first
+	}
+	for _, walletUUID := range []string{first, second} {
+		if _, err := tx.lockBalance(walletUUID, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.state(fromUUID).checkDebit(fromUUID); err != nil {
+		return nil, err
+	}
+	if err := tx.state(toUUID).checkCredit(toUUID); err != nil {
+		return nil, err
+	}
+
+	fromBalance, toBalance := tx.balance(fromUUID, currency), tx.balance(toUUID, currency)
+	held := tx.heldAmount(fromUUID, currency)
+	if amount > fromBalance-held {
+		return nil, &InsufficientFundsError{WalletId: fromUUID, Currency: currency, Balance: fromBalance - held, Requested: amount}
+	}
+
+	toBalanceAfter, err := addBalance(toUUID, currency, toBalance, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	transferId := uuid.New().String()
+	result := &model.TransferResult{
+		TransferId: transferId,
+		Debit: model.Transaction{
+			Id:            uuid.New().String(),
+			WalletId:      fromUUID,
+			OperationType: "TRANSFER_OUT",
+			Currency:      currency,
+			Amount:        amount,
+			BalanceBefore: fromBalance,
+			BalanceAfter:  fromBalance - amount,
+			TransferId:    &transferId,
+			EntryId:       &transferId,
+		},
+		Credit: &model.Transaction{
+			Id:            uuid.New().String(),
+			WalletId:      toUUID,
+			OperationType: "TRANSFER_IN",
+			Currency:      currency,
+			Amount:        amount,
+			BalanceBefore: toBalance,
+			BalanceAfter:  toBalanceAfter,
+			TransferId:    &transferId,
+			EntryId:       &transferId,
+		},
+	}
+
+	err = tx.postEntry(transferId,
+		posting{accountId: fromUUID, currency: currency, amount: -amount},
+		posting{accountId: toUUID, currency: currency, amount: amount})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range []*model.Transaction{&result.Debit, result.Credit} {
+		tx.setBalance(t.WalletId, currency, t.BalanceAfter)
+		tx.insertTransaction(t)
+	}
+
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *MemoryStore) CreateHold(ctx context.Context, walletUUID, currency string, amount model.Money) (*model.Hold, error) {
+	walletUUID = strings.ToLower(walletUUID)
+
+	tx := s.begin()
+	defer tx.release()
+
+	balance, err := tx.lockBalance(walletUUID, currency)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.state(walletUUID).checkDebit(walletUUID); err != nil {
+		return nil, err
+	}
+
+	held := tx.heldAmount(walletUUID, currency)
+	if amount > balance-held {
+		return nil, &InsufficientFundsError{WalletId: walletUUID, Currency: currency, Balance: balance - held, Requested: amount}
+	}
+
+	now := time.Now()
+	hold := model.Hold{
+		Id:        uuid.New().String(),
+		WalletId:  walletUUID,
+		Currency:  currency,
+		Amount:    amount,
+		Status:    HoldActive,
+		ExpiresAt: now.Add(s.options.HoldTTL),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	tx.holds[hold.Id] = hold
+
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+func (s *MemoryStore) CaptureHold(ctx context.Context, walletUUID, holdId string, amount model.Money) (*model.CaptureResult, error) {
+	walletUUID = strings.ToLower(walletUUID)
+
+	tx := s.begin()
+	defer tx.release()
+
+	if err := tx.lock(walletUUID); err != nil {
+		return nil, err
+	}
+	if err := tx.state(walletUUID).checkDebit(walletUUID); err != nil {
+		return nil, err
+	}
+
+	hold, err := tx.lockActiveHold(walletUUID, holdId)
+	if err != nil {
+		return nil, err
+	}
+	balance := tx.balance(walletUUID, hold.Currency)
+
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount > hold.Amount {
+		return nil, fmt.Errorf("%w: capture of %d exceeds hold %s amount %d", ErrInvalidOperation, amount, holdId, hold.Amount)
+	}
+
+	hold.Status = HoldCaptured
+	hold.CapturedAmount = amount
+	hold.UpdatedAt = time.Now()
+	tx.holds[hold.Id] = hold
+
+	tx.setBalance(walletUUID, hold.Currency, balance-amount)
+
+	transactionId := uuid.New().String()
+	transaction := model.Transaction{
+		Id:            transactionId,
+		WalletId:      walletUUID,
+		OperationType: "CAPTURE",
+		Currency:      hold.Currency,
+		Amount:        amount,
+		BalanceBefore: balance,
+		BalanceAfter:  balance - amount,
+		HoldId:        &hold.Id,
+		EntryId:       &transactionId,
+	}
+	err = tx.postEntry(transactionId,
+		posting{accountId: walletUUID, currency: hold.Currency, amount: -amount},
+		posting{accountId: s.options.SystemAccount, currency: hold.Currency, amount: amount})
+	if err != nil {
+		return nil, err
+	}
+	tx.insertTransaction(&transaction)
+
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+
+	return &model.CaptureResult{Hold: hold, Transaction: transaction}, nil
+}
+
+func (s *MemoryStore) VoidHold(ctx context.Context, walletUUID, holdId string) (*model.Hold, error) {
+	walletUUID = strings.ToLower(walletUUID)
+
+	tx := s.begin()
+	defer tx.release()
+
+	if err := tx.lock(walletUUID); err != nil {
+		return nil, err
+	}
+	if err := tx.state(walletUUID).checkOpen(walletUUID); err != nil {
+		return nil, err
+	}
+
+	hold, err := tx.lockActiveHold(walletUUID, holdId)
+	if err != nil {
+		return nil, err
+	}
+
+	hold.Status = HoldVoided
+	hold.UpdatedAt = time.Now()
+	tx.holds[hold.Id] = hold
+
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+// Аналог lockActiveHold, холды кошелька меняются только под его блокировкой
+func (tx *memTx) lockActiveHold(walletUUID, holdId string) (model.Hold, error) {
+	hold, ok := tx.hold(strings.ToLower(holdId))
+	if !ok || hold.WalletId != walletUUID {
+		return model.Hold{}, fmt.Errorf("hold %s on wallet %s: %w", holdId, walletUUID, ErrHoldNotFound)
+	}
+
+	if hold.Status == HoldActive && !hold.ExpiresAt.After(time.Now()) {
+		hold.Status = HoldExpired
+	}
+	if hold.Status != HoldActive {
+		return model.Hold{}, fmt.Errorf("hold %s is %s: %w", holdId, hold.Status, ErrHoldNotActive)
+	}
+
+	return hold, nil
+}
+
+func (s *MemoryStore) CreateQuote(ctx context.Context, quote *model.Quote) (*model.Quote, error) {
+	saved := *quote
+	saved.Id = uuid.New().String()
+	saved.CreatedAt = time.Now()
+	saved.ExpiresAt = saved.CreatedAt.Add(s.options.QuoteTTL)
+
+	s.mu.Lock()
+	s.quotes[saved.Id] = saved
+	s.mu.Unlock()
+
+	return &saved, nil
+}
+
+func (s *MemoryStore) Quote(ctx context.Context, quoteId string) (*model.Quote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quote, ok := s.quotes[strings.ToLower(quoteId)]
+	if !ok {
+		return nil, fmt.Errorf("quote %s: %w", quoteId, ErrQuoteNotFound)
+	}
+	return &quote, nil
+}
+
+func (s *MemoryStore) Convert(ctx context.Context, walletUUID string, quote *model.Quote) (*model.ConversionResult, error) {
+	walletUUID = strings.ToLower(walletUUID)
+
+	tx := s.begin()
+	defer tx.release()
+
+	if err := tx.lock(walletUUID); err != nil {
+		return nil, err
+	}
+	if err := tx.state(walletUUID).checkDebit(walletUUID); err != nil {
+		return nil, err
+	}
+
+	var quoteId *string
+	if quote.Id != "" {
+		if err := tx.lockQuote(strings.ToLower(quote.Id)); err != nil {
+			return nil, err
+		}
+		quoteId = &quote.Id
+	}
+
+	fromBalance, err := tx.lockBalance(walletUUID, quote.FromCurrency)
+	if err != nil {
+		return nil, err
+	}
+	toBalance, err := tx.lockBalance(walletUUID, quote.ToCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	held := tx.heldAmount(walletUUID, quote.FromCurrency)
+	if quote.FromAmount > fromBalance-held {
+		return nil, &InsufficientFundsError{WalletId: walletUUID, Currency: quote.FromCurrency, Balance: fromBalance - held, Requested: quote.FromAmount}
+	}
+
+	conversionId := uuid.New().String()
+	result, err := newConversionResult(conversionId, walletUUID, quoteId, quote, fromBalance, toBalance)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.postEntry(conversionId,
+		posting{accountId: walletUUID, currency: quote.FromCurrency, amount: -quote.FromAmount},
+		posting{accountId: s.options.FXAccount, currency: quote.FromCurrency, amount: quote.FromAmount},
+		posting{accountId: s.options.FXAccount, currency: quote.ToCurrency, amount: -quote.ToAmount},
+		posting{accountId: walletUUID, currency: quote.ToCurrency, amount: quote.ToAmount})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range []*model.Transaction{&result.Debit, &result.Credit} {
+		tx.setBalance(walletUUID, t.Currency, t.BalanceAfter)
+		tx.insertTransaction(t)
+	}
+	if quoteId != nil {
+		tx.usedQuotes[strings.ToLower(quote.Id)] = conversionId
+	}
+
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Аналог lockQuote, использование окончательно проверяется в commit
+func (tx *memTx) lockQuote(quoteId string) error {
+	tx.s.mu.RLock()
+	quote, ok := tx.s.quotes[quoteId]
+	_, used := tx.s.usedQuotes[quoteId]
+	tx.s.mu.RUnlock()
+
+	switch {
+	case !ok:
+		return fmt.Errorf("quote %s: %w", quoteId, ErrQuoteNotFound)
+	case used:
+		return fmt.Errorf("quote %s: %w", quoteId, ErrQuoteUsed)
+	case !quote.ExpiresAt.After(time.Now()):
+		return fmt.Errorf("quote %s: %w", quoteId, ErrQuoteExpired)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Reverse(ctx context.Context, transactionId string, amount model.Money) (*model.ReversalResult, error) {
+	legs, err := s.reversibleLegs(strings.ToLower(transactionId))
+	if err != nil {
+		return nil, err
+	}
+
+	tx := s.begin()
+	defer tx.release()
+
+	walletUUIDs := make([]string, 0, len(legs))
+	for _, leg := range legs {
+		walletUUIDs = append(walletUUIDs, leg.WalletId)
+	}
+	sort.Strings(walletUUIDs)
+	for _, walletUUID := range walletUUIDs {
+		if err = tx.lock(walletUUID); err != nil {
+			return nil, err
+		}
+	}
+
+	original := legs[0]
+	currency := original.Currency
+	var reversed model.Money
+	s.mu.RLock()
+	for _, t := range s.transactions {
+		if t.ReversalOf != nil && *t.ReversalOf == original.Id {
+			reversed += t.Amount
+		}
+	}
+	s.mu.RUnlock()
+
+	remaining := original.Amount - reversed
+	if remaining <= 0 {
+		return nil, fmt.Errorf("transaction %s: %w", transactionId, ErrAlreadyReversed)
+	}
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount > remaining {
+		return nil, fmt.Errorf("%w: reversal of %d exceeds remaining %d of transaction %s",
+			ErrInvalidOperation, amount, remaining, transactionId)
+	}
+
+	reversalId := uuid.New().String()
+	result := &model.ReversalResult{
+		ReversalId:            reversalId,
+		OriginalTransactionId: transactionId,
+		Amount:                amount,
+		Remaining:             remaining - amount,
+		Transactions:          make([]model.Transaction, 0, len(legs)),
+	}
+
+	postings := make([]posting, 0, 2)
+	for _, leg := range legs {
+		delta := amount
+		if leg.OperationType == "DEPOSIT" || leg.OperationType == "TRANSFER_IN" {
+			delta = -amount
+		}
+
+		balance := tx.balance(leg.WalletId, currency)
+		if delta > 0 {
+			if err = tx.state(leg.WalletId).checkCredit(leg.WalletId); err != nil {
+				return nil, err
+			}
+		} else {
+			if err = tx.state(leg.WalletId).checkDebit(leg.WalletId); err != nil {
+				return nil, err
+			}
+			held := tx.heldAmount(leg.WalletId, currency)
+			if amount > balance-held {
+				return nil, &InsufficientFundsError{WalletId: leg.WalletId, Currency: currency, Balance: balance - held, Requested: amount}
+			}
+		}
+
+		balanceAfter, err := addBalance(leg.WalletId, currency, balance, delta)
+		if err != nil {
+			return nil, err
+		}
+
+		postings = append(postings, posting{accountId: leg.WalletId, currency: currency, amount: delta})
+		result.Transactions = append(result.Transactions, model.Transaction{
+			Id:            uuid.New().String(),
+			WalletId:      leg.WalletId,
+			OperationType: "REVERSAL",
+			Currency:      currency,
+			Amount:        amount,
+			BalanceBefore: balance,
+			BalanceAfter:  balanceAfter,
+			EntryId:       &reversalId,
+			ReversalOf:    &leg.Id,
+		})
+	}
+	if len(legs) == 1 {
+		postings = append(postings, posting{accountId: s.options.SystemAccount, currency: currency, amount: -postings[0].amount})
+	}
+
+	if err = tx.postEntry(reversalId, postings...); err != nil {
+		return nil, err
+	}
+
+	for i := range result.Transactions {
+		t := &result.Transactions[i]
+		tx.setBalance(t.WalletId, currency, t.BalanceAfter)
+		tx.insertTransaction(t)
+	}
+
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Аналог reversibleLegs
+func (s *MemoryStore) reversibleLegs(transactionId string) ([]model.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, ok := s.byId[transactionId]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s: %w", transactionId, ErrTransactionNotFound)
+	}
+	t := s.transactions[index]
+
+	switch t.OperationType {
+	case "DEPOSIT", "WITHDRAW":
+		return []model.Transaction{t}, nil
+	case "TRANSFER_OUT", "TRANSFER_IN":
+	default:
+		return nil, fmt.Errorf("%w: %s transaction %s cannot be reversed", ErrInvalidOperation, t.OperationType, transactionId)
+	}
+
+	legs := make([]model.Transaction, 0, 2)
+	for _, leg := range s.transactions {
+		if leg.TransferId != nil && *leg.TransferId == *t.TransferId {
+			legs = append(legs, leg)
+		}
+	}
+	sort.Slice(legs, func(i, j int) bool { return legs[i].OperationType > legs[j].OperationType })
+	if len(legs) != 2 {
+		return nil, fmt.Errorf("transfer %s has %d parts instead of 2", *t.TransferId, len(legs))
+	}
+
+	return legs, nil
+}
+
+func (s *MemoryStore) LedgerSummary(ctx context.Context) (*model.LedgerSummary, error) {
+	summary := &model.LedgerSummary{SystemAccounts: []model.LedgerAccount{}}
+	totals := make(map[string]*model.LedgerCurrency)
+	total := func(currency string) *model.LedgerCurrency {
+		if _, ok := totals[currency]; !ok {
+			totals[currency] = &model.LedgerCurrency{Currency: currency}
+		}
+		return totals[currency]
+	}
+
+	s.mu.RLock()
+	wallets := make(map[string]*memWallet, len(s.wallets))
+	for walletUUID, wallet := range s.wallets {
+		wallets[walletUUID] = wallet
+	}
+	for account, balance := range s.posted {
+		if _, ok := wallets[account.id]; !ok {
+			summary.SystemAccounts = append(summary.SystemAccounts, model.LedgerAccount{Code: account.id, Currency: account.currency, Balance: balance})
+			total(account.currency).Total = total(account.currency).Total.Add(balance)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(summary.SystemAccounts, func(i, j int) bool {
+		a, b := summary.SystemAccounts[i], summary.SystemAccounts[j]
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		return a.Currency < b.Currency
+	})
+
+	// балансы кошелька и его проводки сверяются под блокировкой кошелька, как и меняются
+	for walletUUID, wallet := range wallets {
+		wallet.mu.Lock()
+		s.mu.RLock()
+		posted := make(map[string]model.Money)
+		for account, balance := range s.posted {
+			if account.id == walletUUID {
+				posted[account.currency] = balance
+			}
+		}
+		s.mu.RUnlock()
+		for currency, balance := range wallet.balances {
+			if _, ok := posted[currency]; !ok && balance != 0 {
+				summary.MismatchedWallets++
+			}
+		}
+		for currency, balance := range posted {
+			if wallet.balances[currency] != balance {
+				summary.MismatchedWallets++
+			}
+			total(currency).WalletsTotal = total(currency).WalletsTotal.Add(balance)
+		}
+		wallet.mu.Unlock()
+	}
+
+	summary.Currencies, summary.Balanced = ledgerTotals(totals, summary.MismatchedWallets)
+	return summary, nil
+}
+
+func (s *MemoryStore) CreateClient(ctx context.Context, name, keyHash, keyPrefix string) (*model.ApiClient, error) {
+	client := model.ApiClient{Id: uuid.New().String(), Name: name, KeyPrefix: keyPrefix, CreatedAt: time.Now()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.clients {
+		if existing.keyHash == keyHash {
+			return nil, fmt.Errorf("error creating api client: duplicate key")
+		}
+	}
+	s.clients[client.Id] = memClient{client: client, keyHash: keyHash}
+
+	return &client, nil
+}
+
+func (s *MemoryStore) ClientByKeyHash(ctx context.Context, keyHash string) (*model.ApiClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, existing := range s.clients {
+		if existing.keyHash == keyHash && existing.client.RevokedAt == nil {
+			client := existing.client
+			return &client, nil
+		}
+	}
+	return nil, ErrClientNotFound
+}
+
+func (s *MemoryStore) ClientById(ctx context.Context, clientId string) (*model.ApiClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	existing, ok := s.clients[strings.ToLower(clientId)]
+	if !ok || existing.client.RevokedAt != nil {
+		return nil, ErrClientNotFound
+	}
+	client := existing.client
+	return &client, nil
+}
+
+func (s *MemoryStore) Clients(ctx context.Context) ([]model.ApiClient, error) {
+	s.mu.RLock()
+	clients := make([]model.ApiClient, 0, len(s.clients))
+	for _, existing := range s.clients {
+		clients = append(clients, existing.client)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		if !clients[i].CreatedAt.Equal(clients[j].CreatedAt) {
+			return clients[i].CreatedAt.After(clients[j].CreatedAt)
+		}
+		return clients[i].Id < clients[j].Id
+	})
+	return clients, nil
+}
+
+func (s *MemoryStore) RevokeClient(ctx context.Context, clientId string) (*model.ApiClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.clients[strings.ToLower(clientId)]
+	if !ok {
+		return nil, fmt.Errorf("client %s: %w", clientId, ErrClientNotFound)
+	}
+	if existing.client.RevokedAt == nil {
+		now := time.Now()
+		existing.client.RevokedAt = &now
+		s.clients[existing.client.Id] = existing
+	}
+
+	client := existing.client
+	return &client, nil
+}
+
+func (s *MemoryStore) WalletOwners(ctx context.Context, walletUUIDs []string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	owners := make(map[string]string, len(walletUUIDs))
+	for _, walletUUID := range walletUUIDs {
+		walletUUID = strings.ToLower(walletUUID)
+		if wallet, ok := s.wallets[walletUUID]; ok {
+			owners[walletUUID] = wallet.owner
+		}
+	}
+	return owners, nil
+}
+
+func (s *MemoryStore) SetWalletOwner(ctx context.Context, walletUUID, clientId string) (*model.WalletOwner, error) {
+	walletUUID, clientId = strings.ToLower(walletUUID), strings.ToLower(clientId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.clients[clientId]; !ok || existing.client.RevokedAt != nil {
+		return nil, fmt.Errorf("client %s: %w", clientId, ErrClientNotFound)
+	}
+	wallet, ok := s.wallets[walletUUID]
+	if !ok {
+		return nil, fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+
+	owner := &model.WalletOwner{WalletId: walletUUID, OwnerId: clientId, PreviousOwnerId: wallet.owner}
+	wallet.owner = clientId
+	return owner, nil
+}
+
+func (s *MemoryStore) TransactionWallets(ctx context.Context, transactionId string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, ok := s.byId[strings.ToLower(transactionId)]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s: %w", transactionId, ErrTransactionNotFound)
+	}
+	t := s.transactions[index]
+	if t.TransferId == nil {
+		return []string{t.WalletId}, nil
+	}
+
+	walletUUIDs := make([]string, 0, 2)
+	for _, leg := range s.transactions {
+		if leg.TransferId != nil && *leg.TransferId == *t.TransferId {
+			walletUUIDs = append(walletUUIDs, leg.WalletId)
+		}
+	}
+	return walletUUIDs, nil
+}
+
+func (s *MemoryStore) ChangeWalletStatus(ctx context.Context, change *model.WalletStatusChange) (*model.WalletStatusChange, error) {
+	walletUUID := strings.ToLower(change.WalletId)
+
+	tx := s.begin()
+	defer tx.release()
+
+	if err := tx.lock(walletUUID); err != nil {
+		return nil, err
+	}
+	state := tx.state(walletUUID)
+	if err := checkTransition(change, state); err != nil {
+		return nil, err
+	}
+
+	if change.Status == WalletClosed {
+		for _, balance := range tx.locked[walletUUID].balances {
+			if balance != 0 {
+				return nil, fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotEmpty)
+			}
+		}
+	}
+
+	saved := *change
+	saved.Id = uuid.New().String()
+	saved.WalletId = walletUUID
+	saved.PreviousStatus = state.status
+	saved.DepositsBlocked = change.Status == WalletFrozen && change.DepositsBlocked
+	saved.CreatedAt = time.Now()
+
+	tx.states[walletUUID] = walletState{status: saved.Status, depositsBlocked: saved.DepositsBlocked}
+	tx.statusLog = append(tx.statusLog, saved)
+
+	if err := tx.commit(); err != nil {
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+func (s *MemoryStore) WalletStatusHistory(ctx context.Context, walletUUID string) ([]model.WalletStatusChange, error) {
+	walletUUID = strings.ToLower(walletUUID)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.wallets[walletUUID]; !ok {
+		return nil, fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+
+	changes := make([]model.WalletStatusChange, 0)
+	for _, change := range s.statusLog {
+		if change.WalletId == walletUUID {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
@@ -1,64 +1,101 @@
 package repository
 
 import (
+	"WalletAPI/m/internal/metrics"
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/tracing"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Структура для работы с базой данных
 type WalletRepo struct {
-	DB     *pgxpool.Pool
-	logger *log.Logger
+	DB      *pgxpool.Pool
+	logger  *slog.Logger
+	options Options
+
+	systemAccountId string // id счёта Options.SystemAccount, заполняется в InitAccounts
+	fxAccountId     string // id счёта Options.FXAccount, заполняется в InitAccounts
+}
+
+// Настройки WalletRepo, заполняются из config.Config
+type Options struct {
+	IdempotencyTTL time.Duration // сколько хранится ключ идемпотентности
+	HoldTTL        time.Duration // через сколько истекает холд
+	SystemAccount  string        // код системного счёта для DEPOSIT и WITHDRAW
+	FXAccount      string        // код системного счёта, против которого проводится обмен валют
+	QuoteTTL       time.Duration // сколько действует котировка обмена
 }
 
 // Конструктор WalletRepo
-func NewWalletRepo(db *pgxpool.Pool, logger *log.Logger) *WalletRepo {
+func NewWalletRepo(db *pgxpool.Pool, logger *slog.Logger, options Options) *WalletRepo {
 	return &WalletRepo{
-		DB:     db,
-		logger: logger,
+		DB:      db,
+		logger:  logger,
+		options: options,
 	}
 }
 
 /*
 Создание кошелька (в ТЗ нет, но без UUID и кошелька не будет, а так тестить легче)
 
+Принимает:
+
+ownerId string - id клиента API, который создаёт кошелёк и становится его владельцем
+
+params model.CreateWallet - необязательные данные кошелька: внешний id владельца, название, metadata и labels
+
 Возвращает:
 
-walletUUID string - UUID кошелька
+wallet *model.Wallet - созданный кошелёк
 
 error - error
 */
-func (r *WalletRepo) CreateWallet(ctx context.Context) (string, error) {
+func (r *WalletRepo) CreateWallet(ctx context.Context, ownerId string, params model.CreateWallet) (*model.Wallet, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error starting transaction: %v", err)
+		return nil, dbError(err, "error starting transaction")
 	}
 	defer tx.Rollback(ctx)
 
 	walletUUID := uuid.New().String()
 
+	// кошелёк - это счёт в журнале, id счёта совпадает с UUID кошелька
 	_, err = tx.Exec(ctx, `
-        INSERT INTO wallets (uuid, balance)
-        VALUES ($1, $2)`,
-		walletUUID, 0)
+        INSERT INTO accounts (id, kind)
+        VALUES ($1, 'WALLET')`,
+		walletUUID)
 	if err != nil {
-		return "", fmt.Errorf("error creating wallet: %v", err)
+		return nil, dbError(err, "error creating wallet account")
+	}
+
+	var wallet model.Wallet
+	err = tx.QueryRow(ctx, `
+        INSERT INTO wallets (uuid, owner_id, external_owner_id, display_name, metadata, labels)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING `+walletColumns,
+		walletUUID, ownerId, params.ExternalOwnerId, params.DisplayName, nullableMetadata(params.Metadata), labelsOrEmpty(params.Labels)).Scan(walletDest(&wallet)...)
+	if err != nil {
+		return nil, dbError(err, "error creating wallet")
 	}
 
 	if err = tx.Commit(ctx); err != nil {
-		return "", fmt.Errorf("error committing transaction: %v", err)
+		return nil, dbError(err, "error committing transaction")
 	}
 
-	r.logger.Printf("INFO: New wallet %s successfully created!", walletUUID)
-	return walletUUID, nil
+	r.logger.InfoContext(ctx, "Wallet created", "wallet_id", walletUUID, "owner_id", ownerId, "external_owner_id", wallet.ExternalOwnerId)
+	return &wallet, nil
 }
 
 /*
@@ -73,87 +110,405 @@ walletUUID string - UUID кошелька
 
 operationType string - тип оперции, DEPOSIT либо WITHDRAW
 
-amount int64 - сумма, на которую пополняется/списывается с кошелька
+currency string - код валюты, баланс в которой меняется
+
+amount model.Money - сумма в минимальных единицах валюты, на которую пополняется/списывается с кошелька
+
+idempotency *model.Idempotency - ключ идемпотентности, nil если клиент его не передал.
+Ключ сохраняется в той же транзакции, что и операция; если ключ уже занят - ErrIdempotencyKeyExists
 
 Возвращает:
 
+transaction *model.Transaction - запись журнала операций, созданная в той же транзакции
+
 error - error
 */
-func (r *WalletRepo) Update(ctx context.Context, walletUUID, operationType string, amount int64) error {
+func (r *WalletRepo) Update(ctx context.Context, walletUUID, operationType, currency string, amount model.Money, idempotency *model.Idempotency) (*model.Transaction, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.Update",
+		attribute.String("wallet.id", walletUUID),
+		attribute.String("wallet.operation_type", operationType),
+		attribute.String("wallet.currency", currency),
+	)
+	defer span.End()
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return nil, dbError(err, "error starting transaction")
 	}
 	defer tx.Rollback(ctx)
 
-	var currentBalance int64
-	err = tx.QueryRow(ctx, `
-        SELECT balance FROM wallets 
-        WHERE uuid = $1
-        FOR UPDATE`, // предотвращает race conditions
-		walletUUID).Scan(&currentBalance)
+	transaction, err := r.applyUpdate(ctx, tx, walletUUID, operationType, currency, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if idempotency != nil {
+		if err = r.saveIdempotencyKey(ctx, tx, idempotency, transaction.Id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Wallet updated", "wallet_id", walletUUID, "operation", operationType,
+		"currency", currency, "amount", amount, "balance", transaction.BalanceAfter, "transaction_id", transaction.Id)
+	return transaction, nil
+}
+
+// DEPOSIT или WITHDRAW внутри уже открытой транзакции: блокировка кошелька, новый баланс,
+// запись журнала двойной записи и журнала операций
+func (r *WalletRepo) applyUpdate(ctx context.Context, tx pgx.Tx, walletUUID, operationType, currency string, amount model.Money) (*model.Transaction, error) {
+	lockStart := time.Now()
+	currentBalance, state, err := lockBalance(ctx, tx, walletUUID, currency)
+	metrics.LockWait.WithLabelValues(operationType).Observe(time.Since(lockStart).Seconds())
 	if err != nil {
-		return fmt.Errorf("error getting balance: %v", err)
+		return nil, err
 	}
 
-	var newBalance int64
+	// изменение баланса кошелька, системный счёт получает противоположную проводку
+	var delta model.Money
 	if operationType == "DEPOSIT" {
-		newBalance = currentBalance + amount
+		if err = state.checkCredit(walletUUID); err != nil {
+			return nil, err
+		}
+		delta = amount
 	} else if operationType == "WITHDRAW" {
-		if amount > currentBalance {
-			return fmt.Errorf("insufficient funds: have %d, need %d", currentBalance, amount)
+		if err = state.checkDebit(walletUUID); err != nil {
+			return nil, err
+		}
+		// зарезервированное холдами снять нельзя
+		held, err := heldAmount(ctx, tx, walletUUID, currency)
+		if err != nil {
+			return nil, err
 		}
-		newBalance = currentBalance - amount
+		if amount > currentBalance-held {
+			return nil, &InsufficientFundsError{WalletId: walletUUID, Currency: currency, Balance: currentBalance - held, Requested: amount}
+		}
+		delta = -amount
 	} else {
-		return fmt.Errorf("invalid operation type: %s", operationType)
+		return nil, fmt.Errorf("%w: unknown operation type %s", ErrInvalidOperation, operationType)
 	}
 
-	_, err = tx.Exec(ctx, `
-        UPDATE wallets 
-        SET balance = $1 
-        WHERE uuid = $2`,
-		newBalance, walletUUID)
+	newBalance, err := addBalance(walletUUID, currency, currentBalance, delta)
 	if err != nil {
-		return fmt.Errorf("error updating balance: %v", err)
+		return nil, err
+	}
+	if err = setBalance(ctx, tx, walletUUID, currency, newBalance); err != nil {
+		return nil, err
 	}
 
-	if err = tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+	// запись в журнал в той же транзакции, чтобы баланс и история не расходились
+	transaction := &model.Transaction{
+		Id:            uuid.New().String(),
+		WalletId:      walletUUID,
+		OperationType: operationType,
+		Currency:      currency,
+		Amount:        amount,
+		BalanceBefore: currentBalance,
+		BalanceAfter:  newBalance,
+	}
+	transaction.EntryId = &transaction.Id
+	err = postEntry(ctx, tx, transaction.Id, operationType,
+		posting{accountId: walletUUID, currency: currency, amount: delta},
+		posting{accountId: r.systemAccountId, currency: currency, amount: -delta})
+	if err != nil {
+		return nil, err
+	}
+	if err = insertTransaction(ctx, tx, transaction); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// Блокировка строки кошелька до конца транзакции. Блокируется кошелёк целиком, а не баланс
+// в одной валюте: так все операции кошелька идут по очереди, как и до мультивалютности.
+// Возвращает статус кошелька: его меняют тоже под этой блокировкой, так что до конца транзакции он не изменится
+func lockWallet(ctx context.Context, tx pgx.Tx, walletUUID string) (walletState, error) {
+	var state walletState
+	err := tx.QueryRow(ctx, `
+        SELECT status, deposits_blocked FROM wallets
+        WHERE uuid = $1
+        FOR UPDATE`, // предотвращает race conditions
+		walletUUID).Scan(&state.status, &state.depositsBlocked)
+	if err != nil {
+		return walletState{}, walletError(err, walletUUID, "error locking wallet %s", walletUUID)
+	}
+
+	return state, nil
+}
+
+// Блокировка строки кошелька до конца транзакции, возвращает текущий баланс в валюте currency,
+// 0 если операций в ней ещё не было, и статус кошелька, как lockWallet. ErrUnsupportedCurrency для неизвестной валюты
+func lockBalance(ctx context.Context, tx pgx.Tx, walletUUID, currency string) (model.Money, walletState, error) {
+	var balance model.Money
+	var state walletState
+	var supported bool
+	err := tx.QueryRow(ctx, `
+        SELECT COALESCE(b.balance, 0), w.status, w.deposits_blocked, EXISTS (SELECT 1 FROM currencies WHERE code = $2)
+        FROM wallets w
+        LEFT JOIN wallet_balances b ON b.wallet_uuid = w.uuid AND b.currency = $2
+        WHERE w.uuid = $1
+        FOR UPDATE OF w`, // предотвращает race conditions
+		walletUUID, currency).Scan(&balance, &state.status, &state.depositsBlocked, &supported)
+	if err != nil {
+		return 0, walletState{}, walletError(err, walletUUID, "error getting wallet %s balance", walletUUID)
+	}
+	if !supported {
+		return 0, walletState{}, fmt.Errorf("currency %s: %w", currency, ErrUnsupportedCurrency)
+	}
+
+	return balance, state, nil
+}
+
+// Новый баланс кошелька в валюте, вызывать под блокировкой кошелька
+func setBalance(ctx context.Context, tx pgx.Tx, walletUUID, currency string, balance model.Money) error {
+	_, err := tx.Exec(ctx, `
+        INSERT INTO wallet_balances (wallet_uuid, currency, balance)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (wallet_uuid, currency) DO UPDATE
+        SET balance = EXCLUDED.balance`,
+		walletUUID, currency, balance)
+	if err != nil {
+		return dbError(err, "error updating wallet %s %s balance", walletUUID, currency)
+	}
+
+	return nil
+}
+
+// Новые балансы кошельков пакета одним запросом, вызывать под блокировкой всех этих кошельков
+func setBalances(ctx context.Context, tx pgx.Tx, balances map[balanceKey]model.Money) error {
+	walletUUIDs := make([]string, 0, len(balances))
+	currencies := make([]string, 0, len(balances))
+	amounts := make([]int64, 0, len(balances))
+	for key, balance := range balances {
+		walletUUIDs = append(walletUUIDs, key.walletUUID)
+		currencies = append(currencies, key.currency)
+		amounts = append(amounts, int64(balance))
+	}
+
+	_, err := tx.Exec(ctx, `
+        INSERT INTO wallet_balances (wallet_uuid, currency, balance)
+        SELECT wallet_uuid, currency, balance
+        FROM unnest($1::uuid[], $2::text[], $3::bigint[]) AS b (wallet_uuid, currency, balance)
+        ON CONFLICT (wallet_uuid, currency) DO UPDATE
+        SET balance = EXCLUDED.balance`,
+		walletUUIDs, currencies, amounts)
+	if err != nil {
+		return dbError(err, "error updating %d wallet balances", len(balances))
+	}
+
+	return nil
+}
+
+// Баланс кошелька после изменения на delta, ErrBalanceOverflow если он не помещается в BIGINT.
+// Списания проверяются на достаточность средств раньше, так что переполнить баланс может только зачисление
+func addBalance(walletUUID, currency string, balance, delta model.Money) (model.Money, error) {
+	newBalance, err := balance.Add(delta)
+	if err != nil {
+		return 0, fmt.Errorf("wallet %s %s: %w: %v", walletUUID, currency, ErrBalanceOverflow, err)
+	}
+	return newBalance, nil
+}
+
+// Общее у pgx.Tx и pgxpool.Pool, чтобы запросы на чтение работали и в транзакции, и без неё
+type pgxQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Сумма активных непросроченных холдов кошелька в валюте. Вызывать под блокировкой строки кошелька,
+// все изменения холдов тоже идут под ней, поэтому сумма не может устареть до конца транзакции
+func heldAmount(ctx context.Context, q pgxQuerier, walletUUID, currency string) (model.Money, error) {
+	var held model.Money
+	err := q.QueryRow(ctx, `
+        SELECT COALESCE(SUM(amount), 0) FROM holds
+        WHERE wallet_uuid = $1
+          AND currency = $2
+          AND status = 'ACTIVE'
+          AND expires_at > now()`,
+		walletUUID, currency).Scan(&held)
+	if err != nil {
+		return 0, dbError(err, "error getting wallet %s held amount", walletUUID)
+	}
+
+	return held, nil
+}
+
+// Колонки журнала операций в порядке transactionDest
+const transactionColumns = `id, wallet_uuid, operation_type, currency, amount, balance_before, balance_after, transfer_id, hold_id, entry_id, reversal_of, created_at`
+
+// Указатели на поля записи журнала для Scan, в порядке transactionColumns
+func transactionDest(t *model.Transaction) []any {
+	return []any{&t.Id, &t.WalletId, &t.OperationType, &t.Currency, &t.Amount, &t.BalanceBefore, &t.BalanceAfter, &t.TransferId, &t.HoldId, &t.EntryId, &t.ReversalOf, &t.CreatedAt}
+}
+
+// Запись операции в журнал внутри уже открытой транзакции, CreatedAt заполняется из БД
+func insertTransaction(ctx context.Context, tx pgx.Tx, t *model.Transaction) error {
+	err := tx.QueryRow(ctx, `
+        INSERT INTO transactions (id, wallet_uuid, operation_type, currency, amount, balance_before, balance_after, transfer_id, hold_id, entry_id, reversal_of)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING created_at`,
+		t.Id, t.WalletId, t.OperationType, t.Currency, t.Amount, t.BalanceBefore, t.BalanceAfter, t.TransferId, t.HoldId, t.EntryId, t.ReversalOf).Scan(&t.CreatedAt)
+	if err != nil {
+		return dbError(err, "error recording transaction")
+	}
+
+	return nil
+}
+
+// Запись операций в журнал одним запросом внутри уже открытой транзакции, CreatedAt заполняется из БД
+func insertTransactions(ctx context.Context, tx pgx.Tx, transactions []*model.Transaction) error {
+	n := len(transactions)
+	ids, walletUUIDs := make([]string, 0, n), make([]string, 0, n)
+	operationTypes, currencies := make([]string, 0, n), make([]string, 0, n)
+	amounts, balancesBefore, balancesAfter := make([]int64, 0, n), make([]int64, 0, n), make([]int64, 0, n)
+	transferIds, holdIds, entryIds, reversalsOf := make([]*string, 0, n), make([]*string, 0, n), make([]*string, 0, n), make([]*string, 0, n)
+	byId := make(map[string]*model.Transaction, n)
+	for _, t := range transactions {
+		ids = append(ids, t.Id)
+		walletUUIDs = append(walletUUIDs, t.WalletId)
+		operationTypes = append(operationTypes, t.OperationType)
+		currencies = append(currencies, t.Currency)
+		amounts = append(amounts, int64(t.Amount))
+		balancesBefore = append(balancesBefore, int64(t.BalanceBefore))
+		balancesAfter = append(balancesAfter, int64(t.BalanceAfter))
+		transferIds = append(transferIds, t.TransferId)
+		holdIds = append(holdIds, t.HoldId)
+		entryIds = append(entryIds, t.EntryId)
+		reversalsOf = append(reversalsOf, t.ReversalOf)
+		byId[t.Id] = t
+	}
+
+	rows, err := tx.Query(ctx, `
+        INSERT INTO transactions (id, wallet_uuid, operation_type, currency, amount, balance_before, balance_after, transfer_id, hold_id, entry_id, reversal_of)
+        SELECT *
+        FROM unnest($1::uuid[], $2::uuid[], $3::text[], $4::text[], $5::bigint[], $6::bigint[], $7::bigint[], $8::uuid[], $9::uuid[], $10::uuid[], $11::uuid[])
+        RETURNING id, created_at`,
+		ids, walletUUIDs, operationTypes, currencies, amounts, balancesBefore, balancesAfter, transferIds, holdIds, entryIds, reversalsOf)
+	if err != nil {
+		return dbError(err, "error recording %d transactions", n)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var createdAt time.Time
+		if err = rows.Scan(&id, &createdAt); err != nil {
+			return dbError(err, "error scanning recorded transaction")
+		}
+		byId[id].CreatedAt = createdAt
+	}
+	if err = rows.Err(); err != nil {
+		return dbError(err, "error recording %d transactions", n)
 	}
 
-	r.logger.Printf("INFO: Wallet %s updated: %s %d (new balance: %d)",
-		walletUUID, operationType, amount, newBalance)
 	return nil
 }
 
 /*
-Получение баланса кошелька
+Получение балансов кошелька
 
 Принимает:
 
 walletUUID string - UUID кошелька
 
+currency string - код валюты, пустой - все валюты, в которых по кошельку были операции
+
 Возвращает:
 
-balance int64 - баланс
+balances []model.Balance - по каждой валюте весь баланс, доступный баланс и зарезервированная холдами сумма.
+С currency - ровно один баланс, нулевой, если в этой валюте операций не было
 
-error - error
+error - error, ErrUnsupportedCurrency для неизвестной валюты
+*/
+func (r *WalletRepo) Balance(ctx context.Context, walletUUID, currency string) ([]model.Balance, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err := r.DB.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM wallets WHERE uuid = $1)`,
+		walletUUID).Scan(&exists)
+	if err != nil {
+		return nil, dbError(err, "error checking wallet %s", walletUUID)
+	}
+	if !exists {
+		return nil, fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+
+	rows, err := r.DB.Query(ctx, `
+        WITH held AS (
+            SELECT currency, SUM(amount) AS amount FROM holds
+            WHERE wallet_uuid = $1
+              AND status = 'ACTIVE'
+              AND expires_at > now()
+            GROUP BY currency
+        )
+        SELECT c.code, c.scale, COALESCE(b.balance, 0), COALESCE(h.amount, 0)
+        FROM currencies c
+        LEFT JOIN wallet_balances b ON b.wallet_uuid = $1 AND b.currency = c.code
+        LEFT JOIN held h ON h.currency = c.code
+        WHERE CASE WHEN $2 = '' THEN b.currency IS NOT NULL ELSE c.code = $2 END
+        ORDER BY c.code`,
+		walletUUID, currency)
+	if err != nil {
+		return nil, dbError(err, "error getting wallet %s balances", walletUUID)
+	}
+	defer rows.Close()
+
+	balances := []model.Balance{}
+	for rows.Next() {
+		var balance model.Balance
+		if err = rows.Scan(&balance.Currency, &balance.Scale, &balance.Balance, &balance.Held); err != nil {
+			return nil, dbError(err, "error scanning wallet %s balance", walletUUID)
+		}
+		balance.Available = balance.Balance - balance.Held
+		balances = append(balances, balance)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting wallet %s balances", walletUUID)
+	}
+
+	if currency != "" && len(balances) == 0 {
+		return nil, fmt.Errorf("currency %s: %w", currency, ErrUnsupportedCurrency)
+	}
+
+	return balances, nil
+}
+
+/*
+Валюта по коду
+
+Принимает:
+
+code string - код валюты
+
+Возвращает:
+
+currency *model.Currency - валюта с её масштабом
+
+error - error, ErrUnsupportedCurrency для неизвестной валюты
 */
-func (r *WalletRepo) Balance(ctx context.Context, walletUUID string) (int64, error) {
+func (r *WalletRepo) Currency(ctx context.Context, code string) (*model.Currency, error) {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	var balance int64
+	currency := model.Currency{Code: code}
 	err := r.DB.QueryRow(ctx, `
-        SELECT balance FROM wallets 
-        WHERE uuid = $1`,
-		walletUUID).Scan(&balance)
+        SELECT scale FROM currencies
+        WHERE code = $1`,
+		code).Scan(&currency.Scale)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("currency %s: %w", code, ErrUnsupportedCurrency)
+	}
 	if err != nil {
-		return 0, fmt.Errorf("error getting wallet %s balance: %v", walletUUID, err)
+		return nil, dbError(err, "error getting currency %s", code)
 	}
 
-	return balance, nil
+	return &currency, nil
 }
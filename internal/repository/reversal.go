@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+/*
+Сторно операции - компенсирующая запись журнала, полностью или частично
+
+Сторнировать можно DEPOSIT, WITHDRAW и перевод (по id любой из двух его частей,
+сторнируются обе). По каждой операции суммарно можно сторнировать не больше её суммы.
+Если сторно списывает средства с кошелька, действует то же правило, что и для WITHDRAW:
+списать можно только доступный баланс
+
+Принимает:
+
+transactionId string - id исходной операции
+
+amount model.Money - сумма сторно, 0 - весь ещё не сторнированный остаток
+
+Возвращает:
+
+result *model.ReversalResult - операции сторно и остаток, который ещё можно сторнировать
+
+error - error
+*/
+func (r *WalletRepo) Reverse(ctx context.Context, transactionId string, amount model.Money) (*model.ReversalResult, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.Reverse",
+		attribute.String("transaction.id", transactionId),
+		attribute.String("wallet.operation_type", "REVERSAL"),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	// записи журнала операций не меняются, поэтому читаем их без блокировки
+	legs, err := reversibleLegs(ctx, tx, transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	// блокировки кошельков в порядке возрастания UUID, как в Transfer. Они же не дают
+	// двум параллельным сторно одной операции превысить её сумму
+	walletUUIDs := make([]string, 0, len(legs))
+	for _, leg := range legs {
+		walletUUIDs = append(walletUUIDs, leg.WalletId)
+	}
+	sort.Strings(walletUUIDs)
+
+	// у перевода обе части сторнируются вместе и в одной валюте, поэтому остаток считаем по первой
+	original := legs[0]
+	currency := original.Currency
+
+	balances := make(map[string]model.Money, len(walletUUIDs))
+	states := make(map[string]walletState, len(walletUUIDs))
+	for _, walletUUID := range walletUUIDs {
+		balance, state, err := lockBalance(ctx, tx, walletUUID, currency)
+		if err != nil {
+			return nil, err
+		}
+		balances[walletUUID], states[walletUUID] = balance, state
+	}
+
+	var reversed model.Money
+	err = tx.QueryRow(ctx, `
+        SELECT COALESCE(SUM(amount), 0) FROM transactions
+        WHERE reversal_of = $1`,
+		original.Id).Scan(&reversed)
+	if err != nil {
+		return nil, dbError(err, "error getting reversed amount of transaction %s", original.Id)
+	}
+
+	remaining := original.Amount - reversed
+	if remaining <= 0 {
+		return nil, fmt.Errorf("transaction %s: %w", transactionId, ErrAlreadyReversed)
+	}
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount > remaining {
+		return nil, fmt.Errorf("%w: reversal of %d exceeds remaining %d of transaction %s",
+			ErrInvalidOperation, amount, remaining, transactionId)
+	}
+
+	reversalId := uuid.New().String()
+	result := &model.ReversalResult{
+		ReversalId:            reversalId,
+		OriginalTransactionId: transactionId,
+		Amount:                amount,
+		Remaining:             remaining - amount,
+		Transactions:          make([]model.Transaction, 0, len(legs)),
+	}
+
+	postings := make([]posting, 0, 2)
+	for _, leg := range legs {
+		// сторно двигает деньги в обратную сторону относительно исходной операции
+		delta := amount
+		if leg.OperationType == "DEPOSIT" || leg.OperationType == "TRANSFER_IN" {
+			delta = -amount
+		}
+
+		balance := balances[leg.WalletId]
+		if delta > 0 {
+			if err = states[leg.WalletId].checkCredit(leg.WalletId); err != nil {
+				return nil, err
+			}
+		} else {
+			if err = states[leg.WalletId].checkDebit(leg.WalletId); err != nil {
+				return nil, err
+			}
+			held, err := heldAmount(ctx, tx, leg.WalletId, currency)
+			if err != nil {
+				return nil, err
+			}
+			if amount > balance-held {
+				return nil, &InsufficientFundsError{WalletId: leg.WalletId, Currency: currency, Balance: balance - held, Requested: amount}
+			}
+		}
+
+		balanceAfter, err := addBalance(leg.WalletId, currency, balance, delta)
+		if err != nil {
+			return nil, err
+		}
+
+		postings = append(postings, posting{accountId: leg.WalletId, currency: currency, amount: delta})
+		result.Transactions = append(result.Transactions, model.Transaction{
+			Id:            uuid.New().String(),
+			WalletId:      leg.WalletId,
+			OperationType: "REVERSAL",
+			Currency:      currency,
+			Amount:        amount,
+			BalanceBefore: balance,
+			BalanceAfter:  balanceAfter,
+			EntryId:       &reversalId,
+			ReversalOf:    &leg.Id,
+		})
+	}
+	// DEPOSIT и WITHDRAW проводились против системного счёта, сторно тоже
+	if len(legs) == 1 {
+		postings = append(postings, posting{accountId: r.systemAccountId, currency: currency, amount: -postings[0].amount})
+	}
+
+	if err = postEntry(ctx, tx, reversalId, "REVERSAL", postings...); err != nil {
+		return nil, err
+	}
+
+	for i := range result.Transactions {
+		t := &result.Transactions[i]
+		if err = setBalance(ctx, tx, t.WalletId, currency, t.BalanceAfter); err != nil {
+			return nil, err
+		}
+		if err = insertTransaction(ctx, tx, t); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Transaction reversed", "transaction_id", transactionId, "currency", currency, "amount", amount, "remaining", result.Remaining)
+	return result, nil
+}
+
+// Части операции, которые сторнируются вместе: сама операция или обе части перевода,
+// первой всегда идёт TRANSFER_OUT
+func reversibleLegs(ctx context.Context, tx pgx.Tx, transactionId string) ([]model.Transaction, error) {
+	var t model.Transaction
+	err := tx.QueryRow(ctx, `
+        SELECT `+transactionColumns+` FROM transactions
+        WHERE id = $1`,
+		transactionId).Scan(transactionDest(&t)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("transaction %s: %w", transactionId, ErrTransactionNotFound)
+	}
+	if err != nil {
+		return nil, dbError(err, "error getting transaction %s", transactionId)
+	}
+
+	switch t.OperationType {
+	case "DEPOSIT", "WITHDRAW":
+		return []model.Transaction{t}, nil
+	case "TRANSFER_OUT", "TRANSFER_IN":
+	default:
+		return nil, fmt.Errorf("%w: %s transaction %s cannot be reversed", ErrInvalidOperation, t.OperationType, transactionId)
+	}
+
+	rows, err := tx.Query(ctx, `
+        SELECT `+transactionColumns+` FROM transactions
+        WHERE transfer_id = $1
+        ORDER BY operation_type DESC`,
+		t.TransferId)
+	if err != nil {
+		return nil, dbError(err, "error getting transfer %s", *t.TransferId)
+	}
+	defer rows.Close()
+
+	legs := make([]model.Transaction, 0, 2)
+	for rows.Next() {
+		var leg model.Transaction
+		if err = rows.Scan(transactionDest(&leg)...); err != nil {
+			return nil, dbError(err, "error scanning transaction")
+		}
+		legs = append(legs, leg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting transfer %s", *t.TransferId)
+	}
+	if len(legs) != 2 {
+		return nil, fmt.Errorf("transfer %s has %d parts instead of 2", *t.TransferId, len(legs))
+	}
+
+	return legs, nil
+}
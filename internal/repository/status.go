@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/tracing"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Статусы кошелька
+const (
+	WalletActive = "ACTIVE"
+	WalletFrozen = "FROZEN"
+	WalletClosed = "CLOSED"
+)
+
+// Разрешённые смены статуса. Закрытый кошелёк закрыт навсегда, замороженный перед закрытием
+// нужно разморозить: закрытие не должно обходить заморозку
+var walletTransitions = map[string][]string{
+	WalletActive: {WalletFrozen, WalletClosed},
+	WalletFrozen: {WalletActive},
+}
+
+// Колонки смены статуса в порядке statusChangeDest
+const statusChangeColumns = `id, wallet_uuid, previous_status, status, deposits_blocked, actor, reason, created_at`
+
+func statusChangeDest(c *model.WalletStatusChange) []any {
+	return []any{&c.Id, &c.WalletId, &c.PreviousStatus, &c.Status, &c.DepositsBlocked, &c.Actor, &c.Reason, &c.CreatedAt}
+}
+
+// Статус кошелька, прочитанный под блокировкой его строки
+type walletState struct {
+	status          string
+	depositsBlocked bool
+}
+
+// Можно ли списать средства кошелька или зарезервировать их холдом
+func (w walletState) checkDebit(walletUUID string) error {
+	switch w.status {
+	case WalletClosed:
+		return fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletClosed)
+	case WalletFrozen:
+		return fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletFrozen)
+	}
+	return nil
+}
+
+// Можно ли зачислить средства на кошелёк: замороженный их получает, если при заморозке не закрыли зачисления
+func (w walletState) checkCredit(walletUUID string) error {
+	switch {
+	case w.status == WalletClosed:
+		return fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletClosed)
+	case w.status == WalletFrozen && w.depositsBlocked:
+		return fmt.Errorf("wallet %s: %w, deposits are blocked", walletUUID, ErrWalletFrozen)
+	}
+	return nil
+}
+
+// Операции без движения денег (отмена холда) запрещены только закрытому кошельку
+func (w walletState) checkOpen(walletUUID string) error {
+	if w.status == WalletClosed {
+		return fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletClosed)
+	}
+	return nil
+}
+
+// Проверка, что из текущего статуса кошелька можно перейти в change.Status
+func checkTransition(change *model.WalletStatusChange, state walletState) error {
+	if !slices.Contains(walletTransitions[state.status], change.Status) {
+		return fmt.Errorf("%w: wallet %s is %s, cannot become %s", ErrInvalidTransition, change.WalletId, state.status, change.Status)
+	}
+	return nil
+}
+
+/*
+Смена статуса кошелька: заморозка, разморозка или закрытие
+
+Статус меняется под той же блокировкой строки кошелька FOR UPDATE, под которой операции его проверяют,
+поэтому операция, начатая до заморозки, успевает завершиться, а начатая после - видит новый статус.
+Каждая смена записывается в wallet_status_changes с тем, кто и почему её сделал
+
+Принимает:
+
+change *model.WalletStatusChange - кошелёк, новый статус, DepositsBlocked (только для FROZEN), Actor и Reason.
+Id, PreviousStatus и CreatedAt заполняются здесь
+
+Возвращает:
+
+change *model.WalletStatusChange - записанная смена статуса
+
+error - error, ErrInvalidTransition если из текущего статуса в новый перейти нельзя,
+ErrWalletNotEmpty если при закрытии баланс в какой-то валюте не нулевой
+*/
+func (r *WalletRepo) ChangeWalletStatus(ctx context.Context, change *model.WalletStatusChange) (*model.WalletStatusChange, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.ChangeWalletStatus",
+		attribute.String("wallet.id", change.WalletId),
+		attribute.String("wallet.status", change.Status),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	state, err := lockWallet(ctx, tx, change.WalletId)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkTransition(change, state); err != nil {
+		return nil, err
+	}
+
+	if change.Status == WalletClosed {
+		// холды резервируют часть баланса, так что при нулевых балансах активных холдов тоже нет
+		var nonEmpty bool
+		err = tx.QueryRow(ctx, `
+            SELECT EXISTS (SELECT 1 FROM wallet_balances WHERE wallet_uuid = $1 AND balance <> 0)`,
+			change.WalletId).Scan(&nonEmpty)
+		if err != nil {
+			return nil, dbError(err, "error checking wallet %s balances", change.WalletId)
+		}
+		if nonEmpty {
+			return nil, fmt.Errorf("wallet %s: %w", change.WalletId, ErrWalletNotEmpty)
+		}
+	}
+
+	saved := *change
+	saved.Id = uuid.New().String()
+	saved.PreviousStatus = state.status
+	saved.DepositsBlocked = change.Status == WalletFrozen && change.DepositsBlocked
+
+	_, err = tx.Exec(ctx, `
+        UPDATE wallets
+        SET status = $2, deposits_blocked = $3
+        WHERE uuid = $1`,
+		saved.WalletId, saved.Status, saved.DepositsBlocked)
+	if err != nil {
+		return nil, dbError(err, "error updating wallet %s status", saved.WalletId)
+	}
+
+	err = tx.QueryRow(ctx, `
+        INSERT INTO wallet_status_changes (id, wallet_uuid, previous_status, status, deposits_blocked, actor, reason)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING `+statusChangeColumns,
+		saved.Id, saved.WalletId, saved.PreviousStatus, saved.Status, saved.DepositsBlocked, saved.Actor, saved.Reason).Scan(statusChangeDest(&saved)...)
+	if err != nil {
+		return nil, dbError(err, "error recording wallet %s status change", saved.WalletId)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Wallet status changed", "wallet_id", saved.WalletId, "previous_status", saved.PreviousStatus,
+		"status", saved.Status, "deposits_blocked", saved.DepositsBlocked, "actor", saved.Actor, "reason", saved.Reason)
+	return &saved, nil
+}
+
+/*
+История смен статуса кошелька, от старых к новым
+
+Принимает:
+
+walletUUID string - UUID кошелька
+
+Возвращает:
+
+changes []model.WalletStatusChange - смены статуса, пустой список если статус не менялся
+
+error - error, ErrWalletNotFound если кошелька нет
+*/
+func (r *WalletRepo) WalletStatusHistory(ctx context.Context, walletUUID string) ([]model.WalletStatusChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err := r.DB.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM wallets WHERE uuid = $1)`,
+		walletUUID).Scan(&exists)
+	if err != nil {
+		return nil, dbError(err, "error checking wallet %s", walletUUID)
+	}
+	if !exists {
+		return nil, fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+
+	rows, err := r.DB.Query(ctx, `
+        SELECT `+statusChangeColumns+` FROM wallet_status_changes
+        WHERE wallet_uuid = $1
+        ORDER BY created_at, id`,
+		walletUUID)
+	if err != nil {
+		return nil, dbError(err, "error getting wallet %s status history", walletUUID)
+	}
+	defer rows.Close()
+
+	changes := make([]model.WalletStatusChange, 0)
+	for rows.Next() {
+		var change model.WalletStatusChange
+		if err = rows.Scan(statusChangeDest(&change)...); err != nil {
+			return nil, dbError(err, "error scanning wallet %s status change", walletUUID)
+		}
+		changes = append(changes, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting wallet %s status history", walletUUID)
+	}
+
+	return changes, nil
+}
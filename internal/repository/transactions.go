@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Размер страницы истории по умолчанию, если limit не передан
+const defaultTransactionsLimit = 50
+
+/*
+История операций кошелька, от новых к старым, с пагинацией по курсору
+
+Курсор - пара (created_at, id) последней записи страницы, так страницы не съезжают,
+даже если между запросами появляются новые операции
+
+Принимает:
+
+walletUUID string - UUID кошелька
+
+query model.TransactionsQuery - фильтры и параметры страницы
+
+Возвращает:
+
+page *model.TransactionsPage - страница истории и курсор следующей страницы
+
+error - error
+*/
+func (r *WalletRepo) Transactions(ctx context.Context, walletUUID string, query model.TransactionsQuery) (*model.TransactionsPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err := r.DB.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM wallets WHERE uuid = $1)`,
+		walletUUID).Scan(&exists)
+	if err != nil {
+		return nil, dbError(err, "error checking wallet %s", walletUUID)
+	}
+	if !exists {
+		return nil, fmt.Errorf("wallet %s: %w", walletUUID, ErrWalletNotFound)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultTransactionsLimit
+	}
+
+	sql := `
+        SELECT ` + transactionColumns + `
+        FROM transactions
+        WHERE wallet_uuid = $1`
+	args := []any{walletUUID}
+
+	// добавляет условие с очередным плейсхолдером
+	where := func(condition string, value any) {
+		args = append(args, value)
+		sql += fmt.Sprintf(" AND "+condition, len(args))
+	}
+
+	if query.Cursor != "" {
+		cursorTime, cursorId, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, cursorTime, cursorId)
+		sql += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	if query.OperationType != "" {
+		where("operation_type = $%d", query.OperationType)
+	}
+	if query.Currency != "" {
+		where("currency = $%d", query.Currency)
+	}
+	if query.MinAmount != nil {
+		where("amount >= $%d", *query.MinAmount)
+	}
+	if query.MaxAmount != nil {
+		where("amount <= $%d", *query.MaxAmount)
+	}
+	if query.From != nil {
+		where("created_at >= $%d", *query.From)
+	}
+	if query.To != nil {
+		where("created_at < $%d", *query.To)
+	}
+
+	// берём на одну запись больше, чтобы понять, есть ли следующая страница
+	args = append(args, limit+1)
+	sql += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := r.DB.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, dbError(err, "error getting wallet %s transactions", walletUUID)
+	}
+	defer rows.Close()
+
+	page := &model.TransactionsPage{Transactions: []model.Transaction{}}
+	for rows.Next() {
+		var t model.Transaction
+		err = rows.Scan(transactionDest(&t)...)
+		if err != nil {
+			return nil, dbError(err, "error scanning transaction")
+		}
+		page.Transactions = append(page.Transactions, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err, "error getting wallet %s transactions", walletUUID)
+	}
+
+	if len(page.Transactions) > limit {
+		page.Transactions = page.Transactions[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.Id)
+	}
+
+	return page, nil
+}
+
+// Курсор кодируется в base64, чтобы клиенты не полагались на его формат
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	createdAtStr, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	if _, err = uuid.Parse(id); err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	return createdAt, id, nil
+}
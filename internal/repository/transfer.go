@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/tracing"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+/*
+Перевод между кошельками одной транзакцией
+
+Обе строки блокируются FOR UPDATE в порядке возрастания UUID, поэтому встречные переводы
+A->B и B->A не могут заблокировать друг друга
+
+Принимает:
+
+fromUUID string - UUID кошелька, с которого списываются средства
+
+toUUID string - UUID кошелька, на который зачисляются средства
+
+currency string - код валюты перевода, списывается и зачисляется одна и та же валюта
+
+amount model.Money - сумма перевода в минимальных единицах валюты
+
+Возвращает:
+
+result *model.TransferResult - обе записи журнала операций, связанные общим transferId
+
+error - error
+*/
+func (r *WalletRepo) Transfer(ctx context.Context, fromUUID, toUUID, currency string, amount model.Money) (*model.TransferResult, error) {
+	ctx, span := tracing.Start(ctx, "WalletRepo.Transfer",
+		attribute.String("wallet.id", fromUUID),
+		attribute.String("wallet.to_id", toUUID),
+		attribute.String("wallet.operation_type", "TRANSFER"),
+		attribute.String("wallet.currency", currency),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// сортировка ниже сравнивает строки, поэтому приводим UUID к каноническому виду
+	fromUUID, toUUID = strings.ToLower(fromUUID), strings.ToLower(toUUID)
+	if fromUUID == toUUID {
+		return nil, fmt.Errorf("%w: transfer to the same wallet %s", ErrInvalidOperation, fromUUID)
+	}
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	// UUID в нижнем регистре сравниваются как строки так же, как в postgres
+	first, second := fromUUID, toUUID
+	if second < first {
+		first, second = second, first
+	}
+
+	balances := make(map[string]model.Money, 2)
+	states := make(map[string]walletState, 2)
+	for _, walletUUID := range []string{first, second} {
+		balance, state, err := lockBalance(ctx, tx, walletUUID, currency)
+		if err != nil {
+			return nil, err
+		}
+		balances[walletUUID], states[walletUUID] = balance, state
+	}
+	if err = states[fromUUID].checkDebit(fromUUID); err != nil {
+		return nil, err
+	}
+	if err = states[toUUID].checkCredit(toUUID); err != nil {
+		return nil, err
+	}
+
+	fromBalance, toBalance := balances[fromUUID], balances[toUUID]
+	held, err := heldAmount(ctx, tx, fromUUID, currency)
+	if err != nil {
+		return nil, err
+	}
+	if amount > fromBalance-held {
+		return nil, &InsufficientFundsError{WalletId: fromUUID, Currency: currency, Balance: fromBalance - held, Requested: amount}
+	}
+
+	toBalanceAfter, err := addBalance(toUUID, currency, toBalance, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	transferId := uuid.New().String()
+	result := &model.TransferResult{
+		TransferId: transferId,
+		Debit: model.Transaction{
+			Id:            uuid.New().String(),
+			WalletId:      fromUUID,
+			OperationType: "TRANSFER_OUT",
+			Currency:      currency,
+			Amount:        amount,
+			BalanceBefore: fromBalance,
+			BalanceAfter:  fromBalance - amount,
+			TransferId:    &transferId,
+			EntryId:       &transferId,
+		},
+		Credit: &model.Transaction{
+			Id:            uuid.New().String(),
+			WalletId:      toUUID,
+			OperationType: "TRANSFER_IN",
+			Currency:      currency,
+			Amount:        amount,
+			BalanceBefore: toBalance,
+			BalanceAfter:  toBalanceAfter,
+			TransferId:    &transferId,
+			EntryId:       &transferId,
+		},
+	}
+
+	// запись журнала перевода - id совпадает с transferId, проводки только между кошельками
+	err = postEntry(ctx, tx, transferId, "TRANSFER",
+		posting{accountId: fromUUID, currency: currency, amount: -amount},
+		posting{accountId: toUUID, currency: currency, amount: amount})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range []*model.Transaction{&result.Debit, result.Credit} {
+		if err = setBalance(ctx, tx, t.WalletId, currency, t.BalanceAfter); err != nil {
+			return nil, err
+		}
+		if err = insertTransaction(ctx, tx, t); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Transfer completed", "transfer_id", transferId, "currency", currency, "amount", amount, "from_wallet_id", fromUUID, "to_wallet_id", toUUID)
+	return result, nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"WalletAPI/m/internal/model"
+	"context"
+	"time"
+)
+
+// Колонки кошелька в порядке walletDest
+const walletColumns = `uuid, status, deposits_blocked, external_owner_id, display_name, metadata, labels`
+
+func walletDest(w *model.Wallet) []any {
+	// metadata сканируется как []byte: NULL остаётся nil, а не превращается в JSON null
+	return []any{&w.WalletId, &w.Status, &w.DepositsBlocked, &w.ExternalOwnerId, &w.DisplayName, (*[]byte)(&w.Metadata), &w.Labels}
+}
+
+// labels в БД NOT NULL, пустой список вместо nil
+func labelsOrEmpty(labels []string) []string {
+	if labels == nil {
+		return []string{}
+	}
+	return labels
+}
+
+/*
+Кошелёк со статусом и данными клиента, без балансов
+
+Принимает:
+
+walletUUID string - UUID кошелька
+
+Возвращает:
+
+wallet *model.Wallet - кошелёк
+
+error - error, ErrWalletNotFound если кошелька нет
+*/
+func (r *WalletRepo) Wallet(ctx context.Context, walletUUID string) (*model.Wallet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var wallet model.Wallet
+	err := r.DB.QueryRow(ctx, `
+        SELECT `+walletColumns+` FROM wallets
+        WHERE uuid = $1`,
+		walletUUID).Scan(walletDest(&wallet)...)
+	if err != nil {
+		return nil, walletError(err, walletUUID, "error getting wallet %s", walletUUID)
+	}
+
+	return &wallet, nil
+}
+
+/*
+Изменение данных кошелька: внешнего id владельца, названия, metadata и labels
+
+Переданные поля заменяются целиком, nil - поле не меняется. Metadata со значением JSON null очищается.
+Строка кошелька блокируется, как при операциях, поэтому закрытие кошелька и изменение не пересекаются
+
+Принимает:
+
+walletUUID string - UUID кошелька
+
+patch model.UpdateWallet - новые значения полей
+
+Возвращает:
+
+wallet *model.Wallet - кошелёк после изменения
+
+error - error, ErrWalletClosed если кошелёк закрыт
+*/
+func (r *WalletRepo) UpdateWallet(ctx context.Context, walletUUID string, patch model.UpdateWallet) (*model.Wallet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err, "error starting transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	state, err := lockWallet(ctx, tx, walletUUID)
+	if err != nil {
+		return nil, err
+	}
+	if err = state.checkOpen(walletUUID); err != nil {
+		return nil, err
+	}
+
+	var labels *[]string
+	if patch.Labels != nil {
+		nonNil := labelsOrEmpty(*patch.Labels)
+		labels = &nonNil
+	}
+
+	var wallet model.Wallet
+	err = tx.QueryRow(ctx, `
+        UPDATE wallets
+        SET external_owner_id = COALESCE($2::text, external_owner_id),
+            display_name = COALESCE($3::text, display_name),
+            metadata = CASE WHEN $4::boolean THEN $5::jsonb ELSE metadata END,
+            labels = COALESCE($6::text[], labels)
+        WHERE uuid = $1
+        RETURNING `+walletColumns,
+		walletUUID, patch.ExternalOwnerId, patch.DisplayName, patch.Metadata != nil, nullableMetadata(patch.Metadata), labels).Scan(walletDest(&wallet)...)
+	if err != nil {
+		return nil, dbError(err, "error updating wallet %s", walletUUID)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err, "error committing transaction")
+	}
+
+	r.logger.InfoContext(ctx, "Wallet updated", "wallet_id", walletUUID, "external_owner_id", wallet.ExternalOwnerId)
+	return &wallet, nil
+}
+
+// JSON null в metadata хранится как NULL в колонке, а не как jsonb 'null'
+func nullableMetadata(metadata []byte) []byte {
+	if string(metadata) == "null" {
+		return nil
+	}
+	return metadata
+}
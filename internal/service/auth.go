@@ -0,0 +1,280 @@
+package service
+
+import (
+	"WalletAPI/m/internal/auth"
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+
+	// Префикс ключей клиентов, по нему ключ легко найти в утёкшем конфиге или логе
+	apiKeyPrefix = "wk_"
+
+	// Сколько первых символов ключа хранится открыто, чтобы отличать ключи в списке
+	apiKeyVisible = 10
+
+	// Ключи gin.Context, под которыми лежат аутентифицированный клиент и его права
+	clientContextKey = "apiClient"
+	scopesContextKey = "scopes"
+)
+
+// SHA-256 ключа в hex, так ключ хранится в БД
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Новый ключ клиента: префикс и 32 случайных байта
+func newAPIKey() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("error generating api key: %v", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+/*
+Middleware: клиент запроса по JWT из Authorization: Bearer или по ключу из X-API-Key, без них 401
+
+У JWT права из scope, а sub - id клиента API: кошельки, созданные по токену, принадлежат
+этому клиенту, отзыв клиента отзывает и его токены. У статического ключа все права
+*/
+func (api *WalletAPI) authenticate(c *gin.Context) {
+	if token, ok := bearerToken(c); ok {
+		api.authenticateJWT(c, token)
+		return
+	}
+
+	key := c.GetHeader(apiKeyHeader)
+	if key == "" {
+		api.logger.WarnContext(c.Request.Context(), "Request without API key", "path", c.Request.URL.Path)
+		api.unauthorized(c, "", "API key or bearer token required")
+		return
+	}
+
+	client, err := api.Store.ClientByKeyHash(c.Request.Context(), hashAPIKey(key))
+	if errors.Is(err, repository.ErrClientNotFound) {
+		api.logger.WarnContext(c.Request.Context(), "Invalid or revoked API key", "key_prefix", visiblePrefix(key))
+		api.unauthorized(c, "", "Invalid API key")
+		return
+	}
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to authenticate API key", "error", err)
+		repoErrorResponse(c, err)
+		c.Abort()
+		return
+	}
+
+	c.Set(clientContextKey, client)
+	c.Set(scopesContextKey, auth.AllScopes)
+	c.Next()
+}
+
+// Аутентификация по JWT, клиент из sub должен существовать и не быть отозван
+func (api *WalletAPI) authenticateJWT(c *gin.Context, token string) {
+	if api.jwt == nil {
+		api.logger.WarnContext(c.Request.Context(), "Bearer token while JWT authentication is disabled")
+		api.unauthorized(c, "", "JWT authentication is disabled")
+		return
+	}
+
+	claims, err := api.jwt.Verify(c.Request.Context(), token)
+	if err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid bearer token", "error", err)
+		api.unauthorized(c, `error="invalid_token"`, "Invalid bearer token")
+		return
+	}
+
+	if _, err = uuid.Parse(claims.Subject); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Bearer token subject is not a client id", "sub", claims.Subject)
+		api.unauthorized(c, `error="invalid_token"`, "Invalid bearer token")
+		return
+	}
+	client, err := api.Store.ClientById(c.Request.Context(), claims.Subject)
+	if errors.Is(err, repository.ErrClientNotFound) {
+		api.logger.WarnContext(c.Request.Context(), "Bearer token of unknown or revoked client", "client_id", claims.Subject)
+		api.unauthorized(c, `error="invalid_token"`, "Invalid bearer token")
+		return
+	}
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to authenticate bearer token", "error", err)
+		repoErrorResponse(c, err)
+		c.Abort()
+		return
+	}
+
+	c.Set(clientContextKey, client)
+	c.Set(scopesContextKey, claims.Scopes())
+	c.Next()
+}
+
+// Токен из Authorization: Bearer, false если заголовка нет или схема другая
+func bearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// Ответ 401 с WWW-Authenticate (RFC 6750), params - параметры ошибки Bearer, если они есть
+func (api *WalletAPI) unauthorized(c *gin.Context, params, message string) {
+	if api.jwt != nil {
+		challenge := `Bearer realm="walletapi"`
+		if params != "" {
+			challenge += ", " + params
+		}
+		c.Header("WWW-Authenticate", challenge)
+	}
+	errorResponse(c, http.StatusUnauthorized, model.ErrCodeUnauthorized, message)
+	c.Abort()
+}
+
+// Middleware маршрута: у клиента есть все перечисленные права
+func (api *WalletAPI) requireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !api.authorizeScopes(c, scopes...) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Middleware маршрута: у клиента есть хотя бы одно из прав. Для ручек, где нужное право
+// зависит от тела запроса: точная проверка в обработчике через authorizeScopes
+func (api *WalletAPI) requireAnyScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := scopesOf(c)
+		for _, scope := range scopes {
+			if auth.HasScopes(granted, scope) {
+				c.Next()
+				return
+			}
+		}
+		api.forbidScope(c, scopes)
+		c.Abort()
+	}
+}
+
+/*
+Проверка, что у клиента запроса есть все перечисленные права
+
+Возвращает true, если права есть, иначе ответ 403 уже отправлен
+*/
+func (api *WalletAPI) authorizeScopes(c *gin.Context, scopes ...string) bool {
+	if auth.HasScopes(scopesOf(c), scopes...) {
+		return true
+	}
+	api.forbidScope(c, scopes)
+	return false
+}
+
+func (api *WalletAPI) forbidScope(c *gin.Context, scopes []string) {
+	required := strings.Join(scopes, " ")
+	api.logger.WarnContext(c.Request.Context(), "Insufficient scope", "client_id", clientOf(c).Id, "required", required, "granted", strings.Join(scopesOf(c), " "))
+
+	// права бывают только у JWT, поэтому подсказка в формате Bearer (RFC 6750)
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, required))
+	problem := newProblem(c, http.StatusForbidden, model.ErrCodeInsufficientScope, "Insufficient scope")
+	problem.Detail = "Token lacks scope " + required
+	writeProblem(c, problem)
+}
+
+// Право, нужное для операции DEPOSIT/WITHDRAW. Для неизвестной операции пустое:
+// её отклонит хранилище с INVALID_OPERATION
+func operationScope(operationType string) string {
+	switch operationType {
+	case "DEPOSIT":
+		return auth.ScopeDeposit
+	case "WITHDRAW":
+		return auth.ScopeWithdraw
+	}
+	return ""
+}
+
+// Middleware: только для ключа администратора из конфига, без него админские ручки выключены
+func (api *WalletAPI) requireAdmin(c *gin.Context) {
+	if api.adminKeyHash == "" {
+		api.logger.WarnContext(c.Request.Context(), "Admin API is disabled", "path", c.Request.URL.Path)
+		errorResponse(c, http.StatusForbidden, model.ErrCodeForbidden, "Admin API is disabled")
+		c.Abort()
+		return
+	}
+
+	// сравниваем хеши за постоянное время, чтобы ключ нельзя было подобрать по времени ответа
+	keyHash := hashAPIKey(c.GetHeader(apiKeyHeader))
+	if subtle.ConstantTimeCompare([]byte(keyHash), []byte(api.adminKeyHash)) != 1 {
+		api.logger.WarnContext(c.Request.Context(), "Invalid admin API key", "path", c.Request.URL.Path)
+		errorResponse(c, http.StatusUnauthorized, model.ErrCodeUnauthorized, "Invalid admin API key")
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
+// Аутентифицированный клиент запроса, есть во всех ручках за authenticate
+func clientOf(c *gin.Context) *model.ApiClient {
+	return c.MustGet(clientContextKey).(*model.ApiClient)
+}
+
+// Права клиента запроса
+func scopesOf(c *gin.Context) []string {
+	return c.MustGet(scopesContextKey).([]string)
+}
+
+/*
+Проверка доступа к кошелькам: все принадлежат клиенту запроса и укладываются в лимит запросов
+
+Несуществующие кошельки пропускаются: на них операция сама ответит 404. Кошельки без
+владельца (созданные до появления ключей) не принадлежат никому, пока администратор не назначит
+владельца через PUT /v1/admin/wallets/{id}/owner. Лимит проверяется после
+владельца, чтобы чужие запросы не тратили лимит кошелька
+
+Возвращает true, если доступ есть, иначе ответ 403 или 429 уже отправлен
+*/
+func (api *WalletAPI) checkWallets(c *gin.Context, walletUUIDs ...string) bool {
+	owners, err := api.Store.WalletOwners(c.Request.Context(), walletUUIDs)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to get wallet owners", "error", err)
+		repoErrorResponse(c, err)
+		return false
+	}
+
+	client := clientOf(c)
+	for _, walletUUID := range walletUUIDs {
+		owner, ok := owners[strings.ToLower(walletUUID)]
+		if ok && owner != client.Id {
+			api.logger.WarnContext(c.Request.Context(), "Access to wallet denied", "wallet_id", walletUUID, "client_id", client.Id)
+			problem := newProblem(c, http.StatusForbidden, model.ErrCodeForbidden, "Access to wallet denied")
+			problem.Detail = fmt.Sprintf("Wallet %s belongs to another client", walletUUID)
+			writeProblem(c, problem)
+			return false
+		}
+	}
+
+	return api.limitWallets(c, walletUUIDs...)
+}
+
+// Начало ключа для логов, весь ключ в лог попадать не должен
+func visiblePrefix(key string) string {
+	if len(key) > apiKeyVisible {
+		return key[:apiKeyVisible]
+	}
+	return key
+}
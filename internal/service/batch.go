@@ -0,0 +1,279 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/repository"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// UpdateBalanceBatch godoc
+// @Summary Apply a batch of balance operations
+// @Description Applies up to 10000 DEPOSIT/WITHDRAW operations in one request. The whole batch takes a fixed number of database queries regardless of its size,
+// @Description so a payout run of tens of thousands of operations is sent as a few requests of up to 10000 items; with idempotency keys a failed request can be resent as is.
+// @Description With atomic=true all operations run in one database transaction: if any fails, none is applied and the response has the status of that failure.
+// @Description Otherwise a failed operation does not affect the others and the response contains a result for each of them.
+// @Description idempotencyKey of an item works as for a single update: an already applied item is not applied again.
+// @Tags Wallets
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param request body model.UpdateBalanceBatch true "Batch of balance operations"
+// @Success 200 {object} model.Response{data=model.BatchResult} "Batch processed, see per-item results"
+// @Failure 400 {object} model.Response "Invalid request body, or an invalid operation or unsupported currency in an atomic batch"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "A wallet of the batch belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet of an atomic batch item not found"
+// @Failure 409 {object} model.Response "Insufficient funds, balance overflow, frozen or closed wallet for an atomic batch item"
+// @Failure 422 {object} model.Response "Idempotency key of an atomic batch item reused with a different request"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallet/batch [post]
+func (api *WalletAPI) UpdateBalanceBatch(c *gin.Context) {
+	var req model.UpdateBalanceBatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid batch request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	// права и владельца проверяем для всех операций до выполнения любой из них
+	for _, item := range req.Items {
+		if scope := operationScope(item.OperationType); scope != "" && !api.authorizeScopes(c, scope) {
+			return
+		}
+	}
+
+	// чужой кошелёк хотя бы в одной операции - отклоняем весь пакет, ничего не применяя
+	walletUUIDs := make([]string, 0, len(req.Items))
+	seen := make(map[string]bool, len(req.Items))
+	for _, item := range req.Items {
+		walletUUID := strings.ToLower(item.WalletId)
+		if !seen[walletUUID] {
+			seen[walletUUID] = true
+			walletUUIDs = append(walletUUIDs, walletUUID)
+		}
+	}
+	if !api.checkWallets(c, walletUUIDs...) {
+		return
+	}
+
+	idempotency := make([]*model.Idempotency, len(req.Items))
+	keys := make([]string, 0)
+	for i, item := range req.Items {
+		idem, err := idempotencyWithKey(clientOf(c).Id, item.IdempotencyKey, item)
+		if err != nil {
+			api.logger.WarnContext(c.Request.Context(), "Invalid idempotency key of batch item", "index", i, "error", err)
+			errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid idempotencyKey")
+			return
+		}
+		if idem != nil {
+			idempotency[i] = idem
+			keys = append(keys, idem.Key)
+		}
+	}
+
+	records, err := api.Store.IdempotentTransactions(c.Request.Context(), clientOf(c).Id, keys)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to check batch idempotency keys", "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	api.logger.InfoContext(c.Request.Context(), "Batch requested", "operations", len(req.Items), "atomic", req.Atomic)
+
+	if req.Atomic {
+		api.atomicBatch(c, req.Items, idempotency, records)
+	} else {
+		api.bestEffortBatch(c, req.Items, idempotency, records)
+	}
+}
+
+// Атомарный пакет: либо все операции, либо ни одной
+func (api *WalletAPI) atomicBatch(c *gin.Context, items []model.UpdateBalance, idempotency []*model.Idempotency, records map[string]repository.IdempotentRecord) {
+	result := model.BatchResult{Atomic: true, Results: make([]model.BatchItemResult, len(items))}
+
+	// атомарный пакет применяется целиком, поэтому повтором может быть только весь пакет
+	replayed := 0
+	for i := range items {
+		itemResult := batchReplay(c, i, idempotency[i], records)
+		if itemResult == nil {
+			continue
+		}
+		if !itemResult.Success {
+			api.logger.WarnContext(c.Request.Context(), "Idempotency key of batch item reused with a different request", "index", i)
+			api.writeBatchFailure(c, i, newProblem(c, itemResult.Status, itemResult.Code, itemResult.Error))
+			return
+		}
+		result.Results[i] = *itemResult
+		replayed++
+	}
+	if replayed == len(items) {
+		api.logger.InfoContext(c.Request.Context(), "Replaying atomic batch", "operations", len(items))
+		result.Succeeded = len(items)
+		c.Header(idempotencyReplayedHeader, "true")
+		c.JSON(http.StatusOK, model.Response{Success: true, Data: result})
+		return
+	}
+	if replayed > 0 {
+		api.logger.WarnContext(c.Request.Context(), "Atomic batch repeats already applied operations", "replayed", replayed, "operations", len(items))
+		problem := newProblem(c, http.StatusUnprocessableEntity, model.ErrCodeIdempotencyKeyReused, "Idempotency key was already used")
+		problem.Detail = fmt.Sprintf("%d of %d items were already applied by other requests", replayed, len(items))
+		writeProblem(c, problem)
+		return
+	}
+
+	transactions, err := api.Store.UpdateBatch(c.Request.Context(), items, idempotency)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to apply atomic batch", "error", err)
+		var itemErr *repository.BatchItemError
+		if !errors.As(err, &itemErr) {
+			repoErrorResponse(c, err)
+			return
+		}
+		// остальные операции пакета откатились, не дойдя до исхода, учитываем только упавшую
+		observeBalanceOperation(items[itemErr.Index].OperationType, items[itemErr.Index].Currency, items[itemErr.Index].Amount, err)
+		problem := repoProblem(c, err)
+		if problem.Code == model.ErrCodeTimeout {
+			c.Header("Retry-After", "1")
+		}
+		api.writeBatchFailure(c, itemErr.Index, problem)
+		return
+	}
+
+	for i, transaction := range transactions {
+		observeBalanceOperation(items[i].OperationType, items[i].Currency, items[i].Amount, nil)
+		result.Results[i] = model.BatchItemResult{Index: i, Success: true, TransactionId: transaction.Id}
+	}
+	result.Succeeded = len(transactions)
+
+	c.JSON(http.StatusOK, model.Response{Success: true, Data: result})
+}
+
+// Пакет без атомарности: ошибка одной операции не влияет на остальные. Все операции, которые
+// не повторы, применяются одним вызовом хранилища, а не по запросу на операцию
+func (api *WalletAPI) bestEffortBatch(c *gin.Context, items []model.UpdateBalance, idempotency []*model.Idempotency, records map[string]repository.IdempotentRecord) {
+	result := model.BatchResult{Results: make([]model.BatchItemResult, len(items))}
+
+	pending := make([]int, 0, len(items))
+	for i := range items {
+		if replay := batchReplay(c, i, idempotency[i], records); replay != nil {
+			result.Results[i] = *replay
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	if len(pending) > 0 {
+		pendingItems := make([]model.UpdateBalance, 0, len(pending))
+		pendingIdempotency := make([]*model.Idempotency, 0, len(pending))
+		for _, i := range pending {
+			pendingItems = append(pendingItems, items[i])
+			pendingIdempotency = append(pendingIdempotency, idempotency[i])
+		}
+
+		outcomes, err := api.Store.UpdateBatchBestEffort(c.Request.Context(), pendingItems, pendingIdempotency)
+		if err != nil {
+			api.logger.ErrorContext(c.Request.Context(), "Failed to apply batch", "operations", len(pending), "error", err)
+			repoErrorResponse(c, err)
+			return
+		}
+		for j, outcome := range outcomes {
+			i := pending[j]
+			result.Results[i] = api.batchOutcome(c, i, items[i], idempotency[i], outcome)
+		}
+	}
+
+	for _, itemResult := range result.Results {
+		if itemResult.Success {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+
+	api.logger.InfoContext(c.Request.Context(), "Batch processed", "operations", len(items), "succeeded", result.Succeeded, "failed", result.Failed)
+	c.JSON(http.StatusOK, model.Response{Success: true, Data: result})
+}
+
+// Результат одной операции пакета без атомарности по её исходу в хранилище
+func (api *WalletAPI) batchOutcome(c *gin.Context, index int, item model.UpdateBalance, idempotency *model.Idempotency, outcome repository.BatchOutcome) model.BatchItemResult {
+	ctx := c.Request.Context()
+	err := outcome.Err
+	if errors.Is(err, repository.ErrIdempotencyKeyExists) {
+		// ключ занят параллельным запросом или предыдущей операцией этого же пакета
+		requestHash, replayed, replayErr := api.Store.IdempotentTransaction(ctx, idempotency.ClientId, idempotency.Key)
+		if replayErr == nil && replayed != nil {
+			records := map[string]repository.IdempotentRecord{
+				idempotency.Key: {RequestHash: requestHash, Transaction: *replayed},
+			}
+			return *batchReplay(c, index, idempotency, records)
+		}
+	}
+	observeBalanceOperation(item.OperationType, item.Currency, item.Amount, err)
+	if err != nil {
+		api.logger.ErrorContext(ctx, "Batch item failed", "index", index, "wallet_id", item.WalletId, "error", err)
+		problem := repoProblem(c, err)
+		return model.BatchItemResult{Index: index, Status: problem.Status, Code: problem.Code, Error: problem.Title}
+	}
+
+	return model.BatchItemResult{Index: index, Success: true, TransactionId: outcome.Transaction.Id}
+}
+
+// Результат операции, уже выполненной с этим ключом, nil если ключа нет или у операции его нет
+func batchReplay(c *gin.Context, index int, idempotency *model.Idempotency, records map[string]repository.IdempotentRecord) *model.BatchItemResult {
+	if idempotency == nil {
+		return nil
+	}
+	record, ok := records[idempotency.Key]
+	if !ok {
+		return nil
+	}
+
+	if record.RequestHash != idempotency.RequestHash {
+		problem := newProblem(c, http.StatusUnprocessableEntity, model.ErrCodeIdempotencyKeyReused, "Idempotency key was already used with a different request")
+		return &model.BatchItemResult{Index: index, Status: problem.Status, Code: problem.Code, Error: problem.Title}
+	}
+
+	return &model.BatchItemResult{Index: index, Success: true, TransactionId: record.Transaction.Id, Replayed: true}
+}
+
+/*
+Ответ на ошибку атомарного пакета: статус и код ошибки операции, из-за которой пакет откатился
+
+В model.Response в data результат этой операции с её индексом, в application/problem+json
+индекс в detail
+*/
+func (api *WalletAPI) writeBatchFailure(c *gin.Context, index int, problem model.Problem) {
+	if problem.Detail == "" {
+		problem.Detail = fmt.Sprintf("Batch item %d failed, no operations were applied", index)
+	} else {
+		problem.Detail = fmt.Sprintf("Batch item %d failed, no operations were applied: %s", index, problem.Detail)
+	}
+
+	if c.NegotiateFormat(binding.MIMEJSON, problemJSON) == problemJSON {
+		writeProblem(c, problem)
+		return
+	}
+
+	c.JSON(problem.Status, model.Response{
+		Success: false,
+		Code:    problem.Code,
+		Error:   problem.Title,
+		Data: model.BatchResult{
+			Atomic: true,
+			Failed: 1,
+			Results: []model.BatchItemResult{
+				{Index: index, Status: problem.Status, Code: problem.Code, Error: problem.Title},
+			},
+		},
+	})
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateClient godoc
+// @Summary Create an API client
+// @Description Issues a new API key for a client. The key is returned only in this response, the service stores just its hash.
+// @Tags Admin
+// @Accept json
+// @Produce json,application/problem+json
+// @Security AdminKeyAuth
+// @Param request body model.CreateApiClient true "Create client request"
+// @Success 201 {object} model.Response{data=model.CreatedApiClient} "Client created successfully"
+// @Failure 400 {object} model.Response "Invalid request body"
+// @Failure 401 {object} model.Response "Invalid admin API key"
+// @Failure 403 {object} model.Response "Admin API is disabled"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /admin/clients [post]
+func (api *WalletAPI) CreateClient(c *gin.Context) {
+	var req model.CreateApiClient
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid client request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	key, err := newAPIKey()
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to generate API key", "error", err)
+		errorResponse(c, http.StatusInternalServerError, model.ErrCodeInternal, "Internal Error")
+		return
+	}
+
+	client, err := api.Store.CreateClient(c.Request.Context(), req.Name, hashAPIKey(key), visiblePrefix(key))
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to create API client", "name", req.Name, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Success: true,
+		Data:    model.CreatedApiClient{ApiClient: *client, ApiKey: key},
+	})
+}
+
+// ListClients godoc
+// @Summary List API clients
+// @Description Returns all API clients newest-first, including revoked ones. Keys are not returned, only their prefixes.
+// @Tags Admin
+// @Produce json,application/problem+json
+// @Security AdminKeyAuth
+// @Success 200 {object} model.Response{data=[]model.ApiClient} "Clients retrieved successfully"
+// @Failure 401 {object} model.Response "Invalid admin API key"
+// @Failure 403 {object} model.Response "Admin API is disabled"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /admin/clients [get]
+func (api *WalletAPI) ListClients(c *gin.Context) {
+	clients, err := api.Store.Clients(c.Request.Context())
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to list API clients", "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    clients,
+	})
+}
+
+// RevokeClient godoc
+// @Summary Revoke an API client
+// @Description Revokes the key of a client: requests with it get 401. Wallets of the client stay owned by it.
+// @Tags Admin
+// @Produce json,application/problem+json
+// @Security AdminKeyAuth
+// @Param CLIENT_ID path string true "Client ID"
+// @Success 200 {object} model.Response{data=model.ApiClient} "Client revoked successfully"
+// @Failure 400 {object} model.Response "Invalid client ID"
+// @Failure 401 {object} model.Response "Invalid admin API key"
+// @Failure 403 {object} model.Response "Admin API is disabled"
+// @Failure 404 {object} model.Response "Client not found"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /admin/clients/{CLIENT_ID} [delete]
+func (api *WalletAPI) RevokeClient(c *gin.Context) {
+	clientId := c.Param("CLIENT_ID")
+	if _, err := uuid.Parse(clientId); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid client ID", "client_id", clientId)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid client ID")
+		return
+	}
+
+	client, err := api.Store.RevokeClient(c.Request.Context(), clientId)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to revoke API client", "client_id", clientId, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    client,
+	})
+}
+
+// AssignWalletOwner godoc
+// @Summary Assign the owner of a wallet
+// @Description Makes the client the owner of the wallet: only it can use the wallet afterwards.
+// @Description Wallets created before API keys existed have no owner and cannot be used by any client until one is assigned here.
+// @Tags Admin
+// @Accept json
+// @Produce json,application/problem+json
+// @Security AdminKeyAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param request body model.AssignWalletOwner true "New owner"
+// @Success 200 {object} model.Response{data=model.WalletOwner} "Owner assigned successfully"
+// @Failure 400 {object} model.Response "Invalid wallet UUID or request body"
+// @Failure 401 {object} model.Response "Invalid admin API key"
+// @Failure 403 {object} model.Response "Admin API is disabled"
+// @Failure 404 {object} model.Response "Wallet not found, or client not found or revoked"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /admin/wallets/{WALLET_UUID}/owner [put]
+func (api *WalletAPI) AssignWalletOwner(c *gin.Context) {
+	walletUUID := c.Param("WALLET_UUID")
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet UUID", "wallet_id", walletUUID)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidWalletId, "Invalid wallet UUID")
+		return
+	}
+
+	var req model.AssignWalletOwner
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid assign owner request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	owner, err := api.Store.SetWalletOwner(c.Request.Context(), walletUUID, req.ClientId)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to assign wallet owner", "wallet_id", walletUUID, "client_id", req.ClientId, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    owner,
+	})
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/rates"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Источник курсов для обмена валют: в проде - внешний сервис, локально и в тестах - rates.Static
+type RateProvider interface {
+	// Средний курс: сколько основных единиц to дают за одну основную единицу from,
+	// rates.ErrRateNotFound - если пары нет
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+var _ RateProvider = (*rates.Static)(nil)
+
+// Источник курсов не ответил или выключен, в отличие от rates.ErrRateNotFound запрос можно повторить
+var errRatesUnavailable = errors.New("rate provider is unavailable")
+
+// GetQuote godoc
+// @Summary Quote a currency conversion
+// @Description Returns how much of the target currency a conversion of amount (minor units of from) gives at the current rate minus the spread.
+// @Description The quote id locks the rate for a short time: POST /wallets/{WALLET_UUID}/convert with it converts exactly these amounts, once.
+// @Tags Conversions
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param from query string true "Currency to convert from, e.g. USD"
+// @Param to query string true "Currency to convert to, e.g. EUR"
+// @Param amount query int true "Amount in minor units of from"
+// @Success 200 {object} model.Response{data=model.Quote} "Quote created successfully"
+// @Failure 400 {object} model.Response "Invalid query parameters, unsupported currency, no rate for the pair or amount too small"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Token lacks scope"
+// @Failure 429 {object} model.Response "Rate limit of the client exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Rate provider or database unavailable, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /quotes [get]
+func (api *WalletAPI) GetQuote(c *gin.Context) {
+	var query model.QuoteQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid quote query", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid query parameters")
+		return
+	}
+
+	quote, err := api.newQuote(c.Request.Context(), query.From, query.To, query.Amount)
+	if err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Failed to quote conversion", "from_currency", query.From, "to_currency", query.To, "error", err)
+		conversionErrorResponse(c, err)
+		return
+	}
+
+	quote.ClientId = clientOf(c).Id
+	quote, err = api.Store.CreateQuote(c.Request.Context(), quote)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to save quote", "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    quote,
+	})
+}
+
+// Convert godoc
+// @Summary Convert between currencies of a wallet
+// @Description Atomically debits one currency of the wallet (CONVERT_OUT) and credits another (CONVERT_IN).
+// @Description With quoteId the conversion uses the locked rate and amounts of that quote, which must belong to the caller, be unexpired and unused.
+// @Description Without it fromCurrency, toCurrency and amount are converted at the current rate minus the spread.
+// @Tags Conversions
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param request body model.Convert true "Conversion request"
+// @Success 200 {object} model.Response{data=model.ConversionResult} "Conversion completed successfully"
+// @Failure 400 {object} model.Response "Invalid request body, unsupported currency, no rate for the pair or amount too small"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet or quote not found"
+// @Failure 409 {object} model.Response "Insufficient funds, balance overflow, wallet frozen or closed, quote expired or already used"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Rate provider or database unavailable, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID}/convert [post]
+func (api *WalletAPI) Convert(c *gin.Context) {
+	walletUUID := c.Param("WALLET_UUID")
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet UUID", "wallet_id", walletUUID)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidWalletId, "Invalid wallet UUID")
+		return
+	}
+
+	var req model.Convert
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid conversion request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	// либо котировка, либо пара с суммой - проверяем здесь, в binding это неудобно
+	withQuote := req.QuoteId != ""
+	withPair := req.FromCurrency != "" || req.ToCurrency != "" || req.Amount != 0
+	if withQuote == withPair || withPair && (req.FromCurrency == "" || req.ToCurrency == "" || req.Amount == 0 || req.FromCurrency == req.ToCurrency) {
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if !api.checkWallets(c, walletUUID) {
+		return
+	}
+
+	var quote *model.Quote
+	var err error
+	if withQuote {
+		quote, err = api.Store.Quote(c.Request.Context(), req.QuoteId)
+		if err == nil && quote.ClientId != clientOf(c).Id {
+			// чужая котировка неотличима от несуществующей
+			api.logger.WarnContext(c.Request.Context(), "Quote belongs to another client", "quote_id", req.QuoteId, "client_id", clientOf(c).Id)
+			errorResponse(c, http.StatusNotFound, model.ErrCodeQuoteNotFound, "Quote not found")
+			return
+		}
+	} else {
+		quote, err = api.newQuote(c.Request.Context(), req.FromCurrency, req.ToCurrency, req.Amount)
+	}
+	if err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Failed to get conversion rate", "wallet_id", walletUUID, "quote_id", req.QuoteId, "error", err)
+		conversionErrorResponse(c, err)
+		return
+	}
+
+	api.logger.InfoContext(c.Request.Context(), "Conversion requested", "wallet_id", walletUUID, "quote_id", quote.Id,
+		"from_currency", quote.FromCurrency, "from_amount", quote.FromAmount, "to_currency", quote.ToCurrency, "to_amount", quote.ToAmount, "rate", quote.Rate)
+
+	result, err := api.Store.Convert(c.Request.Context(), walletUUID, quote)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to convert", "wallet_id", walletUUID, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    result,
+	})
+}
+
+/*
+Расчёт котировки по текущему курсу, без сохранения
+
+Курс для клиента - средний курс источника за вычетом спреда, округлённый вниз до rates.Precision знаков;
+сумма в валюте to тоже округляется вниз, до минимальной единицы
+
+Принимает:
+
+from, to string - коды валют
+
+amount model.Money - сумма в минимальных единицах from
+
+Возвращает:
+
+quote *model.Quote - котировка без Id, ClientId и сроков
+
+error - ошибки репозитория, errRatesUnavailable, rates.ErrRateNotFound, rates.ErrAmountTooSmall или rates.ErrAmountOverflow
+*/
+func (api *WalletAPI) newQuote(ctx context.Context, from, to string, amount model.Money) (*model.Quote, error) {
+	fromCurrency, err := api.Store.Currency(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	toCurrency, err := api.Store.Currency(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.rates == nil {
+		return nil, fmt.Errorf("%w: conversion is disabled", errRatesUnavailable)
+	}
+	mid, err := api.rates.Rate(ctx, from, to)
+	if errors.Is(err, rates.ErrRateNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRatesUnavailable, err)
+	}
+
+	rate := rates.WithSpread(mid, api.spread)
+	converted, err := rates.Convert(int64(amount), fromCurrency.Scale, toCurrency.Scale, rate)
+	if err != nil {
+		return nil, fmt.Errorf("%d %s to %s at %s: %w", amount, from, to, rates.Format(rate), err)
+	}
+
+	return &model.Quote{
+		FromCurrency: from,
+		ToCurrency:   to,
+		FromAmount:   amount,
+		ToAmount:     model.Money(converted),
+		Rate:         rates.Format(rate),
+		MidRate:      rates.Format(mid),
+		Spread:       rates.Format(api.spread),
+	}, nil
+}
+
+// Ответ на ошибку расчёта курса, ошибки репозитория - как в repoErrorResponse
+func conversionErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, rates.ErrRateNotFound):
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeRateNotFound, "No exchange rate for the currency pair")
+	case errors.Is(err, rates.ErrAmountTooSmall):
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidOperation, "Amount is too small to convert")
+	case errors.Is(err, rates.ErrAmountOverflow):
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidOperation, "Converted amount is too large")
+	case errors.Is(err, errRatesUnavailable):
+		c.Header("Retry-After", "1")
+		errorResponse(c, http.StatusServiceUnavailable, model.ErrCodeRateUnavailable, "Exchange rates are unavailable, try again later")
+	default:
+		repoErrorResponse(c, err)
+	}
+}
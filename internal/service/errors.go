@@ -0,0 +1,125 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/repository"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const problemJSON = "application/problem+json"
+
+// Ответ с ошибкой в стандартном формате model.Response или model.Problem, смотря что просит клиент
+func errorResponse(c *gin.Context, status int, code, message string) {
+	writeProblem(c, newProblem(c, status, code, message))
+}
+
+// Ошибка по RFC 7807, type строится из кода ошибки: INSUFFICIENT_FUNDS -> /problems/insufficient-funds
+func newProblem(c *gin.Context, status int, code, title string) model.Problem {
+	return model.Problem{
+		Type:     "/problems/" + strings.ToLower(strings.ReplaceAll(code, "_", "-")),
+		Title:    title,
+		Status:   status,
+		Instance: c.Request.URL.Path,
+		Code:     code,
+	}
+}
+
+/*
+Отправка ошибки с учётом заголовка Accept
+
+application/problem+json отдаётся, только если клиент поставил его в Accept раньше
+application/json, иначе (и без Accept) - привычный model.Response
+*/
+func writeProblem(c *gin.Context, problem model.Problem) {
+	if c.NegotiateFormat(binding.MIMEJSON, problemJSON) == problemJSON {
+		c.Header("Content-Type", problemJSON)
+		c.JSON(problem.Status, problem)
+		return
+	}
+
+	c.JSON(problem.Status, model.Response{
+		Success: false,
+		Code:    problem.Code,
+		Error:   problem.Title,
+	})
+}
+
+/*
+Ответ на ошибку репозитория
+
+Доменные ошибки получают свой статус и код, всё остальное - 500 без подробностей,
+подробности только в логе
+*/
+func repoErrorResponse(c *gin.Context, err error) {
+	problem := repoProblem(c, err)
+	if problem.Code == model.ErrCodeTimeout {
+		// таймаут обычно из-за конкуренции за строку кошелька, запрос можно повторить
+		c.Header("Retry-After", "1")
+	}
+	writeProblem(c, problem)
+}
+
+// Статус и код ошибки репозитория, без отправки ответа
+func repoProblem(c *gin.Context, err error) model.Problem {
+	var insufficientFunds *repository.InsufficientFundsError
+
+	switch {
+	case errors.Is(err, repository.ErrWalletNotFound):
+		return newProblem(c, http.StatusNotFound, model.ErrCodeWalletNotFound, "Wallet not found")
+	case errors.As(err, &insufficientFunds):
+		problem := newProblem(c, http.StatusConflict, model.ErrCodeInsufficientFunds, "Insufficient funds")
+		problem.Detail = fmt.Sprintf("Wallet %s has %d %s, requested %d",
+			insufficientFunds.WalletId, insufficientFunds.Balance, insufficientFunds.Currency, insufficientFunds.Requested)
+		problem.CurrentBalance = &insufficientFunds.Balance
+		problem.RequestedAmount = &insufficientFunds.Requested
+		return problem
+	case errors.Is(err, repository.ErrInsufficientFunds):
+		return newProblem(c, http.StatusConflict, model.ErrCodeInsufficientFunds, "Insufficient funds")
+	case errors.Is(err, repository.ErrBalanceOverflow):
+		return newProblem(c, http.StatusConflict, model.ErrCodeBalanceOverflow, "Balance would exceed the maximum amount")
+	case errors.Is(err, repository.ErrWalletFrozen):
+		return newProblem(c, http.StatusConflict, model.ErrCodeWalletFrozen, "Wallet is frozen")
+	case errors.Is(err, repository.ErrWalletClosed):
+		return newProblem(c, http.StatusConflict, model.ErrCodeWalletClosed, "Wallet is closed")
+	case errors.Is(err, repository.ErrWalletNotEmpty):
+		return newProblem(c, http.StatusConflict, model.ErrCodeWalletNotEmpty, "Wallet balance must be zero to close it")
+	case errors.Is(err, repository.ErrInvalidTransition):
+		problem := newProblem(c, http.StatusConflict, model.ErrCodeInvalidTransition, "Invalid wallet status transition")
+		problem.Detail = err.Error()
+		return problem
+	case errors.Is(err, repository.ErrInvalidOperation):
+		return newProblem(c, http.StatusBadRequest, model.ErrCodeInvalidOperation, "Invalid operation")
+	case errors.Is(err, repository.ErrUnsupportedCurrency):
+		return newProblem(c, http.StatusBadRequest, model.ErrCodeUnsupportedCurrency, "Unsupported currency")
+	case errors.Is(err, repository.ErrHoldNotFound):
+		return newProblem(c, http.StatusNotFound, model.ErrCodeHoldNotFound, "Hold not found")
+	case errors.Is(err, repository.ErrHoldNotActive):
+		return newProblem(c, http.StatusConflict, model.ErrCodeHoldNotActive, "Hold is already captured, voided or expired")
+	case errors.Is(err, repository.ErrQuoteNotFound):
+		return newProblem(c, http.StatusNotFound, model.ErrCodeQuoteNotFound, "Quote not found")
+	case errors.Is(err, repository.ErrQuoteExpired):
+		return newProblem(c, http.StatusConflict, model.ErrCodeQuoteExpired, "Quote expired, request a new one")
+	case errors.Is(err, repository.ErrQuoteUsed):
+		return newProblem(c, http.StatusConflict, model.ErrCodeQuoteUsed, "Quote is already used")
+	case errors.Is(err, repository.ErrTransactionNotFound):
+		return newProblem(c, http.StatusNotFound, model.ErrCodeTransactionNotFound, "Transaction not found")
+	case errors.Is(err, repository.ErrAlreadyReversed):
+		return newProblem(c, http.StatusConflict, model.ErrCodeAlreadyReversed, "Transaction is already fully reversed")
+	case errors.Is(err, repository.ErrClientNotFound):
+		return newProblem(c, http.StatusNotFound, model.ErrCodeClientNotFound, "API client not found")
+	case errors.Is(err, repository.ErrIdempotencyKeyExists):
+		return newProblem(c, http.StatusUnprocessableEntity, model.ErrCodeIdempotencyKeyReused, "Idempotency key was already used")
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return newProblem(c, http.StatusBadRequest, model.ErrCodeInvalidCursor, "Invalid cursor")
+	case errors.Is(err, repository.ErrTimeout):
+		return newProblem(c, http.StatusServiceUnavailable, model.ErrCodeTimeout, "Service temporarily unavailable, try again later")
+	default:
+		return newProblem(c, http.StatusInternalServerError, model.ErrCodeInternal, "Internal Error")
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"WalletAPI/m/internal/migrate"
+	"WalletAPI/m/internal/model"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Сколько ждём все проверки готовности, пробы оркестратора обычно с таймаутом в несколько секунд
+const readinessTimeout = 2 * time.Second
+
+// Проверка одной зависимости: детали попадают в ответ /readyz, ошибка - зависимость не готова
+type ReadinessCheck func(ctx context.Context) (details map[string]any, err error)
+
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+// Добавляет проверку в /readyz, вызывается до запуска сервера
+func (api *WalletAPI) AddReadinessCheck(name string, check ReadinessCheck) {
+	api.checks = append(api.checks, namedCheck{name: name, check: check})
+}
+
+// Проверка, что БД отвечает на ping
+func PingCheck(pool *pgxpool.Pool) ReadinessCheck {
+	return func(ctx context.Context) (map[string]any, error) {
+		start := time.Now()
+		if err := pool.Ping(ctx); err != nil {
+			return nil, err
+		}
+		return map[string]any{"latencyMs": time.Since(start).Milliseconds()}, nil
+	}
+}
+
+// Проверка, что все миграции бинарника применены: иначе запросы упадут на схеме
+func MigrationsCheck(migrator *migrate.Migrator) ReadinessCheck {
+	return func(ctx context.Context) (map[string]any, error) {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]any{"pending": pending}
+		if pending > 0 {
+			return details, fmt.Errorf("%d migrations are not applied", pending)
+		}
+		return details, nil
+	}
+}
+
+// Проверка, что занятых соединений пула меньше threshold (доля от MaxConns): при полном
+// пуле новые запросы ждут соединение и упираются в таймаут
+func PoolSaturationCheck(pool *pgxpool.Pool, threshold float64) ReadinessCheck {
+	return func(ctx context.Context) (map[string]any, error) {
+		stat := pool.Stat()
+		saturation := float64(stat.AcquiredConns()) / float64(stat.MaxConns())
+		details := map[string]any{
+			"acquired":   stat.AcquiredConns(),
+			"idle":       stat.IdleConns(),
+			"max":        stat.MaxConns(),
+			"saturation": saturation,
+		}
+		if saturation >= threshold {
+			return details, fmt.Errorf("pool saturation %.2f is above threshold %.2f", saturation, threshold)
+		}
+		return details, nil
+	}
+}
+
+// Liveness: процесс жив и обрабатывает запросы, зависимости не проверяются,
+// чтобы недоступная БД не приводила к перезапуску контейнера
+func (api *WalletAPI) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, model.Response{Success: true, Data: model.HealthReport{Status: "ok"}})
+}
+
+// Readiness: инстанс готов принимать трафик - не останавливается и все зависимости в порядке.
+// 503 с результатом каждой проверки, если хотя бы одна не прошла
+func (api *WalletAPI) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	report := model.HealthReport{Status: "ok", Checks: make(map[string]model.HealthCheck, len(api.checks)+1)}
+
+	if !api.Ready() {
+		report.Status = "fail"
+		report.Checks["shutdown"] = model.HealthCheck{Status: "fail", Error: "instance is shutting down"}
+	}
+
+	for _, named := range api.checks {
+		details, err := named.check(ctx)
+		result := model.HealthCheck{Status: "ok", Details: details}
+		if err != nil {
+			api.logger.ErrorContext(ctx, "Readiness check failed", "check", named.name, "error", err)
+			result.Status = "fail"
+			result.Error = err.Error()
+			report.Status = "fail"
+		}
+		report.Checks[named.name] = result
+	}
+
+	if report.Status != "ok" {
+		c.JSON(http.StatusServiceUnavailable, model.Response{
+			Success: false,
+			Code:    model.ErrCodeNotReady,
+			Error:   "Service is not ready",
+			Data:    report,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{Success: true, Data: report})
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateHold godoc
+// @Summary Place a hold on wallet funds
+// @Description Reserves amount in a currency on a wallet: available balance decreases, total balance does not.
+// @Description The hold expires automatically if it is not captured or voided in time.
+// @Tags Holds
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param request body model.CreateHold true "Create hold request"
+// @Success 201 {object} model.Response{data=model.Hold} "Hold created successfully"
+// @Failure 400 {object} model.Response "Invalid request body or unsupported currency"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 409 {object} model.Response "Insufficient available funds, wallet frozen or closed"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID}/holds [post]
+func (api *WalletAPI) CreateHold(c *gin.Context) {
+	walletUUID := c.Param("WALLET_UUID")
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet UUID", "wallet_id", walletUUID)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidWalletId, "Invalid wallet UUID")
+		return
+	}
+
+	if !api.checkWallets(c, walletUUID) {
+		return
+	}
+
+	var req model.CreateHold
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid hold request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	hold, err := api.Store.CreateHold(c.Request.Context(), walletUUID, req.Currency, req.Amount)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to create hold", "wallet_id", walletUUID, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Success: true,
+		Data:    hold,
+	})
+}
+
+// CaptureHold godoc
+// @Summary Capture a hold
+// @Description Withdraws the held amount (or a part of it) from the wallet. The rest of a partially captured hold is released.
+// @Tags Holds
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param HOLD_ID path string true "Hold ID"
+// @Param request body model.CaptureHold false "Capture request, without amount the whole hold is captured"
+// @Success 200 {object} model.Response{data=model.CaptureResult} "Hold captured successfully"
+// @Failure 400 {object} model.Response "Invalid request body or amount exceeds hold"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet or hold not found"
+// @Failure 409 {object} model.Response "Hold is already captured, voided or expired, wallet frozen or closed"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID}/holds/{HOLD_ID}/capture [post]
+func (api *WalletAPI) CaptureHold(c *gin.Context) {
+	walletUUID, holdId, ok := api.holdParams(c)
+	if !ok {
+		return
+	}
+
+	// тело необязательное: пустое тело - подтверждение всей суммы
+	var req model.CaptureHold
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		api.logger.WarnContext(c.Request.Context(), "Invalid capture request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	result, err := api.Store.CaptureHold(c.Request.Context(), walletUUID, holdId, req.Amount)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to capture hold", "hold_id", holdId, "wallet_id", walletUUID, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// VoidHold godoc
+// @Summary Void a hold
+// @Description Releases the held amount back to the available balance
+// @Tags Holds
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param HOLD_ID path string true "Hold ID"
+// @Success 200 {object} model.Response{data=model.Hold} "Hold voided successfully"
+// @Failure 400 {object} model.Response "Invalid wallet UUID or hold ID"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet or hold not found"
+// @Failure 409 {object} model.Response "Hold is already captured, voided or expired, or wallet closed"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID}/holds/{HOLD_ID}/void [post]
+func (api *WalletAPI) VoidHold(c *gin.Context) {
+	walletUUID, holdId, ok := api.holdParams(c)
+	if !ok {
+		return
+	}
+
+	hold, err := api.Store.VoidHold(c.Request.Context(), walletUUID, holdId)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to void hold", "hold_id", holdId, "wallet_id", walletUUID, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    hold,
+	})
+}
+
+// UUID кошелька и id холда из url и проверка владельца кошелька, при ошибке ответ уже отправлен
+func (api *WalletAPI) holdParams(c *gin.Context) (string, string, bool) {
+	walletUUID, holdId := c.Param("WALLET_UUID"), c.Param("HOLD_ID")
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet UUID", "wallet_id", walletUUID)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidWalletId, "Invalid wallet UUID")
+		return "", "", false
+	}
+	if _, err := uuid.Parse(holdId); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid hold ID", "hold_id", holdId)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid hold ID")
+		return "", "", false
+	}
+	if !api.checkWallets(c, walletUUID) {
+		return "", "", false
+	}
+
+	return walletUUID, holdId, true
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// Ключ идемпотентности из заголовка или из тела запроса, nil если клиент его не передал
+func idempotencyFor(c *gin.Context, req model.UpdateBalance) (*model.Idempotency, error) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		key = req.IdempotencyKey
+	}
+	return idempotencyWithKey(clientOf(c).Id, key, req)
+}
+
+// Ключ идемпотентности клиента с хешем тела запроса, nil если ключ пустой
+func idempotencyWithKey(clientId, key string, req model.UpdateBalance) (*model.Idempotency, error) {
+	if key == "" {
+		return nil, nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("key is longer than %d characters", maxIdempotencyKeyLength)
+	}
+
+	// сам ключ в хеш не входит, чтобы тело с ключом и без него считались одним запросом
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s|%s|%s|%d", req.WalletId, req.OperationType, req.Currency, req.Amount))
+	return &model.Idempotency{
+		ClientId:    clientId,
+		Key:         key,
+		RequestHash: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+/*
+Ответ на повтор запроса с уже использованным ключом
+
+Возвращает true, если ответ уже отправлен: исходный результат операции,
+либо 422 если ключ использован с другим телом запроса
+*/
+func (api *WalletAPI) replayIdempotent(c *gin.Context, idempotency *model.Idempotency) bool {
+	requestHash, transaction, err := api.Store.IdempotentTransaction(c.Request.Context(), idempotency.ClientId, idempotency.Key)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to check idempotency key", "idempotency_key", idempotency.Key, "error", err)
+		repoErrorResponse(c, err)
+		return true
+	}
+	if transaction == nil {
+		return false
+	}
+
+	if requestHash != idempotency.RequestHash {
+		api.logger.WarnContext(c.Request.Context(), "Idempotency key reused with a different request", "idempotency_key", idempotency.Key)
+		errorResponse(c, http.StatusUnprocessableEntity, model.ErrCodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request")
+		return true
+	}
+
+	api.logger.InfoContext(c.Request.Context(), "Replaying transaction", "transaction_id", transaction.Id, "idempotency_key", idempotency.Key)
+	c.Header(idempotencyReplayedHeader, "true")
+	c.JSON(http.StatusOK, updateBalanceResponse(transaction))
+	return true
+}
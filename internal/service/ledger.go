@@ -0,0 +1,40 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLedger godoc
+// @Summary Reconcile the double-entry journal
+// @Description Returns balances of system accounts and the total of all wallets per currency. In every currency the sum of all accounts is always 0 and every wallet balance must match the sum of its postings.
+// @Description Requires the admin API key.
+// @Tags Ledger
+// @Produce json,application/problem+json
+// @Security AdminKeyAuth
+// @Success 200 {object} model.Response{data=model.LedgerSummary} "Ledger summary retrieved successfully"
+// @Failure 401 {object} model.Response "Invalid admin API key"
+// @Failure 403 {object} model.Response "Admin API is disabled"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /ledger [get]
+func (api *WalletAPI) GetLedger(c *gin.Context) {
+	summary, err := api.Store.LedgerSummary(c.Request.Context())
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to get ledger summary", "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	if !summary.Balanced {
+		api.logger.ErrorContext(c.Request.Context(), "Ledger is not balanced", "currencies", summary.Currencies, "mismatched_wallets", summary.MismatchedWallets)
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    summary,
+	})
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"WalletAPI/m/internal/logging"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Id запроса от вызывающего принимаем, только если он не сломает логи
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,128}$`)
+
+// Middleware: id запроса из X-Request-ID вызывающего или новый. Возвращается в X-Request-ID
+// ответа и попадает во все строки лога service и repository, пишущих с context запроса
+func requestIDMiddleware(c *gin.Context) {
+	requestID := c.GetHeader(requestIDHeader)
+	if !validRequestID.MatchString(requestID) {
+		requestID = uuid.New().String()
+	}
+
+	c.Header(requestIDHeader, requestID)
+	c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+	c.Next()
+}
+
+// Middleware: строка лога на каждый запрос вместо логгера gin
+func (api *WalletAPI) accessLog(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	status := c.Writer.Status()
+	level := slog.LevelInfo
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case route == "/healthz" || route == "/readyz" || route == "/metrics":
+		// пробы и scrape идут каждые несколько секунд
+		level = slog.LevelDebug
+	}
+
+	api.logger.Log(c.Request.Context(), level, "Request handled",
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"route", route,
+		"status", status,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"client_ip", c.ClientIP(),
+	)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"WalletAPI/m/internal/metrics"
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/repository"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware: количество и длительность запросов по шаблону маршрута и статусу
+func metricsMiddleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	// шаблон, а не путь, иначе каждый UUID кошелька станет отдельной серией
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	status := strconv.Itoa(c.Writer.Status())
+
+	metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+}
+
+// Учёт выполненной DEPOSIT/WITHDRAW по исходу, повторы по ключу идемпотентности не учитываются
+func observeBalanceOperation(operationType, currency string, amount model.Money, err error) {
+	outcome := metrics.OutcomeSuccess
+	switch {
+	case err == nil:
+		metrics.AmountMoved.WithLabelValues(operationType, currency).Add(float64(amount))
+	case errors.Is(err, repository.ErrInsufficientFunds):
+		outcome = metrics.OutcomeInsufficientFunds
+	case errors.Is(err, repository.ErrWalletNotFound):
+		outcome = metrics.OutcomeNotFound
+	default:
+		outcome = metrics.OutcomeError
+	}
+	metrics.BalanceOperations.WithLabelValues(operationType, outcome).Inc()
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"WalletAPI/m/internal/metrics"
+	"WalletAPI/m/internal/model"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware: лимит запросов клиента, ставится после authenticate
+func (api *WalletAPI) limitClient(c *gin.Context) {
+	client := clientOf(c)
+	if ok, retryAfter := api.clientLimit.Allow(client.Id); !ok {
+		api.logger.WarnContext(c.Request.Context(), "Client rate limit exceeded", "client_id", client.Id, "retry_after", retryAfter)
+		api.rateLimited(c, "client", retryAfter, "Client rate limit exceeded")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+/*
+Лимит запросов к кошелькам, каждый кошелёк запроса тратит один токен своей корзины
+
+Возвращает true, если все кошельки укладываются в лимит, иначе ответ 429 уже отправлен
+*/
+func (api *WalletAPI) limitWallets(c *gin.Context, walletUUIDs ...string) bool {
+	for _, walletUUID := range walletUUIDs {
+		if ok, retryAfter := api.walletLimit.Allow(strings.ToLower(walletUUID)); !ok {
+			api.logger.WarnContext(c.Request.Context(), "Wallet rate limit exceeded", "wallet_id", walletUUID, "retry_after", retryAfter)
+			api.rateLimited(c, "wallet", retryAfter, fmt.Sprintf("Wallet %s rate limit exceeded", walletUUID))
+			return false
+		}
+	}
+	return true
+}
+
+// Ответ 429 с Retry-After в целых секундах, не меньше 1
+func (api *WalletAPI) rateLimited(c *gin.Context, limit string, retryAfter time.Duration, detail string) {
+	metrics.RateLimited.WithLabelValues(limit).Inc()
+
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	problem := newProblem(c, http.StatusTooManyRequests, model.ErrCodeRateLimited, "Too many requests")
+	problem.Detail = detail
+	writeProblem(c, problem)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ReverseTransaction godoc
+// @Summary Reverse a transaction
+// @Description Creates a compensating ledger entry for a DEPOSIT, WITHDRAW or transfer, fully or partially.
+// @Description A transfer is reversed by the id of either of its parts. The total reversed amount never exceeds the original amount.
+// @Description All wallets of the transaction, for a transfer both of them, must belong to the calling client.
+// @Tags Transactions
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param TRANSACTION_ID path string true "Transaction ID"
+// @Param request body model.ReverseTransaction false "Reversal request, without amount the whole remaining amount is reversed"
+// @Success 200 {object} model.Response{data=model.ReversalResult} "Transaction reversed successfully"
+// @Failure 400 {object} model.Response "Invalid request, transaction type cannot be reversed or amount exceeds remaining"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "A wallet of the transaction belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Transaction not found"
+// @Failure 409 {object} model.Response "Transaction is already fully reversed, insufficient funds, balance overflow, wallet frozen or closed"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /transactions/{TRANSACTION_ID}/reverse [post]
+func (api *WalletAPI) ReverseTransaction(c *gin.Context) {
+	transactionId := c.Param("TRANSACTION_ID")
+	if _, err := uuid.Parse(transactionId); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid transaction ID", "transaction_id", transactionId)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid transaction ID")
+		return
+	}
+
+	// тело необязательное: пустое тело - сторно всего остатка
+	var req model.ReverseTransaction
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		api.logger.WarnContext(c.Request.Context(), "Invalid reversal request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	// сторно перевода меняет оба кошелька, поэтому оба должны быть своими
+	walletUUIDs, err := api.Store.TransactionWallets(c.Request.Context(), transactionId)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to get wallets of transaction", "transaction_id", transactionId, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+	if !api.checkWallets(c, walletUUIDs...) {
+		return
+	}
+
+	result, err := api.Store.Reverse(c.Request.Context(), transactionId, req.Amount)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to reverse transaction", "transaction_id", transactionId, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    result,
+	})
+}
@@ -1,132 +1,374 @@
 package service
 
 import (
+	"WalletAPI/m/internal/auth"
 	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/ratelimit"
 	"WalletAPI/m/internal/repository"
-	"log"
+	"WalletAPI/m/internal/tracing"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 // Структура для API
 type WalletAPI struct {
-	WalletRepo *repository.WalletRepo
-	logger     *log.Logger
+	Store  WalletStore
+	logger *slog.Logger
+
+	adminKeyHash string             // SHA-256 ключа администратора, пустой - админские ручки выключены
+	jwt          *auth.Verifier     // проверка Bearer токенов, nil - аутентификация только по ключам
+	clientLimit  *ratelimit.Limiter // лимит запросов по клиенту, nil - без ограничения
+	walletLimit  *ratelimit.Limiter // лимит запросов по кошельку, nil - без ограничения
+	rates        RateProvider       // курсы для обмена валют, nil - обмен выключен
+	spread       *big.Rat           // доля, на которую курс для клиента хуже среднего
+
+	draining atomic.Bool  // инстанс останавливается и не должен получать новые запросы
+	checks   []namedCheck // проверки зависимостей для /readyz
+}
+
+// Настройки API, заполняются из конфига
+type Options struct {
+	AdminApiKey string         // ключ для /v1/admin и /v1/ledger, пустой - эти ручки выключены
+	JWT         *auth.Verifier // проверка JWT, nil - Bearer токены не принимаются
+
+	ClientRateLimit ratelimit.Options // лимит запросов одного клиента ко всем ручкам /v1
+	WalletRateLimit ratelimit.Options // лимит запросов к одному кошельку от его владельца
+
+	Rates  RateProvider // источник курсов для котировок и обмена, nil - обмен выключен
+	Spread *big.Rat     // спред обмена, доля от 0 до 1, nil - без спреда
 }
 
 // Конструктор WalletAPI
-func NewWalletAPI(walletRepo *repository.WalletRepo, logger *log.Logger) *WalletAPI {
-	return &WalletAPI{
-		WalletRepo: walletRepo,
-		logger:     logger,
+func NewWalletAPI(store WalletStore, logger *slog.Logger, options Options) *WalletAPI {
+	api := &WalletAPI{
+		Store:  store,
+		logger: logger,
+		jwt:    options.JWT,
+
+		clientLimit: ratelimit.New(options.ClientRateLimit),
+		walletLimit: ratelimit.New(options.WalletRateLimit),
+
+		rates:  options.Rates,
+		spread: new(big.Rat),
+	}
+	if options.Spread != nil {
+		api.spread.Set(options.Spread)
+	}
+	if options.AdminApiKey != "" {
+		api.adminKeyHash = hashAPIKey(options.AdminApiKey)
+	}
+	return api
+}
+
+// Переводит инстанс в остановку: readiness начинает отвечать ошибкой, keep-alive соединения закрываются
+func (api *WalletAPI) StartDraining() {
+	if !api.draining.Swap(true) {
+		api.logger.Info("Draining, instance is no longer ready")
+	}
+}
+
+// Готов ли инстанс принимать новые запросы
+func (api *WalletAPI) Ready() bool {
+	return !api.draining.Load()
+}
+
+/*
+Плавная остановка сервера
+
+Сначала readiness, чтобы балансировщик перестал слать запросы, пока сервер ещё их принимает.
+Через delay server.Shutdown закрывает listener и ждёт завершения запросов, которые уже выполняются
+
+Принимает:
+
+server *http.Server - сервер с обработчиками этого API
+
+delay time.Duration - сколько принимать запросы после того, как readiness начала отвечать ошибкой
+
+timeout time.Duration - сколько ждать запросы, которые уже выполняются
+
+Возвращает:
+
+error - error, context.DeadlineExceeded если запросы не завершились за timeout
+*/
+func (api *WalletAPI) Shutdown(ctx context.Context, server *http.Server, delay, timeout time.Duration) error {
+	api.StartDraining()
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
 	}
+
+	drainCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return server.Shutdown(drainCtx)
 }
 
 // CreateWallet godoc
 // @Summary Create a new wallet
-// @Description Creates a new wallet with zero balance and returns its UUID
+// @Description Creates a new wallet with zero balance owned by the calling API client and returns it.
+// @Description The body is optional: external owner id, display name, metadata (a JSON object) and labels are stored with the wallet.
 // @Tags Wallets
 // @Accept json
-// @Produce json
-// @Success 200 {object} model.Response{data=map[string]string} "Wallet created successfully"
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param request body model.CreateWallet false "Wallet details"
+// @Success 200 {object} model.Response{data=model.Wallet} "Wallet created successfully"
+// @Failure 400 {object} model.Response "Invalid request body"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Token lacks scope"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
 // @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
 // @Router /create [post]
 func (api *WalletAPI) CreateWallet(c *gin.Context) {
-	walletUUID, err := api.WalletRepo.CreateWallet(c.Request.Context())
+	// тело необязательное: без него создаётся кошелёк без данных клиента
+	var req model.CreateWallet
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		api.logger.WarnContext(c.Request.Context(), "Invalid create wallet request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if !api.checkMetadata(c, req.Metadata) {
+		return
+	}
+	req.Labels = normalizeLabels(req.Labels)
+
+	client := clientOf(c)
+	wallet, err := api.Store.CreateWallet(c.Request.Context(), client.Id, req)
 	if err != nil {
-		api.logger.Printf("ERROR: Failed to create wallet: %v", err)
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Success: false,
-			Error:   "Internal Error",
-		})
+		api.logger.ErrorContext(c.Request.Context(), "Failed to create wallet", "error", err)
+		repoErrorResponse(c, err)
 		return
 	}
 
-	api.logger.Printf("INFO: Created wallet %s", walletUUID)
+	api.logger.InfoContext(c.Request.Context(), "Created wallet", "wallet_id", wallet.WalletId, "client_id", client.Id)
 	c.JSON(http.StatusOK, model.Response{
 		Success: true,
-		Data:    map[string]string{"walletId": walletUUID}, // возвращаем UUID кошелька
+		Data:    wallet, // UUID кошелька и его данные
 	})
 }
 
 // UpdateBalance godoc
 // @Summary Update wallet balance
-// @Description Deposits or withdraws funds from a wallet
+// @Description Deposits or withdraws funds from a wallet balance in the given currency. The amount is in minor units of the currency.
+// @Description With Idempotency-Key a retry of an already applied request returns the original result instead of applying it again.
 // @Tags Wallets
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
+// @Param Idempotency-Key header string false "Idempotency key, max 255 characters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Param request body model.UpdateBalance true "Update balance request"
 // @Success 200 {object} model.Response{data=map[string]string} "Balance updated successfully"
-// @Failure 400 {object} model.Response "Invalid request body"
+// @Failure 400 {object} model.Response "Invalid request body, operation type or unsupported currency"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 409 {object} model.Response "Insufficient funds, the deposit would overflow the balance, or the wallet is frozen or closed"
+// @Failure 422 {object} model.Response "Idempotency key reused with a different request"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
 // @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
 // @Router /wallet [post]
 func (api *WalletAPI) UpdateBalance(c *gin.Context) {
 	var req model.UpdateBalance
 	if err := c.ShouldBindJSON(&req); err != nil {
-		api.logger.Printf("ERROR: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, model.Response{
-			Success: false,
-			Error:   "Invalid request body",
-		})
+		api.logger.WarnContext(c.Request.Context(), "Invalid request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
 		return
 	}
 
-	api.logger.Printf("INFO: Wallet %s requested %s , amount %d", req.WalletId, req.OperationType, req.Amount)
+	idempotency, err := idempotencyFor(c, req)
+	if err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid idempotency key", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid Idempotency-Key")
+		return
+	}
 
-	err := api.WalletRepo.Update(c.Request.Context(), req.WalletId, req.OperationType, req.Amount)
+	// право зависит от операции, поэтому проверяется после разбора тела, а не на маршруте
+	if scope := operationScope(req.OperationType); scope != "" && !api.authorizeScopes(c, scope) {
+		return
+	}
+
+	// владельца проверяем до повтора, иначе по чужому ключу идемпотентности можно прочитать чужой результат
+	if !api.checkWallets(c, req.WalletId) {
+		return
+	}
+
+	// повтор уже выполненного запроса - отдаём исходный результат, ничего не выполняя
+	if idempotency != nil && api.replayIdempotent(c, idempotency) {
+		return
+	}
+
+	api.logger.InfoContext(c.Request.Context(), "Balance update requested", "wallet_id", req.WalletId, "operation", req.OperationType,
+		"currency", req.Currency, "amount", req.Amount)
+
+	transaction, err := api.Store.Update(c.Request.Context(), req.WalletId, req.OperationType, req.Currency, req.Amount, idempotency)
+	if errors.Is(err, repository.ErrIdempotencyKeyExists) && api.replayIdempotent(c, idempotency) {
+		// параллельный запрос с тем же ключом успел закоммитить раньше
+		return
+	}
+	observeBalanceOperation(req.OperationType, req.Currency, req.Amount, err)
 	if err != nil {
-		api.logger.Printf("ERROR: Failed to update wallet %s: %v", req.WalletId, err)
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Success: false,
-			Error:   "Internal Error",
-		})
+		api.logger.ErrorContext(c.Request.Context(), "Failed to update wallet", "wallet_id", req.WalletId, "error", err)
+		repoErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, model.Response{
+	c.JSON(http.StatusOK, updateBalanceResponse(transaction))
+}
+
+// Ответ на успешное обновление баланса, одинаковый для первого запроса и его повторов
+func updateBalanceResponse(transaction *model.Transaction) model.Response {
+	return model.Response{
 		Success: true,
-		Data:    map[string]string{"message": "Wallet updated successfully!"},
-	})
+		Data: map[string]string{
+			"message":       "Wallet updated successfully!",
+			"transactionId": transaction.Id, // id записи в журнале операций
+		},
+	}
 }
 
 // GetBalance godoc
-// @Summary Get wallet balance
-// @Description Returns the current balance of a wallet by its UUID
+// @Summary Get wallet details and balances
+// @Description Returns the wallet by its UUID: status, external owner id, display name, metadata and labels,
+// @Description and its balances in every currency it has operations in: total, available and held by active holds.
+// @Description With currency only the balance in that currency is returned, zero if there were no operations in it.
 // @Tags Wallets
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Param WALLET_UUID path string true "Wallet UUID"
-// @Success 200 {object} model.Response{data=map[string]interface{}} "Balance retrieved successfully"
-// @Failure 400 {object} model.Response "Wallet UUID not provided"
+// @Param currency query string false "Currency code, e.g. USD or BTC"
+// @Success 200 {object} model.Response{data=model.WalletBalances} "Wallet retrieved successfully"
+// @Failure 400 {object} model.Response "Invalid wallet UUID or unsupported currency"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
 // @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
 // @Router /wallets/{WALLET_UUID} [get]
 func (api *WalletAPI) GetBalance(c *gin.Context) {
 	walletUUID := c.Param("WALLET_UUID") // param берёт значение WALLET_UUID из url запроса
-	if walletUUID == "" {
-		api.logger.Printf("ERROR: Wallet UUID not provided")
-		c.JSON(http.StatusBadRequest, model.Response{
-			Success: false,
-			Error:   "Wallet UUID is required",
-		})
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet UUID", "wallet_id", walletUUID)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidWalletId, "Invalid wallet UUID")
 		return
 	}
 
-	balance, err := api.WalletRepo.Balance(c.Request.Context(), walletUUID)
+	var query model.BalanceQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid balance query", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid query parameters")
+		return
+	}
+
+	if !api.checkWallets(c, walletUUID) {
+		return
+	}
+
+	wallet, err := api.Store.Wallet(c.Request.Context(), walletUUID)
 	if err != nil {
-		api.logger.Printf("ERROR: Failed to get balance for wallet %s: %v", walletUUID, err)
-		c.JSON(http.StatusNotFound, model.Response{
-			Success: false,
-			Error:   "Wallet not found",
-		})
+		api.logger.ErrorContext(c.Request.Context(), "Failed to get wallet", "wallet_id", walletUUID, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	balances, err := api.Store.Balance(c.Request.Context(), walletUUID, query.Currency)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to get balance", "wallet_id", walletUUID, "currency", query.Currency, "error", err)
+		repoErrorResponse(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, model.Response{
 		Success: true,
-		Data:    map[string]interface{}{"balance": balance}, // возврашаемый баланс, собственно
+		// данные кошелька и балансы, собственно: по каждой валюте весь, доступный и зарезервированный холдами
+		Data: model.WalletBalances{Wallet: *wallet, Balances: balances},
+	})
+}
+
+// GetTransactions godoc
+// @Summary Get wallet transactions history
+// @Description Returns wallet operations newest-first with cursor-based pagination and filters
+// @Tags Transactions
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param limit query int false "Page size (1-500, default 50)"
+// @Param cursor query string false "Cursor from nextCursor of the previous page"
+// @Param operationType query string false "Operation type filter" Enums(DEPOSIT, WITHDRAW, TRANSFER_OUT, TRANSFER_IN, CAPTURE, REVERSAL, CONVERT_OUT, CONVERT_IN)
+// @Param currency query string false "Currency filter, e.g. USD"
+// @Param minAmount query int false "Minimum amount, inclusive"
+// @Param maxAmount query int false "Maximum amount, inclusive"
+// @Param from query string false "Start of time window, inclusive (RFC 3339)"
+// @Param to query string false "End of time window, exclusive (RFC 3339)"
+// @Success 200 {object} model.Response{data=model.TransactionsPage} "Transactions retrieved successfully"
+// @Failure 400 {object} model.Response "Invalid query parameters"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID}/transactions [get]
+func (api *WalletAPI) GetTransactions(c *gin.Context) {
+	walletUUID := c.Param("WALLET_UUID")
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet UUID", "wallet_id", walletUUID)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidWalletId, "Invalid wallet UUID")
+		return
+	}
+
+	var query model.TransactionsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid transactions query", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid query parameters")
+		return
+	}
+
+	// диапазоны проверяем здесь, в binding сравнение двух полей неудобно
+	if query.MinAmount != nil && query.MaxAmount != nil && *query.MinAmount > *query.MaxAmount ||
+		query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid query parameters")
+		return
+	}
+
+	if !api.checkWallets(c, walletUUID) {
+		return
+	}
+
+	page, err := api.Store.Transactions(c.Request.Context(), walletUUID, query)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to get transactions", "wallet_id", walletUUID, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    page,
 	})
 }
 
@@ -134,6 +376,13 @@ func (api *WalletAPI) GetBalance(c *gin.Context) {
 func SetupRoutes(router *gin.Engine, api *WalletAPI) {
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler)) // для swagger документации, в логах есть ссылка на неё
 
+	router.Use(tracing.Middleware, requestIDMiddleware, api.accessLog, metricsMiddleware)
+
+	// пробы оркестратора и метрики, вне /v1, так как не часть API
+	router.GET("/healthz", api.Healthz)
+	router.GET("/readyz", api.Readyz)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// базовая безопасность
 	router.Use(func(c *gin.Context) {
 		c.Header("X-Content-Type-Options", "nosniff")
@@ -143,7 +392,46 @@ func SetupRoutes(router *gin.Engine, api *WalletAPI) {
 		c.Next()
 	})
 
-	router.POST("/v1/create", api.CreateWallet)
-	router.POST("/v1/wallet", api.UpdateBalance)
-	router.GET("/v1/wallets/:WALLET_UUID", api.GetBalance)
+	// при остановке клиенты с keep-alive переподключаются, уже к другому инстансу
+	router.Use(func(c *gin.Context) {
+		if !api.Ready() {
+			c.Header("Connection", "close")
+		}
+		c.Next()
+	})
+
+	// управление клиентами API и сверка журнала - только по ключу администратора
+	admin := router.Group("/v1", api.requireAdmin)
+	admin.POST("/admin/clients", api.CreateClient)
+	admin.GET("/admin/clients", api.ListClients)
+	admin.DELETE("/admin/clients/:CLIENT_ID", api.RevokeClient)
+	admin.PUT("/admin/wallets/:WALLET_UUID/owner", api.AssignWalletOwner)
+	admin.GET("/ledger", api.GetLedger)
+
+	// операции с кошельками - по ключу клиента или JWT, только со своими кошельками и в пределах прав токена
+	read := api.requireScopes(auth.ScopeRead)
+	withdraw := api.requireScopes(auth.ScopeWithdraw)
+	manage := api.requireScopes(auth.ScopeManage)
+	// DEPOSIT или WITHDRAW - из тела запроса, точная проверка в обработчике
+	balance := api.requireAnyScope(auth.ScopeDeposit, auth.ScopeWithdraw)
+
+	v1 := router.Group("/v1", api.authenticate, api.limitClient)
+	v1.POST("/create", api.requireScopes(auth.ScopeCreate), api.CreateWallet)
+	v1.POST("/wallet", balance, api.UpdateBalance)
+	v1.POST("/wallet/batch", balance, api.UpdateBalanceBatch)
+	v1.POST("/transfer", withdraw, api.Transfer)
+	v1.GET("/wallets/:WALLET_UUID", read, api.GetBalance)
+	v1.PATCH("/wallets/:WALLET_UUID", manage, api.UpdateWallet)
+	v1.GET("/wallets/:WALLET_UUID/transactions", read, api.GetTransactions)
+	v1.GET("/wallets/:WALLET_UUID/status-history", read, api.GetWalletStatusHistory)
+	v1.POST("/wallets/:WALLET_UUID/freeze", manage, api.FreezeWallet)
+	v1.POST("/wallets/:WALLET_UUID/unfreeze", manage, api.UnfreezeWallet)
+	v1.POST("/wallets/:WALLET_UUID/close", manage, api.CloseWallet)
+	v1.POST("/wallets/:WALLET_UUID/holds", withdraw, api.CreateHold)
+	v1.POST("/wallets/:WALLET_UUID/holds/:HOLD_ID/capture", withdraw, api.CaptureHold)
+	v1.POST("/wallets/:WALLET_UUID/holds/:HOLD_ID/void", withdraw, api.VoidHold)
+	v1.GET("/quotes", read, api.GetQuote)
+	v1.POST("/wallets/:WALLET_UUID/convert", withdraw, api.Convert)
+	// сторно может и вернуть деньги на кошелёк, и списать их
+	v1.POST("/transactions/:TRANSACTION_ID/reverse", api.requireScopes(auth.ScopeDeposit, auth.ScopeWithdraw), api.ReverseTransaction)
 }
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/repository"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// FreezeWallet godoc
+// @Summary Freeze a wallet
+// @Description Rejects withdrawals, transfers from the wallet, holds and conversions until the wallet is unfrozen.
+// @Description Deposits and incoming transfers are still accepted unless blockDeposits is set. The change is recorded with actor and reason.
+// @Tags Wallet status
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param request body model.FreezeWallet true "Freeze request"
+// @Success 200 {object} model.Response{data=model.WalletStatusChange} "Wallet frozen"
+// @Failure 400 {object} model.Response "Invalid wallet UUID or request body"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 409 {object} model.Response "Wallet is not active"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID}/freeze [post]
+func (api *WalletAPI) FreezeWallet(c *gin.Context) {
+	var req model.FreezeWallet
+	walletUUID, ok := api.statusRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	api.changeStatus(c, &model.WalletStatusChange{
+		WalletId:        walletUUID,
+		Status:          repository.WalletFrozen,
+		DepositsBlocked: req.BlockDeposits,
+		Reason:          req.Reason,
+	})
+}
+
+// UnfreezeWallet godoc
+// @Summary Unfreeze a wallet
+// @Description Returns a frozen wallet to ACTIVE. The change is recorded with actor and reason.
+// @Tags Wallet status
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param request body model.WalletStatusReason true "Unfreeze request"
+// @Success 200 {object} model.Response{data=model.WalletStatusChange} "Wallet unfrozen"
+// @Failure 400 {object} model.Response "Invalid wallet UUID or request body"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 409 {object} model.Response "Wallet is not frozen"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID}/unfreeze [post]
+func (api *WalletAPI) UnfreezeWallet(c *gin.Context) {
+	var req model.WalletStatusReason
+	walletUUID, ok := api.statusRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	api.changeStatus(c, &model.WalletStatusChange{
+		WalletId: walletUUID,
+		Status:   repository.WalletActive,
+		Reason:   req.Reason,
+	})
+}
+
+// CloseWallet godoc
+// @Summary Close a wallet
+// @Description Closes an active wallet for good: every operation on it is rejected afterwards.
+// @Description Balances in all currencies must be zero. A frozen wallet has to be unfrozen first.
+// @Tags Wallet status
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param request body model.WalletStatusReason true "Close request"
+// @Success 200 {object} model.Response{data=model.WalletStatusChange} "Wallet closed"
+// @Failure 400 {object} model.Response "Invalid wallet UUID or request body"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 409 {object} model.Response "Wallet is not active or its balance is not zero"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID}/close [post]
+func (api *WalletAPI) CloseWallet(c *gin.Context) {
+	var req model.WalletStatusReason
+	walletUUID, ok := api.statusRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	api.changeStatus(c, &model.WalletStatusChange{
+		WalletId: walletUUID,
+		Status:   repository.WalletClosed,
+		Reason:   req.Reason,
+	})
+}
+
+// GetWalletStatusHistory godoc
+// @Summary Get wallet status history
+// @Description Returns every status change of the wallet, oldest first, with actor and reason.
+// @Tags Wallet status
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Success 200 {object} model.Response{data=[]model.WalletStatusChange} "Status history"
+// @Failure 400 {object} model.Response "Invalid wallet UUID"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID}/status-history [get]
+func (api *WalletAPI) GetWalletStatusHistory(c *gin.Context) {
+	walletUUID := c.Param("WALLET_UUID")
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet UUID", "wallet_id", walletUUID)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidWalletId, "Invalid wallet UUID")
+		return
+	}
+
+	if !api.checkWallets(c, walletUUID) {
+		return
+	}
+
+	changes, err := api.Store.WalletStatusHistory(c.Request.Context(), walletUUID)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to get wallet status history", "wallet_id", walletUUID, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    changes,
+	})
+}
+
+// UUID кошелька из пути и тело запроса смены статуса, false если ответ с ошибкой уже отправлен
+func (api *WalletAPI) statusRequest(c *gin.Context, req any) (string, bool) {
+	walletUUID := c.Param("WALLET_UUID")
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet UUID", "wallet_id", walletUUID)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidWalletId, "Invalid wallet UUID")
+		return "", false
+	}
+
+	if !api.checkWallets(c, walletUUID) {
+		return "", false
+	}
+
+	if err := c.ShouldBindJSON(req); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet status request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return "", false
+	}
+
+	return walletUUID, true
+}
+
+// Смена статуса от имени клиента запроса: он и записывается в историю как actor
+func (api *WalletAPI) changeStatus(c *gin.Context, change *model.WalletStatusChange) {
+	change.Actor = clientOf(c).Id
+
+	saved, err := api.Store.ChangeWalletStatus(c.Request.Context(), change)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to change wallet status", "wallet_id", change.WalletId,
+			"status", change.Status, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    saved,
+	})
+}
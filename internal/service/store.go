@@ -0,0 +1,50 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/repository"
+	"context"
+)
+
+// Хранилище кошельков, которым пользуется WalletAPI. В проде это repository.WalletRepo поверх
+// Postgres, в тестах - repository.MemoryStore с той же семантикой
+type WalletStore interface {
+	CreateWallet(ctx context.Context, ownerId string, params model.CreateWallet) (*model.Wallet, error)
+	Wallet(ctx context.Context, walletUUID string) (*model.Wallet, error)
+	UpdateWallet(ctx context.Context, walletUUID string, patch model.UpdateWallet) (*model.Wallet, error)
+	Update(ctx context.Context, walletUUID, operationType, currency string, amount model.Money, idempotency *model.Idempotency) (*model.Transaction, error)
+	Balance(ctx context.Context, walletUUID, currency string) ([]model.Balance, error)
+	Currency(ctx context.Context, code string) (*model.Currency, error)
+
+	Transactions(ctx context.Context, walletUUID string, query model.TransactionsQuery) (*model.TransactionsPage, error)
+	IdempotentTransaction(ctx context.Context, clientId, key string) (string, *model.Transaction, error)
+	IdempotentTransactions(ctx context.Context, clientId string, keys []string) (map[string]repository.IdempotentRecord, error)
+	UpdateBatch(ctx context.Context, items []model.UpdateBalance, idempotency []*model.Idempotency) ([]model.Transaction, error)
+	UpdateBatchBestEffort(ctx context.Context, items []model.UpdateBalance, idempotency []*model.Idempotency) ([]repository.BatchOutcome, error)
+	Transfer(ctx context.Context, fromUUID, toUUID, currency string, amount model.Money) (*model.TransferResult, error)
+	CreateHold(ctx context.Context, walletUUID, currency string, amount model.Money) (*model.Hold, error)
+	CaptureHold(ctx context.Context, walletUUID, holdId string, amount model.Money) (*model.CaptureResult, error)
+	VoidHold(ctx context.Context, walletUUID, holdId string) (*model.Hold, error)
+	CreateQuote(ctx context.Context, quote *model.Quote) (*model.Quote, error)
+	Quote(ctx context.Context, quoteId string) (*model.Quote, error)
+	Convert(ctx context.Context, walletUUID string, quote *model.Quote) (*model.ConversionResult, error)
+	Reverse(ctx context.Context, transactionId string, amount model.Money) (*model.ReversalResult, error)
+	LedgerSummary(ctx context.Context) (*model.LedgerSummary, error)
+	ChangeWalletStatus(ctx context.Context, change *model.WalletStatusChange) (*model.WalletStatusChange, error)
+	WalletStatusHistory(ctx context.Context, walletUUID string) ([]model.WalletStatusChange, error)
+
+	// Клиенты API и владельцы кошельков
+	CreateClient(ctx context.Context, name, keyHash, keyPrefix string) (*model.ApiClient, error)
+	ClientByKeyHash(ctx context.Context, keyHash string) (*model.ApiClient, error)
+	ClientById(ctx context.Context, clientId string) (*model.ApiClient, error)
+	Clients(ctx context.Context) ([]model.ApiClient, error)
+	RevokeClient(ctx context.Context, clientId string) (*model.ApiClient, error)
+	WalletOwners(ctx context.Context, walletUUIDs []string) (map[string]string, error)
+	SetWalletOwner(ctx context.Context, walletUUID, clientId string) (*model.WalletOwner, error)
+	TransactionWallets(ctx context.Context, transactionId string) ([]string, error)
+}
+
+var (
+	_ WalletStore = (*repository.WalletRepo)(nil)
+	_ WalletStore = (*repository.MemoryStore)(nil)
+)
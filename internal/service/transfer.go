@@ -0,0 +1,71 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Transfer godoc
+// @Summary Transfer funds between wallets
+// @Description Atomically withdraws amount from one wallet and deposits it to another in the same currency in a single database transaction.
+// @Description The source wallet must belong to the calling client, the destination wallet may belong to anyone.
+// @Description The credit leg with the destination balances is returned only when the destination wallet belongs to the calling client too.
+// @Tags Transfers
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param request body model.Transfer true "Transfer request"
+// @Success 200 {object} model.Response{data=model.TransferResult} "Transfer completed successfully"
+// @Failure 400 {object} model.Response "Invalid request body or unsupported currency"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Source wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 409 {object} model.Response "Insufficient funds, the credit would overflow the receiving balance, or a wallet is frozen or closed"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /transfer [post]
+func (api *WalletAPI) Transfer(c *gin.Context) {
+	var req model.Transfer
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid transfer request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	// списывать можно только со своего кошелька, зачислять - на любой
+	if !api.checkWallets(c, req.FromWalletId) {
+		return
+	}
+
+	api.logger.InfoContext(c.Request.Context(), "Transfer requested", "currency", req.Currency, "amount", req.Amount, "from_wallet_id", req.FromWalletId, "to_wallet_id", req.ToWalletId)
+
+	owners, err := api.Store.WalletOwners(c.Request.Context(), []string{req.ToWalletId})
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to get wallet owners", "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	result, err := api.Store.Transfer(c.Request.Context(), req.FromWalletId, req.ToWalletId, req.Currency, req.Amount)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to transfer", "from_wallet_id", req.FromWalletId, "to_wallet_id", req.ToWalletId, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	// балансы чужого кошелька получателю перевода не показываются
+	if owners[strings.ToLower(req.ToWalletId)] != clientOf(c).Id {
+		result.Credit = nil
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    result,
+	})
+}
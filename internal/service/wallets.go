@@ -0,0 +1,106 @@
+package service
+
+import (
+	"WalletAPI/m/internal/model"
+	"bytes"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Предельный размер metadata кошелька в байтах
+const maxMetadataSize = 16 << 10
+
+// UpdateWallet godoc
+// @Summary Update wallet details
+// @Description Replaces the external owner id, display name, metadata or labels of a wallet. Omitted fields are left unchanged,
+// @Description an empty string or empty labels list clears the field, "metadata": null removes the metadata. Closed wallets cannot be updated.
+// @Tags Wallets
+// @Accept json
+// @Produce json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param WALLET_UUID path string true "Wallet UUID"
+// @Param request body model.UpdateWallet true "Fields to update"
+// @Success 200 {object} model.Response{data=model.Wallet} "Wallet updated successfully"
+// @Failure 400 {object} model.Response "Invalid wallet UUID or request body"
+// @Failure 401 {object} model.Response "Missing or invalid API key or bearer token"
+// @Failure 403 {object} model.Response "Wallet belongs to another client or token lacks scope"
+// @Failure 404 {object} model.Response "Wallet not found"
+// @Failure 409 {object} model.Response "Wallet is closed"
+// @Failure 429 {object} model.Response "Rate limit of the client or wallet exceeded, see Retry-After"
+// @Failure 500 {object} model.Response "Internal server error"
+// @Failure 503 {object} model.Response "Database timeout, retry later"
+// @Failure default {object} model.Problem "Any error as RFC 7807 problem details, when Accept prefers application/problem+json"
+// @Router /wallets/{WALLET_UUID} [patch]
+func (api *WalletAPI) UpdateWallet(c *gin.Context) {
+	walletUUID := c.Param("WALLET_UUID")
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet UUID", "wallet_id", walletUUID)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidWalletId, "Invalid wallet UUID")
+		return
+	}
+
+	if !api.checkWallets(c, walletUUID) {
+		return
+	}
+
+	var req model.UpdateWallet
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.logger.WarnContext(c.Request.Context(), "Invalid update wallet request body", "error", err)
+		errorResponse(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if !api.checkMetadata(c, req.Metadata) {
+		return
+	}
+	if req.Labels != nil {
+		labels := normalizeLabels(*req.Labels)
+		req.Labels = &labels
+	}
+
+	wallet, err := api.Store.UpdateWallet(c.Request.Context(), walletUUID, req)
+	if err != nil {
+		api.logger.ErrorContext(c.Request.Context(), "Failed to update wallet", "wallet_id", walletUUID, "error", err)
+		repoErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Success: true,
+		Data:    wallet,
+	})
+}
+
+/*
+Проверка metadata кошелька: JSON объект не больше maxMetadataSize или null
+
+Синтаксис JSON уже проверен при разборе тела, здесь - что это объект, а не строка или массив:
+metadata дополняется и читается клиентом по ключам
+
+Возвращает true, если metadata подходит, иначе ответ 400 уже отправлен
+*/
+func (api *WalletAPI) checkMetadata(c *gin.Context, metadata []byte) bool {
+	trimmed := bytes.TrimSpace(metadata)
+	if len(trimmed) == 0 || string(trimmed) == "null" {
+		return true
+	}
+
+	if trimmed[0] != '{' || len(trimmed) > maxMetadataSize {
+		api.logger.WarnContext(c.Request.Context(), "Invalid wallet metadata", "size", len(trimmed))
+		problem := newProblem(c, http.StatusBadRequest, model.ErrCodeInvalidRequest, "Invalid request body")
+		problem.Detail = "metadata must be a JSON object of at most 16 KiB"
+		writeProblem(c, problem)
+		return false
+	}
+	return true
+}
+
+// Labels без повторов и в одном порядке, чтобы их можно было сравнивать
+func normalizeLabels(labels []string) []string {
+	labels = slices.Clone(labels)
+	slices.Sort(labels)
+	return slices.Compact(labels)
+}
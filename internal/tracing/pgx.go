@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+Трейсер pgx: спан на каждый запрос к БД (BEGIN, SELECT ... FOR UPDATE, UPDATE, COMMIT)
+и на ожидание соединения из пула
+
+Ставится в poolConfig.ConnConfig.Tracer. Спаны создаются только внутри уже начатого трейса,
+миграции и фоновые задачи без трейса не порождают одиночных спанов.
+*/
+type QueryTracer struct{}
+
+// Конструктор QueryTracer
+func NewQueryTracer() *QueryTracer {
+	return &QueryTracer{}
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return ctx
+	}
+
+	attrs := append(attributesFrom(ctx),
+		attribute.String("db.system.name", "postgresql"),
+		attribute.String("db.query.text", data.SQL),
+	)
+	ctx, _ = otel.Tracer(instrumentationName).Start(ctx, queryName(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	} else {
+		span.SetAttributes(attribute.Int64("db.response.rows_affected", data.CommandTag.RowsAffected()))
+	}
+	span.End()
+}
+
+// Ожидание соединения из пула: при исчерпанном пуле время уходит сюда, а не в запросы
+func (t *QueryTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return ctx
+	}
+	ctx, _ = otel.Tracer(instrumentationName).Start(ctx, "pool.acquire", trace.WithAttributes(attributesFrom(ctx)...))
+	return ctx
+}
+
+func (t *QueryTracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.End()
+}
+
+// Имя спана по SQL: первое слово запроса, для блокирующего чтения SELECT FOR UPDATE
+func queryName(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+
+	name := strings.ToUpper(fields[0])
+	if name == "SELECT" && strings.Contains(strings.ToUpper(sql), "FOR UPDATE") {
+		name = "SELECT FOR UPDATE"
+	}
+	return name
+}
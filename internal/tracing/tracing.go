@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Имя инструментации, под ним создаются все спаны API
+const instrumentationName = "WalletAPI/m"
+
+// Куда отправлять трейсы
+const (
+	ExporterNone   = "none"   // трейсы не пишутся, traceparent всё равно передаётся дальше
+	ExporterOTLP   = "otlp"   // OTLP/HTTP коллектор, например Jaeger или Tempo
+	ExporterStdout = "stdout" // в stdout, для локальной отладки
+	ExporterFile   = "file"   // в файл, по JSON объекту на спан
+)
+
+// Настройки трейсинга, заполняются из конфига
+type Options struct {
+	Exporter    string
+	Endpoint    string  // URL коллектора для otlp, например http://localhost:4318
+	File        string  // путь к файлу для file
+	SampleRatio float64 // доля трейсов, которые пишутся, если вызывающий не решил за нас
+}
+
+/*
+Настройка глобального TracerProvider и W3C propagator (traceparent, baggage)
+
+Принимает:
+
+options Options - экспортер и доля трейсов
+
+Возвращает:
+
+shutdown func(ctx) error - дописывает накопленные спаны и закрывает экспортер, вызывается при остановке
+
+error - error
+*/
+func Init(ctx context.Context, options Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+	switch options.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(options.Endpoint))
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterFile:
+		file, err = os.OpenFile(options.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			return nil, fmt.Errorf("error opening traces file: %v", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected none, otlp, stdout or file", options.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s trace exporter: %v", options.Exporter, err)
+	}
+
+	// OTEL_SERVICE_NAME и OTEL_RESOURCE_ATTRIBUTES из окружения перекрывают имя по умолчанию
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "walletapi")),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// решение вызывающего из traceparent важнее своей доли
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			file.Close()
+		}
+		return err
+	}, nil
+}
+
+// Начинает спан API, attrs попадают и в спан, и во все SQL спаны внутри него
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = context.WithValue(ctx, attributesKey{}, append(attributesFrom(ctx), attrs...))
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Ключ context для атрибутов, которые наследуют SQL спаны
+type attributesKey struct{}
+
+func attributesFrom(ctx context.Context) []attribute.KeyValue {
+	attrs, _ := ctx.Value(attributesKey{}).([]attribute.KeyValue)
+	return attrs[:len(attrs):len(attrs)] // append не должен портить срез родителя
+}
+
+// Middleware: серверный спан на каждый запрос, продолжает трейс из traceparent вызывающего
+func Middleware(c *gin.Context) {
+	ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+	// шаблон маршрута, а не путь, чтобы спаны одного обработчика группировались
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, c.Request.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", c.Request.URL.Path),
+		),
+	)
+	defer span.End()
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
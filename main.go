@@ -2,14 +2,32 @@ package main
 
 import (
 	_ "WalletAPI/m/docs"
+	"WalletAPI/m/internal/auth"
 	"WalletAPI/m/internal/config"
+	"WalletAPI/m/internal/metrics"
+	"WalletAPI/m/internal/migrate"
+	"WalletAPI/m/internal/ratelimit"
+	"WalletAPI/m/internal/rates"
 	"WalletAPI/m/internal/repository"
 	"WalletAPI/m/internal/service"
+	"WalletAPI/m/internal/tracing"
+	"WalletAPI/m/migrations"
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // @title Wallet API
@@ -26,9 +44,24 @@ import (
 // @host localhost:8080
 // @BasePath /v1/
 // @schemes http
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key of a client, issued by POST /admin/clients
+
+// @securityDefinitions.apikey AdminKeyAuth
+// @in header
+// @name X-API-Key
+// @description Admin API key from the AdminApiKey setting
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT as "Bearer <token>": sub is the API client id, scope lists wallet:read, wallet:deposit, wallet:withdraw, wallet:create, wallet:manage
 func main() {
 	// Инициализация конфига с указанием названия лог файла
-	cfg, err := config.InitConfig("WalletAPI ")
+	cfg, err := config.InitConfig()
 	if err != nil {
 		panic(err)
 	}
@@ -39,7 +72,7 @@ func main() {
 	// Инициализация подключения к БД с настройками
 	poolConfig, err := pgxpool.ParseConfig(cfg.PostgresURL)
 	if err != nil {
-		logger.Fatalf("FATAL: failed to parse pool config: %v", err)
+		fatal(logger, "Failed to parse pool config", err)
 	}
 
 	poolConfig.MaxConns = 100
@@ -48,31 +81,253 @@ func main() {
 	poolConfig.MaxConnIdleTime = 30 * time.Minute
 	poolConfig.HealthCheckPeriod = time.Minute
 	poolConfig.ConnConfig.ConnectTimeout = 5 * time.Second
+	poolConfig.ConnConfig.Tracer = tracing.NewQueryTracer() // спаны запросов и ожидания соединения
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		logger.Fatalf("FATAL: failed to connect to Postgres database: %v", err)
+		fatal(logger, "Failed to connect to Postgres database", err)
 	}
 	defer pool.Close()
 
 	if err := pool.Ping(ctx); err != nil {
-		logger.Fatalf("ping DB not pong: %v", err)
+		fatal(logger, "Failed to ping Postgres database", err)
+	}
+
+	migrator, err := migrate.NewMigrator(pool, logger, migrations.FS)
+	if err != nil {
+		fatal(logger, "Failed to load migrations", err)
+	}
+
+	// wallets-api migrate up|down [N]|status - только миграции, без запуска API
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, migrator, os.Args[2:]); err != nil {
+			logger.Error("Migrate command failed", "error", err)
+			fmt.Fprintln(os.Stderr, err)
+			pool.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Схема приводится к версии бинарника до того, как API начнёт принимать запросы
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		fatal(logger, "Failed to apply migrations", err)
+	}
+	logger.Info("Migrations applied", "applied", applied)
+
+	shutdownTracing, err := tracing.Init(ctx, tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		File:        cfg.TracingFile,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		fatal(logger, "Failed to init tracing", err)
 	}
 
 	// Создание экземпляров WalletRepo и WalletAPI через конструкторы
-	walletRepo := repository.NewWalletRepo(pool, logger)
-	walletAPI := service.NewWalletAPI(walletRepo, logger)
+	walletRepo := repository.NewWalletRepo(pool, logger, repository.Options{
+		IdempotencyTTL: cfg.IdempotencyTTL,
+		HoldTTL:        cfg.HoldTTL,
+		SystemAccount:  cfg.SystemAccount,
+		FXAccount:      cfg.FxAccount,
+		QuoteTTL:       cfg.QuoteTTL,
+	})
+	if err := walletRepo.InitAccounts(ctx); err != nil {
+		fatal(logger, "Failed to init ledger accounts", err)
+	}
+	apiOptions := service.Options{
+		AdminApiKey:     cfg.AdminApiKey,
+		ClientRateLimit: ratelimit.Options{RPS: cfg.RateLimitClientRps, Burst: cfg.RateLimitClientBurst},
+		WalletRateLimit: ratelimit.Options{RPS: cfg.RateLimitWalletRps, Burst: cfg.RateLimitWalletBurst},
+	}
+	apiOptions.Spread, err = rates.Parse(cfg.ConvertSpread)
+	if err == nil && (apiOptions.Spread.Sign() < 0 || apiOptions.Spread.Cmp(big.NewRat(1, 1)) >= 0) {
+		err = fmt.Errorf("spread %s is out of range [0, 1)", cfg.ConvertSpread)
+	}
+	if err != nil {
+		fatal(logger, "Invalid ConvertSpread", err)
+	}
+	if cfg.RatesFile != "" {
+		if apiOptions.Rates, err = rates.LoadFile(cfg.RatesFile); err != nil {
+			fatal(logger, "Failed to load exchange rates", err)
+		}
+		logger.Info("Currency conversion enabled", "rates_file", cfg.RatesFile, "spread", cfg.ConvertSpread, "quote_ttl", cfg.QuoteTTL.String())
+	}
+	jwtOptions := auth.Options{
+		HMACSecret:  cfg.JwtHmacSecret,
+		JWKSFile:    cfg.JwtJwksFile,
+		JWKSURL:     cfg.JwtJwksUrl,
+		JWKSRefresh: cfg.JwtJwksRefresh,
+		Issuer:      cfg.JwtIssuer,
+		Audience:    cfg.JwtAudience,
+	}
+	if jwtOptions.Enabled() {
+		if apiOptions.JWT, err = auth.NewVerifier(ctx, jwtOptions); err != nil {
+			fatal(logger, "Failed to init JWT verifier", err)
+		}
+		logger.Info("JWT authentication enabled", "hs256", cfg.JwtHmacSecret != "", "jwks_file", cfg.JwtJwksFile, "jwks_url", cfg.JwtJwksUrl)
+	}
+	walletAPI := service.NewWalletAPI(walletRepo, logger, apiOptions)
+	walletAPI.AddReadinessCheck("database", service.PingCheck(pool))
+	walletAPI.AddReadinessCheck("migrations", service.MigrationsCheck(migrator))
+	walletAPI.AddReadinessCheck("pool", service.PoolSaturationCheck(pool, cfg.PoolSaturationThreshold))
 
-	router := gin.Default()
+	// SIGINT/SIGTERM отменяют shutdownCtx: фоновые задачи останавливаются, сервер уходит в остановку
+	shutdownCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var background sync.WaitGroup
+
+	// Периодическая чистка просроченных ключей идемпотентности и котировок
+	background.Go(func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-shutdownCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			deleted, err := walletRepo.PurgeIdempotencyKeys(shutdownCtx)
+			if err != nil {
+				logger.Error("Failed to purge idempotency keys", "error", err)
+				continue
+			}
+			logger.Info("Purged expired idempotency keys", "deleted", deleted)
+
+			quotes, err := walletRepo.PurgeQuotes(shutdownCtx)
+			if err != nil {
+				logger.Error("Failed to purge quotes", "error", err)
+				continue
+			}
+			logger.Info("Purged expired quotes", "deleted", quotes)
+		}
+	})
+
+	// Периодический перевод просроченных холдов в статус EXPIRED
+	background.Go(func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-shutdownCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			expired, err := walletRepo.ExpireHolds(shutdownCtx)
+			if err != nil {
+				logger.Error("Failed to expire holds", "error", err)
+				continue
+			}
+			if expired > 0 {
+				logger.Info("Expired holds", "expired", expired)
+			}
+		}
+	})
+
+	prometheus.MustRegister(metrics.NewPoolCollector(pool))
+
+	// вместо логгера gin запросы пишет service в JSON логи с request_id
+	router := gin.New()
+	router.Use(gin.Recovery())
 	router.MaxMultipartMemory = 8 << 20 // 8 MB
 
 	service.SetupRoutes(router, walletAPI)
 
-	logger.Printf("INFO: API запущено на http://localhost:8080")
-	logger.Printf("INFO: Swagger UI доступен по адресу: http://localhost:8080/swagger/index.html")
+	server := &http.Server{
+		Addr:              ":8080",
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	logger.Info("API started", "address", "http://localhost:8080")
+	logger.Info("Swagger UI available", "url", "http://localhost:8080/swagger/index.html")
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Server failed", "error", err)
+			stop()
+		}
+	}()
+
+	<-shutdownCtx.Done()
+	stop() // повторный сигнал завершает процесс сразу, не дожидаясь остановки
+	logger.Info("Shutting down")
+
+	// readiness начинает отвечать ошибкой, через ShutdownDelay listener закрывается, запросы дорабатывают
+	if err := walletAPI.Shutdown(ctx, server, cfg.ShutdownDelay, cfg.ShutdownTimeout); err != nil {
+		logger.Error("In-flight requests did not finish in time", "timeout", cfg.ShutdownTimeout.String(), "error", err)
+	} else {
+		logger.Info("All in-flight requests finished")
+	}
+
+	background.Wait()
+	pool.Close()
+	logger.Info("Database pool closed")
+
+	// спаны последних запросов ещё в батчере экспортера
+	flushCtx, cancelFlush := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("Failed to flush traces", "error", err)
+	}
+	logger.Info("Shutdown complete")
+
+	if err := cfg.CloseLog(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Логирует ошибку запуска и завершает процесс
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// Подкоманда migrate: up - применить все, down [N] - откатить N последних (по умолчанию 1), status - список
+func runMigrate(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [N]|status")
+	}
 
-	if err := router.Run(":8080"); err != nil {
-		logger.Printf("ERROR: ошибка запуска сервера: %v", err)
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations to revert: %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migrations\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-30s %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
 	}
 
+	return nil
 }
@@ -1 +0,0 @@
-CREATE DATABASE walletsdb;
\ No newline at end of file
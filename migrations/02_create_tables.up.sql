@@ -1,9 +1,7 @@
--- init.sql
-CREATE DATABASE walletsdb;
-
+-- Кошельки
 CREATE TABLE IF NOT EXISTS wallets (
     uuid UUID PRIMARY KEY,
     balance DECIMAL NOT NULL DEFAULT 0
 );
 
-CREATE INDEX idx_wallets_uuid ON wallets (uuid);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_wallets_uuid ON wallets (uuid);
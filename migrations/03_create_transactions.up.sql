@@ -0,0 +1,12 @@
+-- Журнал операций: каждая запись создаётся в той же транзакции, что и изменение баланса
+CREATE TABLE IF NOT EXISTS transactions (
+    id UUID PRIMARY KEY,
+    wallet_uuid UUID NOT NULL REFERENCES wallets (uuid),
+    operation_type VARCHAR(16) NOT NULL,
+    amount DECIMAL NOT NULL CHECK (amount > 0),
+    balance_before DECIMAL NOT NULL,
+    balance_after DECIMAL NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_created ON transactions (wallet_uuid, created_at DESC, id DESC);
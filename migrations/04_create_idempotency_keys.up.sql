@@ -0,0 +1,10 @@
+-- Ключи идемпотентности для POST /v1/wallet: повтор запроса с тем же ключом
+-- возвращает исходный результат вместо повторного списания/пополнения
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    request_hash CHAR(64) NOT NULL,
+    transaction_id UUID NOT NULL REFERENCES transactions (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys (created_at);
@@ -0,0 +1,4 @@
+-- Переводы между кошельками: обе части перевода (TRANSFER_OUT и TRANSFER_IN) связаны общим transfer_id
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS transfer_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_transfer ON transactions (transfer_id) WHERE transfer_id IS NOT NULL;
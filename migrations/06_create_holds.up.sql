@@ -0,0 +1,17 @@
+-- Холды (двухфазные платежи): резервируют сумму, уменьшая доступный баланс, но не сам баланс.
+-- Доступный баланс = balance - сумма активных непросроченных холдов
+CREATE TABLE IF NOT EXISTS holds (
+    id UUID PRIMARY KEY,
+    wallet_uuid UUID NOT NULL REFERENCES wallets (uuid),
+    amount DECIMAL NOT NULL CHECK (amount > 0),
+    captured_amount DECIMAL NOT NULL DEFAULT 0 CHECK (captured_amount >= 0 AND captured_amount <= amount),
+    status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE',
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_wallet_active ON holds (wallet_uuid) WHERE status = 'ACTIVE';
+
+-- Списание по холду попадает в журнал операций со ссылкой на холд
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS hold_id UUID REFERENCES holds (id);
@@ -0,0 +1,11 @@
+-- Журнал удаляется целиком, балансы кошельков остаются как есть
+ALTER TABLE transactions DROP COLUMN IF EXISTS entry_id;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_account_fk;
+
+DROP TABLE IF EXISTS journal_postings;
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS accounts;
+
+DROP FUNCTION IF EXISTS check_journal_entry_balanced();
+DROP FUNCTION IF EXISTS forbid_journal_changes();
@@ -0,0 +1,94 @@
+-- Журнал двойной записи. Каждая операция - запись журнала (journal_entries) с проводками
+-- (journal_postings) по счетам. Сумма проводок одной записи всегда равна нулю,
+-- поэтому сумма балансов всех счетов тоже всегда ноль - по этому сверяются деньги в системе.
+
+-- План счетов: кошельки клиентов (id счёта = UUID кошелька) и системные счета
+-- (внешний мир для DEPOSIT/WITHDRAW, комиссии и т.д.), у системных есть код
+CREATE TABLE IF NOT EXISTS accounts (
+    id UUID PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('WALLET', 'SYSTEM')),
+    code VARCHAR(64) UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((kind = 'SYSTEM') = (code IS NOT NULL))
+);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id UUID PRIMARY KEY,
+    operation_type VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- amount со знаком: плюс увеличивает баланс счёта, минус уменьшает
+CREATE TABLE IF NOT EXISTS journal_postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id UUID NOT NULL REFERENCES journal_entries (id),
+    account_id UUID NOT NULL REFERENCES accounts (id),
+    amount DECIMAL NOT NULL CHECK (amount <> 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_postings_entry ON journal_postings (entry_id);
+CREATE INDEX IF NOT EXISTS idx_journal_postings_account ON journal_postings (account_id);
+
+-- Проверка, что запись журнала сбалансирована. Триггер отложенный, поэтому
+-- проверяется при COMMIT, когда все проводки записи уже вставлены
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS trigger AS $$
+DECLARE
+    total DECIMAL;
+BEGIN
+    SELECT SUM(amount) INTO total FROM journal_postings WHERE entry_id = NEW.entry_id;
+    IF total <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced: sum of postings is %', NEW.entry_id, total
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_entry_balanced ON journal_postings;
+CREATE CONSTRAINT TRIGGER journal_entry_balanced
+    AFTER INSERT OR UPDATE ON journal_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_entry_balanced();
+
+-- Журнал только дописывается: исправления - только новыми записями
+CREATE OR REPLACE FUNCTION forbid_journal_changes() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'journal is append-only, % on % is not allowed', TG_OP, TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_postings_append_only ON journal_postings;
+CREATE TRIGGER journal_postings_append_only
+    BEFORE UPDATE OR DELETE ON journal_postings
+    FOR EACH ROW EXECUTE FUNCTION forbid_journal_changes();
+
+-- Счета для уже существующих кошельков и системный счёт по умолчанию
+INSERT INTO accounts (id, kind)
+SELECT uuid, 'WALLET' FROM wallets
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO accounts (id, kind, code)
+VALUES (gen_random_uuid(), 'SYSTEM', 'SYSTEM_CASH')
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_account_fk;
+ALTER TABLE wallets ADD CONSTRAINT wallets_account_fk FOREIGN KEY (uuid) REFERENCES accounts (id);
+
+-- Входящие остатки: балансы, накопленные до журнала, проводятся против системного счёта
+WITH opening AS MATERIALIZED (
+    SELECT w.uuid, w.balance, gen_random_uuid() AS entry_id
+    FROM wallets w
+    WHERE w.balance <> 0
+      AND NOT EXISTS (SELECT 1 FROM journal_postings p WHERE p.account_id = w.uuid)
+), entries AS (
+    INSERT INTO journal_entries (id, operation_type)
+    SELECT entry_id, 'OPENING' FROM opening
+)
+INSERT INTO journal_postings (entry_id, account_id, amount)
+SELECT entry_id, uuid, balance FROM opening
+UNION ALL
+SELECT entry_id, (SELECT id FROM accounts WHERE code = 'SYSTEM_CASH'), -balance FROM opening;
+
+-- Каждая операция в истории кошелька ссылается на свою запись журнала
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS entry_id UUID REFERENCES journal_entries (id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_reversal_of;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS reversal_of;
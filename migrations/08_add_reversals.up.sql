@@ -0,0 +1,5 @@
+-- Сторно: компенсирующая операция ссылается на исходную. Сумма сторно по одной операции
+-- не может превышать её сумму, её считаем по reversal_of
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversal_of UUID REFERENCES transactions (id);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_reversal_of ON transactions (reversal_of) WHERE reversal_of IS NOT NULL;
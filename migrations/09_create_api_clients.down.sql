@@ -0,0 +1,3 @@
+ALTER TABLE wallets DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS api_clients;
@@ -0,0 +1,14 @@
+-- Клиенты API. Ключ хранится только как SHA-256: сам ключ показывается один раз при создании.
+-- Ключи случайные и длинные, поэтому медленный хеш вроде bcrypt не нужен и поиск идёт по хешу
+CREATE TABLE IF NOT EXISTS api_clients (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    key_prefix TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+-- Клиент, создавший кошелёк; только он может им пользоваться.
+-- У кошельков, созданных до появления ключей, владельца нет
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES api_clients (id);
@@ -0,0 +1,27 @@
+-- Возвращается только баланс в USD, балансы в остальных валютах теряются
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS trigger AS $$
+DECLARE
+    total DECIMAL;
+BEGIN
+    SELECT SUM(amount) INTO total FROM journal_postings WHERE entry_id = NEW.entry_id;
+    IF total <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced: sum of postings is %', NEW.entry_id, total
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE journal_postings DROP COLUMN IF EXISTS currency;
+ALTER TABLE holds DROP COLUMN IF EXISTS currency;
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS balance DECIMAL NOT NULL DEFAULT 0;
+
+UPDATE wallets w
+SET balance = b.balance
+FROM wallet_balances b
+WHERE b.wallet_uuid = w.uuid AND b.currency = 'USD';
+
+DROP TABLE IF EXISTS wallet_balances;
+DROP TABLE IF EXISTS currencies;
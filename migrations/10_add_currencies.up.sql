@@ -0,0 +1,56 @@
+-- Мультивалютные кошельки. Суммы во всём API - целые числа в минимальных единицах валюты,
+-- scale - сколько знаков после запятой у валюты: 1000 в USD (scale 2) это 10.00 USD
+CREATE TABLE IF NOT EXISTS currencies (
+    code VARCHAR(10) PRIMARY KEY CHECK (code = upper(code)),
+    scale SMALLINT NOT NULL CHECK (scale BETWEEN 0 AND 18)
+);
+
+INSERT INTO currencies (code, scale)
+VALUES ('USD', 2), ('EUR', 2), ('GBP', 2), ('RUB', 2), ('JPY', 0), ('USDT', 6), ('BTC', 8)
+ON CONFLICT (code) DO NOTHING;
+
+-- Баланс кошелька в каждой валюте. Строка появляется при первой операции в валюте,
+-- блокируется при этом строка кошелька в wallets, так что все валюты кошелька меняются по очереди
+CREATE TABLE IF NOT EXISTS wallet_balances (
+    wallet_uuid UUID NOT NULL REFERENCES wallets (uuid),
+    currency VARCHAR(10) NOT NULL REFERENCES currencies (code),
+    balance DECIMAL NOT NULL DEFAULT 0,
+    PRIMARY KEY (wallet_uuid, currency)
+);
+
+-- Балансы, накопленные до мультивалютности, считаются долларовыми
+INSERT INTO wallet_balances (wallet_uuid, currency, balance)
+SELECT uuid, 'USD', balance FROM wallets
+WHERE balance <> 0
+ON CONFLICT (wallet_uuid, currency) DO NOTHING;
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS balance;
+
+-- Валюта у операций, холдов и проводок; всё, что было до неё, тоже в USD
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency VARCHAR(10) NOT NULL DEFAULT 'USD' REFERENCES currencies (code);
+ALTER TABLE transactions ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE holds ADD COLUMN IF NOT EXISTS currency VARCHAR(10) NOT NULL DEFAULT 'USD' REFERENCES currencies (code);
+ALTER TABLE holds ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE journal_postings ADD COLUMN IF NOT EXISTS currency VARCHAR(10) NOT NULL DEFAULT 'USD' REFERENCES currencies (code);
+ALTER TABLE journal_postings ALTER COLUMN currency DROP DEFAULT;
+
+-- Запись журнала должна быть сбалансирована в каждой валюте отдельно
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS trigger AS $$
+DECLARE
+    unbalanced RECORD;
+BEGIN
+    SELECT currency, SUM(amount) AS total INTO unbalanced
+    FROM journal_postings
+    WHERE entry_id = NEW.entry_id
+    GROUP BY currency
+    HAVING SUM(amount) <> 0
+    LIMIT 1;
+    IF FOUND THEN
+        RAISE EXCEPTION 'journal entry % is not balanced: sum of % postings is %', NEW.entry_id, unbalanced.currency, unbalanced.total
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
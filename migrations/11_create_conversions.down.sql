@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS conversions;
+DROP TABLE IF EXISTS quotes;
@@ -0,0 +1,37 @@
+-- Котировки обмена валют: курс фиксируется на короткое время, по котировке можно один раз
+-- обменять ровно from_amount на to_amount. Курсы хранятся как есть, без округления
+CREATE TABLE IF NOT EXISTS quotes (
+    id UUID PRIMARY KEY,
+    client_id UUID NOT NULL REFERENCES api_clients (id),
+    from_currency VARCHAR(10) NOT NULL REFERENCES currencies (code),
+    to_currency VARCHAR(10) NOT NULL REFERENCES currencies (code),
+    from_amount DECIMAL NOT NULL CHECK (from_amount > 0),
+    to_amount DECIMAL NOT NULL CHECK (to_amount > 0),
+    rate NUMERIC NOT NULL CHECK (rate > 0),
+    mid_rate NUMERIC NOT NULL CHECK (mid_rate > 0),
+    spread NUMERIC NOT NULL CHECK (spread >= 0 AND spread < 1),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (from_currency <> to_currency)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quotes_expires ON quotes (expires_at);
+
+-- Обмен между балансами кошелька. id совпадает с записью журнала, в которой обе части обмена
+-- проведены против системного счёта обмена валют, и с entry_id операций CONVERT_OUT и CONVERT_IN.
+-- Котировка используется не больше одного раза
+CREATE TABLE IF NOT EXISTS conversions (
+    id UUID PRIMARY KEY REFERENCES journal_entries (id),
+    wallet_uuid UUID NOT NULL REFERENCES wallets (uuid),
+    quote_id UUID UNIQUE REFERENCES quotes (id),
+    from_currency VARCHAR(10) NOT NULL REFERENCES currencies (code),
+    to_currency VARCHAR(10) NOT NULL REFERENCES currencies (code),
+    from_amount DECIMAL NOT NULL CHECK (from_amount > 0),
+    to_amount DECIMAL NOT NULL CHECK (to_amount > 0),
+    rate NUMERIC NOT NULL,
+    mid_rate NUMERIC NOT NULL,
+    spread NUMERIC NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversions_wallet ON conversions (wallet_uuid, created_at DESC);
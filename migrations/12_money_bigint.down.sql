@@ -0,0 +1,20 @@
+ALTER TABLE conversions
+    ALTER COLUMN from_amount TYPE DECIMAL,
+    ALTER COLUMN to_amount TYPE DECIMAL;
+
+ALTER TABLE quotes
+    ALTER COLUMN from_amount TYPE DECIMAL,
+    ALTER COLUMN to_amount TYPE DECIMAL;
+
+ALTER TABLE journal_postings ALTER COLUMN amount TYPE DECIMAL;
+
+ALTER TABLE holds
+    ALTER COLUMN amount TYPE DECIMAL,
+    ALTER COLUMN captured_amount TYPE DECIMAL;
+
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE DECIMAL,
+    ALTER COLUMN balance_before TYPE DECIMAL,
+    ALTER COLUMN balance_after TYPE DECIMAL;
+
+ALTER TABLE wallet_balances ALTER COLUMN balance TYPE DECIMAL;
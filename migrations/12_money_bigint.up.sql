@@ -0,0 +1,23 @@
+-- Суммы - целые числа минимальных единиц валюты, в Go это model.Money (int64), поэтому и в БД
+-- BIGINT вместо DECIMAL: сумма, которую нельзя прочитать в int64, не может попасть в таблицу.
+-- API всегда принимал только целые суммы, так что приведение ничего не округляет
+ALTER TABLE wallet_balances ALTER COLUMN balance TYPE BIGINT USING balance::bigint;
+
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE BIGINT USING amount::bigint,
+    ALTER COLUMN balance_before TYPE BIGINT USING balance_before::bigint,
+    ALTER COLUMN balance_after TYPE BIGINT USING balance_after::bigint;
+
+ALTER TABLE holds
+    ALTER COLUMN amount TYPE BIGINT USING amount::bigint,
+    ALTER COLUMN captured_amount TYPE BIGINT USING captured_amount::bigint;
+
+ALTER TABLE journal_postings ALTER COLUMN amount TYPE BIGINT USING amount::bigint;
+
+ALTER TABLE quotes
+    ALTER COLUMN from_amount TYPE BIGINT USING from_amount::bigint,
+    ALTER COLUMN to_amount TYPE BIGINT USING to_amount::bigint;
+
+ALTER TABLE conversions
+    ALTER COLUMN from_amount TYPE BIGINT USING from_amount::bigint,
+    ALTER COLUMN to_amount TYPE BIGINT USING to_amount::bigint;
@@ -0,0 +1,25 @@
+-- Статус кошелька: ACTIVE - обычная работа, FROZEN - списания запрещены (зачисления тоже,
+-- если deposits_blocked), CLOSED - кошелёк закрыт навсегда, любые операции запрещены
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE'
+        CHECK (status IN ('ACTIVE', 'FROZEN', 'CLOSED')),
+    ADD COLUMN IF NOT EXISTS deposits_blocked BOOLEAN NOT NULL DEFAULT false;
+
+-- История смен статуса: кто, когда и почему. Только дописывается, как и журнал
+CREATE TABLE IF NOT EXISTS wallet_status_changes (
+    id UUID PRIMARY KEY,
+    wallet_uuid UUID NOT NULL REFERENCES wallets (uuid),
+    previous_status VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    deposits_blocked BOOLEAN NOT NULL DEFAULT false,
+    actor TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_status_changes_wallet ON wallet_status_changes (wallet_uuid, created_at);
+
+DROP TRIGGER IF EXISTS wallet_status_changes_append_only ON wallet_status_changes;
+CREATE TRIGGER wallet_status_changes_append_only
+    BEFORE UPDATE OR DELETE ON wallet_status_changes
+    FOR EACH ROW EXECUTE FUNCTION forbid_journal_changes();
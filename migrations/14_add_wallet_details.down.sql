@@ -0,0 +1,5 @@
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS labels,
+    DROP COLUMN IF EXISTS metadata,
+    DROP COLUMN IF EXISTS display_name,
+    DROP COLUMN IF EXISTS external_owner_id;
@@ -0,0 +1,8 @@
+-- Данные, которые клиент хранит вместе с кошельком: id владельца в его системе, название,
+-- произвольный JSON объект и метки
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS external_owner_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS display_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS metadata JSONB CHECK (metadata IS NULL OR jsonb_typeof(metadata) = 'object'),
+    ADD COLUMN IF NOT EXISTS labels TEXT[] NOT NULL DEFAULT '{}';
+
@@ -0,0 +1,10 @@
+-- из одинаковых ключей разных клиентов остаётся самый новый
+DELETE FROM idempotency_keys k
+USING idempotency_keys newer
+WHERE newer.key = k.key
+  AND (newer.created_at, newer.client_id) > (k.created_at, k.client_id);
+
+ALTER TABLE idempotency_keys
+    DROP CONSTRAINT idempotency_keys_pkey,
+    ADD PRIMARY KEY (key),
+    DROP COLUMN IF EXISTS client_id;
@@ -0,0 +1,18 @@
+-- Ключи идемпотентности принадлежат клиенту API: одинаковые ключи разных клиентов не пересекаются,
+-- и клиент не может узнать чужой ключ или получить чужой результат операции.
+-- Существующие ключи достаются владельцу кошелька операции, ключи кошельков без владельца
+-- никто не сможет повторить, поэтому они удаляются
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS client_id UUID REFERENCES api_clients (id);
+
+UPDATE idempotency_keys k
+SET client_id = w.owner_id
+FROM transactions t
+JOIN wallets w ON w.uuid = t.wallet_uuid
+WHERE t.id = k.transaction_id;
+
+DELETE FROM idempotency_keys WHERE client_id IS NULL;
+
+ALTER TABLE idempotency_keys
+    ALTER COLUMN client_id SET NOT NULL,
+    DROP CONSTRAINT idempotency_keys_pkey,
+    ADD PRIMARY KEY (client_id, key);
@@ -0,0 +1,17 @@
+-- Балансы системных счетов по валютам. Через системный счёт проходят деньги всех кошельков,
+-- поэтому его баланс может выйти за BIGINT, даже когда баланс каждого кошелька в него помещается.
+-- Баланс обновляется вместе с проводками, и переполнение BIGINT отклоняет проводку целиком
+CREATE TABLE IF NOT EXISTS account_balances (
+    account_id UUID NOT NULL REFERENCES accounts (id),
+    currency VARCHAR(10) NOT NULL REFERENCES currencies (code),
+    balance BIGINT NOT NULL,
+    PRIMARY KEY (account_id, currency)
+);
+
+INSERT INTO account_balances (account_id, currency, balance)
+SELECT p.account_id, p.currency, SUM(p.amount)
+FROM journal_postings p
+JOIN accounts a ON a.id = p.account_id
+WHERE a.kind = 'SYSTEM'
+GROUP BY p.account_id, p.currency
+ON CONFLICT (account_id, currency) DO NOTHING;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS journal_entries_append_only ON journal_entries;
+DROP TRIGGER IF EXISTS transactions_append_only ON transactions;
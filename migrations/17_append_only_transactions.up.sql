@@ -0,0 +1,12 @@
+-- Журнал операций и записи журнала двойной записи только дописываются, как проводки (07)
+-- и история статусов (13): исправление - новая запись (сторно), а не изменение старой.
+-- Отдельной миграцией, а не в 03 и 07, чтобы триггеры появились и в уже развёрнутых БД
+DROP TRIGGER IF EXISTS transactions_append_only ON transactions;
+CREATE TRIGGER transactions_append_only
+    BEFORE UPDATE OR DELETE ON transactions
+    FOR EACH ROW EXECUTE FUNCTION forbid_journal_changes();
+
+DROP TRIGGER IF EXISTS journal_entries_append_only ON journal_entries;
+CREATE TRIGGER journal_entries_append_only
+    BEFORE UPDATE OR DELETE ON journal_entries
+    FOR EACH ROW EXECUTE FUNCTION forbid_journal_changes();
@@ -0,0 +1,8 @@
+// Миграции схемы БД, встраиваются в бинарник и применяются при старте (см. internal/migrate).
+// Файлы: NN_name.up.sql и NN_name.down.sql, NN - версия
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,257 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/service"
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тест: кошелёк другого клиента нельзя ни прочитать, ни изменить, без ключа 401
+func TestAPI_WalletOwnership(t *testing.T) {
+	walletID := createWallet(t)
+
+	other, err := createClient(http.DefaultClient, baseURL, adminKey(), "ownership-test")
+	require.NoError(t, err)
+	otherKey := other.ApiKey
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/wallets/"+walletID, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", otherKey)
+	resp, err := httpClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeForbidden, decodeResponse(t, resp).Code)
+
+	resp, err = http.Get(baseURL + "/v1/wallets/" + walletID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeUnauthorized, decodeResponse(t, resp).Code)
+}
+
+// Тест: владелец кошелька - создавший его клиент, отозванный ключ перестаёт работать
+func TestMemory_Ownership(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID := memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000})
+	require.Equal(t, http.StatusOK, status)
+
+	other, err := createClient(server.Client(), server.URL, memoryAdminKey, "other")
+	require.NoError(t, err)
+	otherKey := other.ApiKey
+
+	// чужой кошелёк: ни баланса, ни операций, ни списания переводом
+	status, resp := memoryRequestWithKey(t, server, otherKey, http.MethodGet, "/v1/wallets/"+walletID, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, model.ErrCodeForbidden, resp.Code)
+
+	status, _ = memoryRequestWithKey(t, server, otherKey, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, resp = memoryRequestWithKey(t, server, otherKey, http.MethodPost, "/v1/create", nil)
+	require.Equal(t, http.StatusOK, status)
+	otherWallet := resp.Data.(map[string]any)["walletId"].(string)
+
+	status, _ = memoryRequestWithKey(t, server, otherKey, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: walletID, ToWalletId: otherWallet, Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// перевод на чужой кошелёк со своего разрешён
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: walletID, ToWalletId: otherWallet, Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, int64(900), memoryBalance(t, server, walletID))
+
+	// пакет с чужим кошельком отклоняется целиком
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallet/batch", model.UpdateBalanceBatch{
+		Items: []model.UpdateBalance{
+			{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 10},
+			{WalletId: otherWallet, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 10},
+		},
+	})
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, int64(900), memoryBalance(t, server, walletID))
+
+	// без ключа и с неверным ключом - 401, клиентский ключ не даёт доступа к админским ручкам
+	status, _ = memoryRequestWithKey(t, server, "wk_unknown", http.MethodGet, "/v1/wallets/"+walletID, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	httpResp, err := http.Get(server.URL + "/v1/wallets/" + walletID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+	assert.Equal(t, model.ErrCodeUnauthorized, decodeResponse(t, httpResp).Code)
+
+	status, _ = memoryRequestWithKey(t, server, otherKey, http.MethodGet, "/v1/admin/clients", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// после отзыва ключ не работает
+	status, resp = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodGet, "/v1/admin/clients", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, resp.Data.([]any), 2)
+
+	status, resp = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodDelete, "/v1/admin/clients/"+other.Id, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, resp.Data.(map[string]any)["revokedAt"])
+
+	status, _ = memoryRequestWithKey(t, server, otherKey, http.MethodGet, "/v1/wallets/"+otherWallet, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+// Тест: кошельком без владельца (созданным до появления ключей) не пользуется никто,
+// пока администратор не назначит владельца; после назначения он доступен только владельцу
+func TestMemory_AssignWalletOwner(t *testing.T) {
+	api := newMemoryAPI(slog.New(slog.DiscardHandler), service.Options{})
+	server := serveMemory(t, api)
+
+	// кошелёк из времён до миграции 09: owner_id NULL
+	legacy, err := api.Store.CreateWallet(context.Background(), "", model.CreateWallet{})
+	require.NoError(t, err)
+	walletID := legacy.WalletId
+
+	status, resp := memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, model.ErrCodeForbidden, resp.Code)
+
+	owner, err := createClient(server.Client(), server.URL, memoryAdminKey, "legacy-owner")
+	require.NoError(t, err)
+	assign := model.AssignWalletOwner{ClientId: owner.Id}
+
+	// только по ключу администратора
+	status, _ = memoryRequestWithKey(t, server, owner.ApiKey, http.MethodPut, "/v1/admin/wallets/"+walletID+"/owner", assign)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, resp = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodPut, "/v1/admin/wallets/"+walletID+"/owner", assign)
+	require.Equal(t, http.StatusOK, status)
+	assigned := resp.Data.(map[string]any)
+	assert.Equal(t, owner.Id, assigned["ownerId"])
+	assert.Nil(t, assigned["previousOwnerId"])
+
+	status, _ = memoryRequestWithKey(t, server, owner.ApiKey, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 700})
+	require.Equal(t, http.StatusOK, status)
+	status, resp = memoryRequestWithKey(t, server, owner.ApiKey, http.MethodGet, "/v1/wallets/"+walletID+"?currency="+testCurrency, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, int64(700), singleBalance(t, resp))
+
+	// клиент сервера по-прежнему не владелец
+	status, _ = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// неизвестный кошелёк, неизвестный и отозванный клиент
+	status, resp = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodPut,
+		"/v1/admin/wallets/00000000-0000-0000-0000-000000000001/owner", assign)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, model.ErrCodeWalletNotFound, resp.Code)
+
+	status, resp = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodPut, "/v1/admin/wallets/"+walletID+"/owner",
+		model.AssignWalletOwner{ClientId: "00000000-0000-0000-0000-000000000002"})
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, model.ErrCodeClientNotFound, resp.Code)
+
+	status, _ = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodDelete, "/v1/admin/clients/"+owner.Id, nil)
+	require.Equal(t, http.StatusOK, status)
+	status, resp = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodPut, "/v1/admin/wallets/"+walletID+"/owner", assign)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, model.ErrCodeClientNotFound, resp.Code)
+}
+
+// Тест: перевод на кошелёк другого клиента не раскрывает его балансы, перевод между своими - отдаёт обе части
+func TestMemory_TransferHidesForeignBalances(t *testing.T) {
+	server := newMemoryServer(t)
+	mine, alsoMine := memoryWallet(t, server), memoryWallet(t, server)
+
+	other, err := createClient(server.Client(), server.URL, memoryAdminKey, "other")
+	require.NoError(t, err)
+	status, resp := memoryRequestWithKey(t, server, other.ApiKey, http.MethodPost, "/v1/create", nil)
+	require.Equal(t, http.StatusOK, status)
+	theirs := resp.Data.(map[string]any)["walletId"].(string)
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: mine, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000})
+	require.Equal(t, http.StatusOK, status)
+	status, _ = memoryRequestWithKey(t, server, other.ApiKey, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: theirs, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 5000})
+	require.Equal(t, http.StatusOK, status)
+
+	// переводы друг другу: каждая сторона видит только своё списание
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: mine, ToWalletId: theirs, Currency: testCurrency, Amount: 1})
+	require.Equal(t, http.StatusOK, status)
+	result := resp.Data.(map[string]any)
+	assert.NotContains(t, result, "credit")
+	assert.Equal(t, mine, result["debit"].(map[string]any)["walletId"])
+
+	status, resp = memoryRequestWithKey(t, server, other.ApiKey, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: theirs, ToWalletId: mine, Currency: testCurrency, Amount: 1})
+	require.Equal(t, http.StatusOK, status)
+	result = resp.Data.(map[string]any)
+	assert.NotContains(t, result, "credit")
+	assert.Equal(t, "5000", result["debit"].(map[string]any)["balanceAfter"])
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: mine, ToWalletId: alsoMine, Currency: testCurrency, Amount: 100})
+	require.Equal(t, http.StatusOK, status)
+	credit := resp.Data.(map[string]any)["credit"].(map[string]any)
+	assert.Equal(t, alsoMine, credit["walletId"])
+	assert.Equal(t, "100", credit["balanceAfter"])
+}
+
+// Тест: одинаковые ключи идемпотентности разных клиентов не пересекаются - ни в одиночных операциях, ни в пакетах
+func TestMemory_IdempotencyKeysPerClient(t *testing.T) {
+	server := newMemoryServer(t)
+	mine := memoryWallet(t, server)
+
+	other, err := createClient(server.Client(), server.URL, memoryAdminKey, "other")
+	require.NoError(t, err)
+	status, resp := memoryRequestWithKey(t, server, other.ApiKey, http.MethodPost, "/v1/create", nil)
+	require.Equal(t, http.StatusOK, status)
+	theirs := resp.Data.(map[string]any)["walletId"].(string)
+
+	deposit := func(key, walletID string, amount model.Money, idempotencyKey string) (int, model.Response) {
+		return memoryRequestWithKey(t, server, key, http.MethodPost, "/v1/wallet", model.UpdateBalance{
+			WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: amount, IdempotencyKey: idempotencyKey,
+		})
+	}
+
+	status, resp = deposit("", mine, 100, "payout-1")
+	require.Equal(t, http.StatusOK, status)
+	mineTx := resp.Data.(map[string]any)["transactionId"]
+
+	// тот же ключ у другого клиента - новая операция, а не 422 и не чужой результат
+	status, resp = deposit(other.ApiKey, theirs, 700, "payout-1")
+	require.Equal(t, http.StatusOK, status)
+	theirsTx := resp.Data.(map[string]any)["transactionId"]
+	assert.NotEqual(t, mineTx, theirsTx)
+
+	status, resp = memoryRequestWithKey(t, server, other.ApiKey, http.MethodPost, "/v1/wallet/batch", model.UpdateBalanceBatch{
+		Items: []model.UpdateBalance{
+			{WalletId: theirs, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 50, IdempotencyKey: "payout-1"},
+			{WalletId: theirs, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1, IdempotencyKey: "payout-2"},
+		},
+	})
+	require.Equal(t, http.StatusOK, status)
+	results := resp.Data.(map[string]any)["results"].([]any)
+	assert.Equal(t, "IDEMPOTENCY_KEY_REUSED", results[0].(map[string]any)["code"], "own key with a different body")
+	assert.Equal(t, true, results[1].(map[string]any)["success"])
+
+	// повтор каждым клиентом возвращает его собственную операцию
+	status, resp = deposit("", mine, 100, "payout-1")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, mineTx, resp.Data.(map[string]any)["transactionId"])
+	status, resp = deposit(other.ApiKey, theirs, 700, "payout-1")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, theirsTx, resp.Data.(map[string]any)["transactionId"])
+
+	status, resp = deposit("", mine, 1, "payout-2")
+	require.Equal(t, http.StatusOK, status, "key used only by the other client is free")
+
+	assert.Equal(t, int64(101), memoryBalance(t, server, mine))
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func updateBalanceBatch(atomic bool, items ...model.UpdateBalance) (*http.Response, error) {
+	body, _ := json.Marshal(model.UpdateBalanceBatch{Atomic: atomic, Items: items})
+	return httpClient.Post(baseURL+"/v1/wallet/batch", "application/json", bytes.NewBuffer(body))
+}
+
+func decodeBatch(t *testing.T, resp *http.Response) (model.Response, model.BatchResult) {
+	defer resp.Body.Close()
+
+	var result struct {
+		model.Response
+		Data model.BatchResult `json:"data"`
+	}
+	err := json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	return result.Response, result.Data
+}
+
+// Тест: атомарный пакет с ошибкой в одной операции не применяет ни одной
+func TestAPI_Batch_Atomic(t *testing.T) {
+	first := createWallet(t)
+	second := createWallet(t)
+
+	resp, err := updateBalanceBatch(true,
+		model.UpdateBalance{WalletId: first, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000},
+		model.UpdateBalance{WalletId: second, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 500},
+		model.UpdateBalance{WalletId: second, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 700},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	response, batch := decodeBatch(t, resp)
+	assert.Equal(t, model.ErrCodeInsufficientFunds, response.Code)
+	require.Len(t, batch.Results, 1)
+	assert.Equal(t, 2, batch.Results[0].Index)
+
+	balance, err := getBalance(first)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), balance)
+
+	resp, err = updateBalanceBatch(true,
+		model.UpdateBalance{WalletId: first, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000},
+		model.UpdateBalance{WalletId: second, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 500},
+		model.UpdateBalance{WalletId: second, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 300},
+	)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, batch = decodeBatch(t, resp)
+	assert.Equal(t, 3, batch.Succeeded)
+
+	balance, err = getBalance(first)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), balance)
+
+	balance, err = getBalance(second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), balance)
+}
+
+// Тест: пакет без атомарности возвращает результат по каждой операции, повтор по ключу не выполняется
+func TestAPI_Batch_BestEffort(t *testing.T) {
+	walletID := createWallet(t)
+	key := "batch-" + walletID
+
+	items := []model.UpdateBalance{
+		{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000, IdempotencyKey: key},
+		{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 5000},
+		{WalletId: "00000000-0000-0000-0000-000000000000", OperationType: "DEPOSIT", Currency: testCurrency, Amount: 100},
+	}
+
+	resp, err := updateBalanceBatch(false, items...)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, batch := decodeBatch(t, resp)
+	assert.Equal(t, 1, batch.Succeeded)
+	assert.Equal(t, 2, batch.Failed)
+	require.Len(t, batch.Results, 3)
+	assert.True(t, batch.Results[0].Success)
+	assert.Equal(t, model.ErrCodeInsufficientFunds, batch.Results[1].Code)
+	assert.Equal(t, model.ErrCodeWalletNotFound, batch.Results[2].Code)
+
+	// повтор того же пакета: пополнение с ключом не выполняется заново
+	resp, err = updateBalanceBatch(false, items...)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, replay := decodeBatch(t, resp)
+	assert.True(t, replay.Results[0].Replayed)
+	assert.Equal(t, batch.Results[0].TransactionId, replay.Results[0].TransactionId)
+
+	balance, err := getBalance(walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), balance)
+}
+
+// Тест: пакет без атомарности на хранилище в памяти - упавшая операция не мешает остальным,
+// повтор ключа внутри пакета отдаёт первую операцию, предел пакета - 10000 операций
+func TestMemory_Batch_BestEffort(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID := memoryWallet(t, server)
+
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallet/batch", model.UpdateBalanceBatch{
+		Items: []model.UpdateBalance{
+			{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000, IdempotencyKey: "payout-1"},
+			{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 5000},
+			{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 300},
+			{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000, IdempotencyKey: "payout-1"},
+		},
+	})
+	require.Equal(t, http.StatusOK, status)
+	data := resp.Data.(map[string]any)
+	assert.Equal(t, float64(3), data["succeeded"])
+	assert.Equal(t, float64(1), data["failed"])
+	results := data["results"].([]any)
+	first := results[0].(map[string]any)
+	assert.Equal(t, true, first["success"])
+	assert.Equal(t, "INSUFFICIENT_FUNDS", results[1].(map[string]any)["code"])
+	assert.Equal(t, true, results[2].(map[string]any)["success"], "item after a failed one is applied")
+	repeated := results[3].(map[string]any)
+	assert.Equal(t, true, repeated["replayed"], "same key later in the batch replays the first item")
+	assert.Equal(t, first["transactionId"], repeated["transactionId"])
+	assert.Equal(t, int64(700), memoryBalance(t, server, walletID))
+
+	items := make([]model.UpdateBalance, 10000)
+	for i := range items {
+		items[i] = model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1}
+	}
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallet/batch", model.UpdateBalanceBatch{Items: items})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(10000), resp.Data.(map[string]any)["succeeded"])
+	assert.Equal(t, int64(10700), memoryBalance(t, server, walletID))
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallet/batch", model.UpdateBalanceBatch{Items: append(items, items[0])})
+	assert.Equal(t, http.StatusBadRequest, status, "more than 10000 items")
+}
@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/rates"
+	"WalletAPI/m/internal/repository"
+	"WalletAPI/m/internal/service"
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// API в памяти с курсами USD/EUR и BTC/USD и спредом 0.5%
+func newConvertAPI(t *testing.T, store service.WalletStore) *service.WalletAPI {
+	provider, err := rates.NewStatic(map[string]string{"USD/EUR": "0.92", "BTC/USD": "65000"})
+	require.NoError(t, err)
+	return service.NewWalletAPI(store, slog.New(slog.DiscardHandler), service.Options{
+		AdminApiKey: memoryAdminKey,
+		Rates:       provider,
+		Spread:      big.NewRat(5, 1000),
+	})
+}
+
+// Тест: обмен по котировке и по текущему курсу, котировка одноразовая, журнал сходится
+func TestMemory_Convert(t *testing.T) {
+	server := serveMemory(t, newConvertAPI(t, repository.NewMemoryStore(repository.Options{QuoteTTL: time.Minute})))
+	walletID := memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: "USD", Amount: 20000})
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp := memoryRequest(t, server, http.MethodGet, "/v1/quotes?from=USD&to=EUR&amount=10000", nil)
+	require.Equal(t, http.StatusOK, status)
+	quote := resp.Data.(map[string]any)
+	assert.Equal(t, "0.9154", quote["rate"])
+	assert.Equal(t, "0.92", quote["midRate"])
+	assert.Equal(t, "0.005", quote["spread"])
+	assert.Equal(t, "9154", quote["toAmount"])
+	quoteID := quote["id"].(string)
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/convert", model.Convert{QuoteId: quoteID})
+	require.Equal(t, http.StatusOK, status)
+	result := resp.Data.(map[string]any)
+	assert.Equal(t, quoteID, result["quoteId"])
+	assert.Equal(t, "CONVERT_OUT", result["debit"].(map[string]any)["operationType"])
+	assert.Equal(t, "9154", result["credit"].(map[string]any)["amount"])
+	assert.Equal(t, result["conversionId"], result["credit"].(map[string]any)["entryId"])
+
+	// котировка одноразовая
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/convert", model.Convert{QuoteId: quoteID})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeQuoteUsed, resp.Code)
+
+	// по текущему курсу; курс обратной пары округлён вниз, поэтому сатоши на один меньше 99500
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/convert",
+		model.Convert{FromCurrency: "USD", ToCurrency: "BTC", Amount: 6500})
+	require.Equal(t, http.StatusOK, status)
+	assert.Nil(t, resp.Data.(map[string]any)["quoteId"])
+	assert.Equal(t, "99499", resp.Data.(map[string]any)["credit"].(map[string]any)["amount"])
+
+	balances := map[string]string{}
+	_, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID, nil)
+	for _, balance := range resp.Data.(map[string]any)["balances"].([]any) {
+		balances[balance.(map[string]any)["currency"].(string)] = balance.(map[string]any)["balance"].(string)
+	}
+	assert.Equal(t, map[string]string{"BTC": "99499", "EUR": "9154", "USD": "3500"}, balances)
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/convert",
+		model.Convert{FromCurrency: "USD", ToCurrency: "EUR", Amount: 3501})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeInsufficientFunds, resp.Code)
+
+	status, resp = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodGet, "/v1/ledger", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, resp.Data.(map[string]any)["balanced"])
+	fx := map[string]string{}
+	for _, account := range resp.Data.(map[string]any)["systemAccounts"].([]any) {
+		if account.(map[string]any)["code"] == "SYSTEM_FX" {
+			fx[account.(map[string]any)["currency"].(string)] = account.(map[string]any)["balance"].(string)
+		}
+	}
+	assert.Equal(t, map[string]string{"BTC": "-99499", "EUR": "-9154", "USD": "16500"}, fx)
+}
+
+// Тест: ошибки котировок и обмена
+func TestMemory_ConvertErrors(t *testing.T) {
+	server := serveMemory(t, newConvertAPI(t, repository.NewMemoryStore(repository.Options{QuoteTTL: time.Millisecond})))
+	walletID := memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: "USD", Amount: 1000})
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp := memoryRequest(t, server, http.MethodGet, "/v1/quotes?from=USD&to=EUR&amount=1000", nil)
+	require.Equal(t, http.StatusOK, status)
+	quoteID := resp.Data.(map[string]any)["id"].(string)
+	time.Sleep(10 * time.Millisecond)
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/convert", model.Convert{QuoteId: quoteID})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeQuoteExpired, resp.Code)
+
+	// котировка другого клиента не видна
+	other, err := createClient(server.Client(), server.URL, memoryAdminKey, "other")
+	require.NoError(t, err)
+	status, resp = memoryRequestWithKey(t, server, other.ApiKey, http.MethodGet, "/v1/quotes?from=USD&to=EUR&amount=1000", nil)
+	require.Equal(t, http.StatusOK, status)
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/convert",
+		model.Convert{QuoteId: resp.Data.(map[string]any)["id"].(string)})
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, model.ErrCodeQuoteNotFound, resp.Code)
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		status int
+		code   string
+	}{
+		{"no rate for pair", "/v1/quotes?from=EUR&to=GBP&amount=1000", http.StatusBadRequest, model.ErrCodeRateNotFound},
+		{"unsupported currency", "/v1/quotes?from=USD&to=DOGE&amount=1000", http.StatusBadRequest, model.ErrCodeUnsupportedCurrency},
+		{"same currency", "/v1/quotes?from=USD&to=USD&amount=1000", http.StatusBadRequest, model.ErrCodeInvalidRequest},
+		{"amount too small", "/v1/quotes?from=BTC&to=USD&amount=1", http.StatusBadRequest, model.ErrCodeInvalidOperation},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, resp := memoryRequest(t, server, http.MethodGet, tc.path, nil)
+			assert.Equal(t, tc.status, status)
+			assert.Equal(t, tc.code, resp.Code)
+		})
+	}
+
+	// котировка и сумма одновременно
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/convert",
+		model.Convert{QuoteId: quoteID, FromCurrency: "USD", ToCurrency: "EUR", Amount: 100})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, model.ErrCodeInvalidRequest, resp.Code)
+
+	// без источника курсов обмен выключен
+	disabled := newMemoryServer(t)
+	status, resp = memoryRequest(t, disabled, http.MethodGet, "/v1/quotes?from=USD&to=EUR&amount=1000", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, model.ErrCodeRateUnavailable, resp.Code)
+}
+
+// Обмены по одной котировке с нескольких кошельков клиента, запущенные одновременно:
+// сколько прошло и ошибки остальных
+func concurrentConverts(store service.WalletStore, walletIDs []string, quote *model.Quote) (succeeded int, errs []error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for _, walletID := range walletIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := store.Convert(context.Background(), walletID, quote)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				succeeded++
+			} else {
+				errs = append(errs, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return succeeded, errs
+}
+
+// Тест: повторная отправка одной котировки параллельно с разных кошельков - один обмен, остальные QUOTE_USED
+func TestMemory_ConvertDoubleSubmit(t *testing.T) {
+	server := serveMemory(t, newConvertAPI(t, repository.NewMemoryStore(repository.Options{QuoteTTL: time.Minute})))
+
+	walletIDs := make([]string, 8)
+	for i := range walletIDs {
+		walletIDs[i] = memoryWallet(t, server)
+		status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+			model.UpdateBalance{WalletId: walletIDs[i], OperationType: "DEPOSIT", Currency: "USD", Amount: 10000})
+		require.Equal(t, http.StatusOK, status)
+	}
+
+	status, resp := memoryRequest(t, server, http.MethodGet, "/v1/quotes?from=USD&to=EUR&amount=1000", nil)
+	require.Equal(t, http.StatusOK, status)
+	quoteID := resp.Data.(map[string]any)["id"].(string)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	statuses := map[int]int{}
+	for _, walletID := range walletIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/convert", model.Convert{QuoteId: quoteID})
+			if status == http.StatusConflict {
+				assert.Equal(t, model.ErrCodeQuoteUsed, resp.Code)
+			}
+			mu.Lock()
+			statuses[status]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[int]int{http.StatusOK: 1, http.StatusConflict: len(walletIDs) - 1}, statuses)
+}
+
+// Тест на Postgres: параллельные обмены по одной котировке с разных кошельков не ждут друг друга
+// на блокировке кошелька, и котировка всё равно используется ровно один раз, остальные - ErrQuoteUsed, а не 500
+func TestDB_ConvertDoubleSubmit(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	client, err := repo.CreateClient(ctx, "double-submit", "hash-double-submit", "wk_test")
+	require.NoError(t, err)
+
+	walletIDs := make([]string, 8)
+	for i := range walletIDs {
+		wallet, err := repo.CreateWallet(ctx, client.Id, model.CreateWallet{})
+		require.NoError(t, err)
+		walletIDs[i] = wallet.WalletId
+		_, err = repo.Update(ctx, wallet.WalletId, "DEPOSIT", "USD", 10000, nil)
+		require.NoError(t, err)
+	}
+
+	quote, err := repo.CreateQuote(ctx, &model.Quote{
+		ClientId: client.Id, FromCurrency: "USD", ToCurrency: "EUR", FromAmount: 1000, ToAmount: 915,
+		Rate: "0.9154", MidRate: "0.92", Spread: "0.005",
+	})
+	require.NoError(t, err)
+
+	succeeded, errs := concurrentConverts(repo, walletIDs, quote)
+	assert.Equal(t, 1, succeeded)
+	for _, err := range errs {
+		assert.True(t, errors.Is(err, repository.ErrQuoteUsed), "unexpected error: %v", err)
+	}
+
+	summary, err := repo.LedgerSummary(ctx)
+	require.NoError(t, err)
+	assert.True(t, summary.Balanced)
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getWalletBalances(t *testing.T, walletID string) model.WalletBalances {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/v1/wallets/%s", baseURL, walletID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Success bool                 `json:"success"`
+		Data    model.WalletBalances `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	return result.Data
+}
+
+// Тест: балансы в разных валютах независимы, без параметра возвращаются все со своим масштабом
+func TestAPI_MultiCurrency(t *testing.T) {
+	walletID := createWallet(t)
+
+	for _, op := range []model.UpdateBalance{
+		{WalletId: walletID, OperationType: "DEPOSIT", Currency: "USD", Amount: 1000},
+		{WalletId: walletID, OperationType: "DEPOSIT", Currency: "BTC", Amount: 50000000},
+	} {
+		body, _ := json.Marshal(op)
+		resp, err := httpClient.Post(baseURL+"/v1/wallet", "application/json", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	balances := getWalletBalances(t, walletID)
+	require.Len(t, balances.Balances, 2)
+	assert.Equal(t, model.Balance{Currency: "BTC", Scale: 8, Balance: 50000000, Available: 50000000}, balances.Balances[0])
+	assert.Equal(t, model.Balance{Currency: "USD", Scale: 2, Balance: 1000, Available: 1000}, balances.Balances[1])
+
+	// долларов на кошельке меньше, чем биткоинов в минимальных единицах, но списание долларовое
+	body, _ := json.Marshal(model.UpdateBalance{WalletId: walletID, OperationType: "WITHDRAW", Currency: "USD", Amount: 2000})
+	resp, err := httpClient.Post(baseURL+"/v1/wallet", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+// Тест: операция без валюты или в неизвестной валюте отклоняется, баланс в валюте без операций нулевой
+func TestMemory_MultiCurrency(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID := memoryWallet(t, server)
+	other := memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: "EUR", Amount: 700})
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Amount: 700})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, model.ErrCodeInvalidRequest, resp.Code)
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: "DOGE", Amount: 700})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, model.ErrCodeUnsupportedCurrency, resp.Code)
+
+	// перевод в EUR не трогает USD
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: walletID, ToWalletId: other, Currency: "EUR", Amount: 200})
+	require.Equal(t, http.StatusOK, status)
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: walletID, ToWalletId: other, Currency: "USD", Amount: 1})
+	assert.Equal(t, http.StatusConflict, status)
+
+	status, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID+"?currency=EUR", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, int64(500), singleBalance(t, resp))
+
+	status, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID+"?currency=USD", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, int64(0), singleBalance(t, resp))
+
+	status, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID+"?currency=DOGE", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, model.ErrCodeUnsupportedCurrency, resp.Code)
+
+	status, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+other, nil)
+	require.Equal(t, http.StatusOK, status)
+	balances := resp.Data.(map[string]any)["balances"].([]any)
+	require.Len(t, balances, 1)
+	assert.Equal(t, "EUR", balances[0].(map[string]any)["currency"])
+	assert.Equal(t, float64(2), balances[0].(map[string]any)["scale"])
+
+	status, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+other+"/transactions?currency=USD", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, resp.Data.(map[string]any)["transactions"])
+
+	status, resp = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodGet, "/v1/ledger", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, resp.Data.(map[string]any)["balanced"])
+}
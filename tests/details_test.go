@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тест: данные кошелька из тела /v1/create возвращаются в GET /v1/wallets/{id} вместе с балансами,
+// PATCH меняет только переданные поля
+func TestMemory_WalletDetails(t *testing.T) {
+	server := newMemoryServer(t)
+
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/create", map[string]any{
+		"externalOwnerId": "user-42",
+		"displayName":     "Main wallet",
+		"metadata":        map[string]any{"tier": "gold", "limits": map[string]any{"daily": 1000}},
+		"labels":          []string{"vip", "eu", "vip"},
+	})
+	require.Equal(t, http.StatusOK, status)
+	created := resp.Data.(map[string]any)
+	walletID := created["walletId"].(string)
+	assert.Equal(t, "ACTIVE", created["status"])
+	assert.Equal(t, []any{"eu", "vip"}, created["labels"])
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 100})
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID, nil)
+	require.Equal(t, http.StatusOK, status)
+	details := resp.Data.(map[string]any)
+	assert.Equal(t, walletID, details["walletId"])
+	assert.Equal(t, "user-42", details["externalOwnerId"])
+	assert.Equal(t, "Main wallet", details["displayName"])
+	assert.Equal(t, map[string]any{"tier": "gold", "limits": map[string]any{"daily": float64(1000)}}, details["metadata"])
+	assert.Len(t, details["balances"], 1)
+
+	status, resp = memoryRequest(t, server, http.MethodPatch, "/v1/wallets/"+walletID,
+		map[string]any{"displayName": "Savings", "labels": []string{}})
+	require.Equal(t, http.StatusOK, status)
+	updated := resp.Data.(map[string]any)
+	assert.Equal(t, "Savings", updated["displayName"])
+	assert.Equal(t, "user-42", updated["externalOwnerId"], "omitted fields are unchanged")
+	assert.Equal(t, "gold", updated["metadata"].(map[string]any)["tier"])
+	assert.Equal(t, []any{}, updated["labels"])
+
+	status, resp = memoryRequest(t, server, http.MethodPatch, "/v1/wallets/"+walletID, map[string]any{"metadata": nil})
+	require.Equal(t, http.StatusOK, status)
+	assert.Nil(t, resp.Data.(map[string]any)["metadata"])
+
+	// кошелёк без тела создаётся как раньше
+	plain := memoryWallet(t, server)
+	status, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+plain, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "", resp.Data.(map[string]any)["displayName"])
+	assert.Equal(t, []any{}, resp.Data.(map[string]any)["labels"])
+}
+
+// Тест: metadata - только JSON объект, labels ограничены, закрытый кошелёк не меняется
+func TestMemory_WalletDetailsValidation(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID := memoryWallet(t, server)
+
+	invalid := []map[string]any{
+		{"metadata": "not an object"},
+		{"metadata": []int{1, 2}},
+		{"labels": []string{""}},
+		{"displayName": string(make([]byte, 201))},
+	}
+	for _, body := range invalid {
+		status, resp := memoryRequest(t, server, http.MethodPost, "/v1/create", body)
+		assert.Equal(t, http.StatusBadRequest, status, "create %v", body)
+		assert.Equal(t, model.ErrCodeInvalidRequest, resp.Code)
+
+		status, _ = memoryRequest(t, server, http.MethodPatch, "/v1/wallets/"+walletID, body)
+		assert.Equal(t, http.StatusBadRequest, status, "patch %v", body)
+	}
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/close",
+		model.WalletStatusReason{Reason: "Customer request"})
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp := memoryRequest(t, server, http.MethodPatch, "/v1/wallets/"+walletID, map[string]any{"displayName": "late"})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeWalletClosed, resp.Code)
+
+	status, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "CLOSED", resp.Data.(map[string]any)["status"])
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/service"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тест: запущенный сервер жив и готов, в /readyz есть результат по каждой зависимости
+func TestAPI_Readyz(t *testing.T) {
+	resp, err := httpClient.Get(baseURL + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = httpClient.Get(baseURL + "/readyz")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	data, ok := decodeResponse(t, resp).Data.(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "ok", data["status"])
+
+	checks := data["checks"].(map[string]any)
+	for _, name := range []string{"database", "migrations", "pool"} {
+		check, ok := checks[name].(map[string]any)
+		require.True(t, ok, "no %s check in /readyz", name)
+		assert.Equal(t, "ok", check["status"], name)
+	}
+}
+
+// Тест: /readyz отвечает 503 при упавшей проверке и при остановке, /healthz при этом 200
+func TestMemory_Readyz(t *testing.T) {
+	api := newMemoryAPI(slog.New(slog.DiscardHandler), service.Options{})
+
+	var healthy atomic.Bool
+	healthy.Store(true)
+	api.AddReadinessCheck("dependency", func(ctx context.Context) (map[string]any, error) {
+		if !healthy.Load() {
+			return nil, context.DeadlineExceeded
+		}
+		return nil, nil
+	})
+
+	server := serveMemory(t, api)
+
+	status, _ := memoryRequest(t, server, http.MethodGet, "/readyz", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	healthy.Store(false)
+	status, resp := memoryRequest(t, server, http.MethodGet, "/readyz", nil)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, model.ErrCodeNotReady, resp.Code)
+	check := resp.Data.(map[string]any)["checks"].(map[string]any)["dependency"].(map[string]any)
+	assert.Equal(t, "fail", check["status"])
+	assert.NotEmpty(t, check["error"])
+
+	healthy.Store(true)
+	api.StartDraining()
+	status, resp = memoryRequest(t, server, http.MethodGet, "/readyz", nil)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, resp.Data.(map[string]any)["checks"], "shutdown")
+
+	status, _ = memoryRequest(t, server, http.MethodGet, "/healthz", nil)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+// Сервер с маршрутами API и медленным /slow на реальном listener, как в main
+func serveShutdown(t *testing.T, api *service.WalletAPI, slow time.Duration) (*http.Server, string, chan struct{}) {
+	router := gin.New()
+	service.SetupRoutes(router, api)
+	started := make(chan struct{}, 1)
+	router.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		time.Sleep(slow)
+		c.String(http.StatusOK, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: router}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return server, "http://" + listener.Addr().String(), started
+}
+
+// Тест: при остановке readiness сразу отвечает ошибкой, а запрос, который уже выполняется,
+// дорабатывает до закрытия сервера
+func TestMemory_GracefulShutdown(t *testing.T) {
+	api := newMemoryAPI(slog.New(slog.DiscardHandler), service.Options{})
+	server, url, started := serveShutdown(t, api, time.Second)
+
+	slowDone := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err == nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || string(body) != "done" {
+				err = fmt.Errorf("slow request: %d %s", resp.StatusCode, body)
+			}
+		}
+		slowDone <- err
+	}()
+	<-started
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- api.Shutdown(context.Background(), server, 300*time.Millisecond, 5*time.Second)
+	}()
+
+	require.Eventually(t, func() bool { return !api.Ready() }, time.Second, 10*time.Millisecond)
+	// пока идёт задержка, сервер ещё принимает запросы, но уже не готов и закрывает keep-alive
+	resp, err := http.Get(url + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp, err = http.Get(url + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = http.Get(url + "/v1/wallets/" + uuid.New().String())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.True(t, resp.Close, "Connection: close while draining")
+
+	select {
+	case err := <-shutdownDone:
+		t.Fatalf("shutdown finished before the in-flight request: %v", err)
+	case err := <-slowDone:
+		assert.NoError(t, err, "in-flight request completes")
+	}
+	assert.NoError(t, <-shutdownDone)
+
+	_, err = http.Get(url + "/healthz")
+	assert.Error(t, err, "server no longer accepts connections")
+}
+
+// Тест: запросы, которые не уложились в таймаут остановки, не держат сервер дольше таймаута
+func TestMemory_GracefulShutdownTimeout(t *testing.T) {
+	api := newMemoryAPI(slog.New(slog.DiscardHandler), service.Options{})
+	server, url, started := serveShutdown(t, api, 2*time.Second)
+
+	go func() {
+		if resp, err := http.Get(url + "/slow"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	begin := time.Now()
+	err := api.Shutdown(context.Background(), server, 0, 200*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(begin), time.Second)
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createHold(t *testing.T, walletID string, amount model.Money) model.Hold {
+	body, _ := json.Marshal(model.CreateHold{Currency: testCurrency, Amount: amount})
+	resp, err := httpClient.Post(fmt.Sprintf("%s/v1/wallets/%s/holds", baseURL, walletID), "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var result struct {
+		Success bool       `json:"success"`
+		Data    model.Hold `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	return result.Data
+}
+
+func getFullBalance(t *testing.T, walletID string) model.Balance {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/v1/wallets/%s?currency=%s", baseURL, walletID, testCurrency))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Success bool                 `json:"success"`
+		Data    model.WalletBalances `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	require.Len(t, result.Data.Balances, 1)
+	return result.Data.Balances[0]
+}
+
+// Тест: холд уменьшает доступный баланс, частичное подтверждение списывает только часть
+func TestAPI_Hold_Capture(t *testing.T) {
+	walletID := createWallet(t)
+
+	resp, err := updateBalance(walletID, "DEPOSIT", 1000)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	hold := createHold(t, walletID, 600)
+	assert.Equal(t, "ACTIVE", hold.Status)
+
+	balance := getFullBalance(t, walletID)
+	assert.Equal(t, model.Balance{Balance: 1000, Available: 400, Held: 600}, balance)
+
+	// зарезервированное снять нельзя
+	resp, err = updateBalance(walletID, "WITHDRAW", 500)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	body, _ := json.Marshal(model.CaptureHold{Amount: 250})
+	resp, err = httpClient.Post(fmt.Sprintf("%s/v1/wallets/%s/holds/%s/capture", baseURL, walletID, hold.Id),
+		"application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	balance = getFullBalance(t, walletID)
+	assert.Equal(t, model.Balance{Balance: 750, Available: 750, Held: 0}, balance)
+
+	// повторно подтвердить нельзя
+	resp, err = httpClient.Post(fmt.Sprintf("%s/v1/wallets/%s/holds/%s/capture", baseURL, walletID, hold.Id),
+		"application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestAPI_Hold_Void(t *testing.T) {
+	walletID := createWallet(t)
+
+	resp, err := updateBalance(walletID, "DEPOSIT", 1000)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	hold := createHold(t, walletID, 1000)
+
+	resp, err = httpClient.Post(fmt.Sprintf("%s/v1/wallets/%s/holds/%s/void", baseURL, walletID, hold.Id),
+		"application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	balance := getFullBalance(t, walletID)
+	assert.Equal(t, model.Balance{Balance: 1000, Available: 1000, Held: 0}, balance)
+}
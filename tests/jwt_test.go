@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/auth"
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/service"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const memoryJWTSecret = "memory-jwt-secret"
+
+// Подписанный токен клиента с правами scopes, живёт минуту
+func signToken(t *testing.T, method jwt.SigningMethod, key any, kid, subject string, scopes ...string) string {
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Scope: strings.Join(scopes, " "),
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// Запрос с Authorization: Bearer
+func bearerRequest(t *testing.T, server *httptest.Server, token, method, path string, body any) (*http.Response, model.Response) {
+	var raw []byte
+	if body != nil {
+		var err error
+		raw, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+
+	req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(raw))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	return resp, decodeResponse(t, resp)
+}
+
+// Тест: токен сервиса отчётов с wallet:read читает баланс, но не может ни снять, ни пополнить, ни перевести
+func TestMemory_JWTScopes(t *testing.T) {
+	verifier, err := auth.NewVerifier(context.Background(), auth.Options{HMACSecret: memoryJWTSecret})
+	require.NoError(t, err)
+	server := serveMemory(t, newMemoryAPI(slog.New(slog.DiscardHandler), service.Options{JWT: verifier}))
+
+	client, err := createClient(server.Client(), server.URL, memoryAdminKey, "reporting")
+	require.NoError(t, err)
+	secret := []byte(memoryJWTSecret)
+	full := signToken(t, jwt.SigningMethodHS256, secret, "", client.Id, auth.AllScopes...)
+	reporting := signToken(t, jwt.SigningMethodHS256, secret, "", client.Id, auth.ScopeRead)
+
+	// кошелёк, созданный по токену, принадлежит клиенту из sub
+	resp, body := bearerRequest(t, server, full, http.MethodPost, "/v1/create", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	walletID := body.Data.(map[string]any)["walletId"].(string)
+
+	resp, _ = bearerRequest(t, server, full, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 500})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, body = bearerRequest(t, server, reporting, http.MethodGet, "/v1/wallets/"+walletID+"?currency="+testCurrency, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(500), singleBalance(t, body))
+
+	resp, body = bearerRequest(t, server, reporting, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeInsufficientScope, body.Code)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), `error="insufficient_scope"`)
+
+	for _, forbidden := range []struct {
+		path string
+		body any
+	}{
+		{"/v1/wallet", model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 100}},
+		{"/v1/create", nil},
+		{"/v1/transfer", model.Transfer{FromWalletId: walletID, ToWalletId: memoryWallet(t, server), Amount: 100}},
+		{"/v1/wallets/" + walletID + "/holds", model.CreateHold{Currency: testCurrency, Amount: 100}},
+	} {
+		resp, body = bearerRequest(t, server, reporting, http.MethodPost, forbidden.path, forbidden.body)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, forbidden.path)
+		assert.Equal(t, model.ErrCodeInsufficientScope, body.Code, forbidden.path)
+	}
+
+	// у токена только с wallet:deposit пакет со снятием отклоняется целиком
+	depositOnly := signToken(t, jwt.SigningMethodHS256, secret, "", client.Id, auth.ScopeDeposit)
+	resp, _ = bearerRequest(t, server, depositOnly, http.MethodPost, "/v1/wallet/batch", model.UpdateBalanceBatch{
+		Items: []model.UpdateBalance{
+			{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 10},
+			{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 10},
+		},
+	})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, body = bearerRequest(t, server, reporting, http.MethodGet, "/v1/wallets/"+walletID+"?currency="+testCurrency, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(500), singleBalance(t, body))
+
+	// неверная подпись, чужой sub и просроченный токен - 401
+	for name, token := range map[string]string{
+		"wrong secret":   signToken(t, jwt.SigningMethodHS256, []byte("other-secret"), "", client.Id, auth.ScopeRead),
+		"unknown client": signToken(t, jwt.SigningMethodHS256, secret, "", "00000000-0000-0000-0000-000000000000", auth.ScopeRead),
+		"expired": func() string {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   client.Id,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			}, Scope: auth.ScopeRead}).SignedString(secret)
+			require.NoError(t, err)
+			return token
+		}(),
+	} {
+		resp, body = bearerRequest(t, server, token, http.MethodGet, "/v1/wallets/"+walletID, nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, name)
+		assert.Equal(t, model.ErrCodeUnauthorized, body.Code, name)
+		assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Bearer", name)
+	}
+}
+
+// Тест: RS256 токены проверяются ключом из JWKS по kid, HS256 без секрета не принимается
+func TestMemory_JWT_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "key-1",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.NoError(t, err)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0600))
+
+	verifier, err := auth.NewVerifier(context.Background(), auth.Options{JWKSFile: jwksFile})
+	require.NoError(t, err)
+	server := serveMemory(t, newMemoryAPI(slog.New(slog.DiscardHandler), service.Options{JWT: verifier}))
+
+	client, err := createClient(server.Client(), server.URL, memoryAdminKey, "rs256")
+	require.NoError(t, err)
+
+	resp, _ := bearerRequest(t, server, signToken(t, jwt.SigningMethodRS256, key, "key-1", client.Id, auth.ScopeCreate), http.MethodPost, "/v1/create", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = bearerRequest(t, server, signToken(t, jwt.SigningMethodRS256, key, "key-2", client.Id, auth.ScopeCreate), http.MethodPost, "/v1/create", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = bearerRequest(t, server, signToken(t, jwt.SigningMethodHS256, []byte(memoryJWTSecret), "", client.Id, auth.ScopeCreate), http.MethodPost, "/v1/create", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
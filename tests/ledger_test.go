@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getLedger(t *testing.T) model.LedgerSummary {
+	// сверка журнала доступна только администратору
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/ledger", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", adminKey())
+	resp, err := httpClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Success bool                `json:"success"`
+		Data    model.LedgerSummary `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	return result.Data
+}
+
+// Тест: после пополнения и снятия журнал сбалансирован: сумма счетов ноль, балансы кошельков совпадают с проводками
+func TestAPI_Ledger_Balanced(t *testing.T) {
+	walletID := createWallet(t)
+
+	resp, err := updateBalance(walletID, "DEPOSIT", 1000)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = updateBalance(walletID, "WITHDRAW", 300)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ledger := getLedger(t)
+	assert.True(t, ledger.Balanced)
+	require.NotEmpty(t, ledger.Currencies)
+	for _, currency := range ledger.Currencies {
+		assert.True(t, currency.Total.IsZero(), currency.Currency)
+	}
+	assert.Equal(t, int64(0), ledger.MismatchedWallets)
+	assert.NotEmpty(t, ledger.SystemAccounts)
+}
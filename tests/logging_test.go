@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/logging"
+	"WalletAPI/m/internal/service"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Буфер для логов, в который пишет горутина сервера
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+// Строки лога, разобранные как JSON
+func (b *syncBuffer) lines(t *testing.T) []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line), "log line is not JSON: %s", scanner.Text())
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Тест: X-Request-ID вызывающего возвращается в ответе и попадает во все строки лога запроса,
+// без заголовка id генерируется
+func TestMemory_RequestID(t *testing.T) {
+	var logs syncBuffer
+	server := serveMemory(t, newMemoryAPI(slog.New(logging.NewHandler(&logs, slog.LevelDebug)), service.Options{}))
+	logs.reset() // строки создания клиента API не относятся к проверяемым запросам
+
+	const requestID = "req-42"
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/create", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-ID", requestID)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, requestID, resp.Header.Get("X-Request-ID"))
+
+	lines := logs.lines(t)
+	require.NotEmpty(t, lines)
+	for _, line := range lines {
+		assert.Equal(t, requestID, line["request_id"], "log line without request id: %v", line)
+	}
+
+	resp, err = server.Client().Post(server.URL+"/v1/create", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	generated := resp.Header.Get("X-Request-ID")
+	assert.NotEmpty(t, generated)
+	assert.NotEqual(t, requestID, generated)
+}
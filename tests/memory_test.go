@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/repository"
+	"WalletAPI/m/internal/service"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тесты обработчиков поверх repository.MemoryStore: без Postgres и запущенного сервера
+
+// Ключ администратора сервера в тестах без БД
+const memoryAdminKey = "memory-admin-key"
+
+// API на хранилище в памяти с ключом администратора memoryAdminKey
+func newMemoryAPI(logger *slog.Logger, options service.Options) *service.WalletAPI {
+	gin.SetMode(gin.TestMode)
+	store := repository.NewMemoryStore(repository.Options{
+		IdempotencyTTL: time.Hour,
+		HoldTTL:        time.Minute,
+		QuoteTTL:       time.Minute,
+	})
+	options.AdminApiKey = memoryAdminKey
+	return service.NewWalletAPI(store, logger, options)
+}
+
+// Запускает сервер с api и создаёт клиента API: запросы server.Client() идут с его ключом
+func serveMemory(t *testing.T, api *service.WalletAPI) *httptest.Server {
+	router := gin.New()
+	service.SetupRoutes(router, api)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	client, err := createClient(server.Client(), server.URL, memoryAdminKey, "memory-tests")
+	require.NoError(t, err)
+	server.Client().Transport = &apiKeyTransport{
+		key:  func() (string, error) { return client.ApiKey, nil },
+		base: server.Client().Transport,
+	}
+	return server
+}
+
+func newMemoryServer(t *testing.T) *httptest.Server {
+	return serveMemory(t, newMemoryAPI(slog.New(slog.DiscardHandler), service.Options{}))
+}
+
+func memoryRequest(t *testing.T, server *httptest.Server, method, path string, body any) (int, model.Response) {
+	return memoryRequestWithKey(t, server, "", method, path, body)
+}
+
+// Запрос с явным X-API-Key, пустой key - ключ клиента сервера
+func memoryRequestWithKey(t *testing.T, server *httptest.Server, key, method, path string, body any) (int, model.Response) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, server.URL+path, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	return resp.StatusCode, decodeResponse(t, resp)
+}
+
+func memoryWallet(t *testing.T, server *httptest.Server) string {
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/create", nil)
+	require.Equal(t, http.StatusOK, status)
+	return resp.Data.(map[string]any)["walletId"].(string)
+}
+
+// Баланс кошелька в testCurrency
+func memoryBalance(t *testing.T, server *httptest.Server, walletID string) int64 {
+	status, resp := memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID+"?currency="+testCurrency, nil)
+	require.Equal(t, http.StatusOK, status)
+	return singleBalance(t, resp)
+}
+
+// Баланс из ответа GetBalance с параметром currency
+func singleBalance(t *testing.T, resp model.Response) int64 {
+	balances := resp.Data.(map[string]any)["balances"].([]any)
+	require.Len(t, balances, 1)
+	// суммы в JSON - строки
+	balance, err := strconv.ParseInt(balances[0].(map[string]any)["balance"].(string), 10, 64)
+	require.NoError(t, err)
+	return balance
+}
+
+// Тест: пополнение, снятие и недостаточно средств без БД
+func TestMemory_DepositAndWithdraw(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID := memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000})
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 300})
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 800})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeInsufficientFunds, resp.Code)
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: "00000000-0000-0000-0000-000000000000", OperationType: "DEPOSIT", Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, model.ErrCodeWalletNotFound, resp.Code)
+
+	assert.Equal(t, int64(700), memoryBalance(t, server, walletID))
+}
+
+// Тест: параллельные снятия не уводят баланс в минус, журнал сходится
+func TestMemory_ConcurrentWithdraw(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID := memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 500})
+	require.Equal(t, http.StatusOK, status)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+				model.UpdateBalance{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 10})
+			if status == http.StatusOK {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 50, succeeded)
+	assert.Equal(t, int64(0), memoryBalance(t, server, walletID))
+
+	status, resp := memoryRequestWithKey(t, server, memoryAdminKey, http.MethodGet, "/v1/ledger", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, resp.Data.(map[string]any)["balanced"])
+}
+
+// Тест: перевод, холд и сторно работают и на хранилище в памяти
+func TestMemory_TransferHoldReverse(t *testing.T) {
+	server := newMemoryServer(t)
+	from := memoryWallet(t, server)
+	to := memoryWallet(t, server)
+
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: from, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000})
+	require.Equal(t, http.StatusOK, status)
+	depositID := resp.Data.(map[string]any)["transactionId"].(string)
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: from, ToWalletId: to, Currency: testCurrency, Amount: 400})
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = memoryRequest(t, server, http.MethodPost, fmt.Sprintf("/v1/wallets/%s/holds", from),
+		model.CreateHold{Currency: testCurrency, Amount: 500})
+	require.Equal(t, http.StatusCreated, status)
+
+	// доступно 100: 600 на балансе минус 500 в холде
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/transactions/"+depositID+"/reverse",
+		model.ReverseTransaction{Amount: 200})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeInsufficientFunds, resp.Code)
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/transactions/"+depositID+"/reverse",
+		model.ReverseTransaction{Amount: 100})
+	require.Equal(t, http.StatusOK, status)
+
+	assert.Equal(t, int64(500), memoryBalance(t, server, from))
+	assert.Equal(t, int64(400), memoryBalance(t, server, to))
+}
@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тест: /metrics отдаёт запросы по шаблону маршрута и операции по исходу
+func TestMemory_Metrics(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID := memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000})
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 5000})
+	require.Equal(t, http.StatusConflict, status)
+
+	memoryBalance(t, server, walletID)
+
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	body := string(raw)
+
+	assert.Contains(t, body, `walletapi_http_requests_total{method="GET",route="/v1/wallets/:WALLET_UUID",status="200"}`)
+	assert.Contains(t, body, `walletapi_balance_operations_total{operation="DEPOSIT",outcome="success"}`)
+	assert.Contains(t, body, `walletapi_balance_operations_total{operation="WITHDRAW",outcome="insufficient_funds"}`)
+	assert.Contains(t, body, `walletapi_amount_moved_total{currency="USD",operation="DEPOSIT"}`)
+	assert.NotContains(t, body, walletID)
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/migrate"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Три миграции: версия 10 идёт после 2, хотя по имени файла раньше
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"1_items.up.sql":        {Data: []byte(`CREATE TABLE items (id BIGINT PRIMARY KEY)`)},
+		"1_items.down.sql":      {Data: []byte(`DROP TABLE items`)},
+		"2_item_name.up.sql":    {Data: []byte(`ALTER TABLE items ADD COLUMN name TEXT`)},
+		"2_item_name.down.sql":  {Data: []byte(`ALTER TABLE items DROP COLUMN name`)},
+		"10_item_tags.up.sql":   {Data: []byte(`CREATE TABLE item_tags (item_id BIGINT REFERENCES items (id), tag TEXT)`)},
+		"10_item_tags.down.sql": {Data: []byte(`DROP TABLE item_tags`)},
+		"README.md":             {Data: []byte(`not a migration`)},
+	}
+}
+
+// Версии и признак применения из Status
+func migrationVersions(t *testing.T, migrator *migrate.Migrator) ([]int64, []bool) {
+	statuses, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+
+	var versions []int64
+	var applied []bool
+	for _, status := range statuses {
+		versions = append(versions, status.Version)
+		applied = append(applied, status.AppliedAt != nil)
+	}
+	return versions, applied
+}
+
+// Есть ли таблица в схеме теста
+func tableExists(t *testing.T, pool *pgxpool.Pool, table string) bool {
+	var exists bool
+	require.NoError(t, pool.QueryRow(context.Background(), `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists))
+	return exists
+}
+
+// Тест: файлы миграций без up или с разными именами одной версии отклоняются ещё до подключения к БД
+func TestMemory_MigratorFiles(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+
+	_, err := migrate.NewMigrator(nil, logger, testMigrations())
+	assert.NoError(t, err)
+
+	_, err = migrate.NewMigrator(nil, logger, fstest.MapFS{
+		"1_items.down.sql": {Data: []byte(`DROP TABLE items`)},
+	})
+	assert.ErrorContains(t, err, "has no up file")
+
+	_, err = migrate.NewMigrator(nil, logger, fstest.MapFS{
+		"1_items.up.sql":  {Data: []byte(`CREATE TABLE items (id BIGINT)`)},
+		"1_things.up.sql": {Data: []byte(`CREATE TABLE things (id BIGINT)`)},
+	})
+	assert.ErrorContains(t, err, "has two names")
+}
+
+// Тест: миграции применяются по возрастанию версии, повторный Up ничего не делает,
+// Down откатывает последнюю применённую, и следующий Up применяет её снова
+func TestDB_MigratorUpDown(t *testing.T) {
+	pool := newTestSchema(t)
+	ctx := context.Background()
+	migrator, err := migrate.NewMigrator(pool, slog.New(slog.DiscardHandler), testMigrations())
+	require.NoError(t, err)
+
+	versions, applied := migrationVersions(t, migrator)
+	assert.Equal(t, []int64{1, 2, 10}, versions)
+	assert.Equal(t, []bool{false, false, false}, applied)
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, pending)
+
+	count, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.True(t, tableExists(t, pool, "item_tags"))
+
+	count, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count, "second Up is a no-op")
+	pending, err = migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pending)
+
+	count, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, applied = migrationVersions(t, migrator)
+	assert.Equal(t, []bool{true, true, false}, applied, "only version 10 is reverted")
+	assert.False(t, tableExists(t, pool, "item_tags"))
+	pending, err = migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pending)
+
+	count, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	var recorded int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT count(*) FROM schema_migrations`).Scan(&recorded))
+	assert.Equal(t, 3, recorded)
+}
+
+// Тест: реплики, стартующие одновременно, применяют каждую миграцию ровно один раз
+func TestDB_MigratorConcurrentUp(t *testing.T) {
+	pool := newTestSchema(t)
+	ctx := context.Background()
+
+	counts := make([]int, 4)
+	errs := make([]error, len(counts))
+	var wg sync.WaitGroup
+	for i := range counts {
+		migrator, err := migrate.NewMigrator(pool, slog.New(slog.DiscardHandler), testMigrations())
+		require.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts[i], errs[i] = migrator.Up(ctx)
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range counts {
+		assert.NoError(t, errs[i])
+		total += counts[i]
+	}
+	assert.Equal(t, 3, total)
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тест: суммы в JSON - строки, на вход принимаются строка и целое число, дробные суммы отклоняются
+func TestMemory_MoneyJSON(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID := memoryWallet(t, server)
+
+	for _, amount := range []any{"600", 400} {
+		status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+			map[string]any{"valletId": walletID, "operationType": "DEPOSIT", "currency": testCurrency, "amount": amount})
+		require.Equal(t, http.StatusOK, status, "amount %v", amount)
+	}
+
+	_, resp := memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID+"?currency="+testCurrency, nil)
+	balance := resp.Data.(map[string]any)["balances"].([]any)[0].(map[string]any)
+	assert.Equal(t, "1000", balance["balance"])
+	assert.Equal(t, "1000", balance["available"])
+	assert.Equal(t, "0", balance["held"])
+
+	_, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID+"/transactions", nil)
+	deposit := resp.Data.(map[string]any)["transactions"].([]any)[0].(map[string]any)
+	assert.Equal(t, "400", deposit["amount"])
+	assert.Equal(t, "600", deposit["balanceBefore"])
+	assert.Equal(t, "1000", deposit["balanceAfter"])
+
+	for _, amount := range []any{"10.5", 10.5, 1e21, "1e3", "9223372036854775808", "", nil} {
+		status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+			map[string]any{"valletId": walletID, "operationType": "DEPOSIT", "currency": testCurrency, "amount": amount})
+		assert.Equal(t, http.StatusBadRequest, status, "amount %v", amount)
+		assert.Equal(t, model.ErrCodeInvalidRequest, resp.Code, "amount %v", amount)
+	}
+	assert.Equal(t, int64(1000), memoryBalance(t, server, walletID))
+}
+
+// Тест: зачисление, после которого баланс кошелька или системного счёта не помещается в int64,
+// отклоняется и ничего не меняет; итоги журнала считаются точно и не переполняются
+func TestMemory_BalanceOverflow(t *testing.T) {
+	server := newMemoryServer(t)
+	full, other := memoryWallet(t, server), memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: full, OperationType: "DEPOSIT", Currency: testCurrency, Amount: math.MaxInt64 - 100})
+	require.Equal(t, http.StatusOK, status)
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: other, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 100})
+	require.Equal(t, http.StatusOK, status)
+
+	// системный счёт уже на -MaxInt64: ещё одно пополнение любого кошелька вывело бы его за int64
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: other, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 2})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeBalanceOverflow, resp.Code)
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: other, ToWalletId: full, Currency: testCurrency, Amount: 100})
+	require.Equal(t, http.StatusOK, status)
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: full, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeBalanceOverflow, resp.Code)
+
+	assert.Equal(t, int64(math.MaxInt64), memoryBalance(t, server, full))
+	assert.Equal(t, int64(0), memoryBalance(t, server, other))
+
+	status, resp = memoryRequestWithKey(t, server, memoryAdminKey, http.MethodGet, "/v1/ledger", nil)
+	require.Equal(t, http.StatusOK, status)
+	ledger := resp.Data.(map[string]any)
+	assert.Equal(t, true, ledger["balanced"])
+	for _, account := range ledger["systemAccounts"].([]any) {
+		assert.Equal(t, "-9223372036854775807", account.(map[string]any)["balance"])
+	}
+	currency := ledger["currencies"].([]any)[0].(map[string]any)
+	assert.Equal(t, "9223372036854775807", currency["walletsTotal"])
+	assert.Equal(t, "0", currency["total"])
+}
+
+// Тест: итоги по многим счетам не переполняются, в JSON - строки, в Money переводятся только в пределах int64
+func TestMemory_MoneyTotal(t *testing.T) {
+	var total model.MoneyTotal
+	assert.True(t, total.IsZero())
+
+	total = total.Add(math.MaxInt64).Add(math.MaxInt64)
+	assert.Equal(t, "18446744073709551614", total.String())
+	_, err := total.Money()
+	assert.ErrorIs(t, err, model.ErrMoneyOverflow)
+
+	raw, err := json.Marshal(total)
+	require.NoError(t, err)
+	assert.Equal(t, `"18446744073709551614"`, string(raw))
+	var decoded model.MoneyTotal
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	assert.Equal(t, total.String(), decoded.String())
+
+	assert.Equal(t, "36893488147419103227", total.Plus(decoded.Add(-1)).String())
+	assert.Equal(t, "18446744073709551614", total.String(), "Add and Plus do not change the receiver")
+	money, err := model.MoneyTotal{}.Add(-5).Money()
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(-5), money)
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"WalletAPI/m/internal/ratelimit"
+	"WalletAPI/m/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тест: сверх лимита кошелька запросы к нему получают 429 с Retry-After, другие кошельки не затронуты
+func TestMemory_WalletRateLimit(t *testing.T) {
+	// пополнение раз в 1000 секунд: за время теста корзина не наполнится
+	server := serveMemory(t, newMemoryAPI(slog.New(slog.DiscardHandler), service.Options{
+		WalletRateLimit: ratelimit.Options{RPS: 0.001, Burst: 2},
+	}))
+	walletID := memoryWallet(t, server)
+	otherWallet := memoryWallet(t, server)
+
+	for range 2 {
+		status, _ := memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID, nil)
+		require.Equal(t, http.StatusOK, status)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/wallets/"+walletID, nil)
+	require.NoError(t, err)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, retryAfter, 1)
+	assert.Equal(t, model.ErrCodeRateLimited, decodeResponse(t, resp).Code)
+
+	// лимит по кошельку, а не по ручке: операции с кошельком тоже отклоняются
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusTooManyRequests, status)
+
+	status, _ = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+otherWallet, nil)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+// Тест: сверх лимита клиента отклоняются все его запросы, другие клиенты не затронуты
+func TestMemory_ClientRateLimit(t *testing.T) {
+	server := serveMemory(t, newMemoryAPI(slog.New(slog.DiscardHandler), service.Options{
+		ClientRateLimit: ratelimit.Options{RPS: 0.001, Burst: 2},
+	}))
+	walletID := memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/create", nil)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, model.ErrCodeRateLimited, resp.Code)
+
+	other, err := createClient(server.Client(), server.URL, memoryAdminKey, "other")
+	require.NoError(t, err)
+	status, _ = memoryRequestWithKey(t, server, other.ApiKey, http.MethodPost, "/v1/create", nil)
+	assert.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// amount 0 - сторно всего остатка, тело не отправляется
+func reverse(transactionID string, amount model.Money) (*http.Response, error) {
+	var body []byte
+	if amount > 0 {
+		body, _ = json.Marshal(model.ReverseTransaction{Amount: amount})
+	}
+	return httpClient.Post(fmt.Sprintf("%s/v1/transactions/%s/reverse", baseURL, transactionID), "application/json", bytes.NewBuffer(body))
+}
+
+func decodeReversal(t *testing.T, resp *http.Response) model.ReversalResult {
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool                 `json:"success"`
+		Data    model.ReversalResult `json:"data"`
+	}
+	err := json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	return result.Data
+}
+
+// Тест: частичное сторно пополнения, затем остаток, повторное сторно запрещено
+func TestAPI_Reverse_Deposit(t *testing.T) {
+	walletID := createWallet(t)
+
+	resp, err := updateBalance(walletID, "DEPOSIT", 1000)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	data, ok := decodeResponse(t, resp).Data.(map[string]any)
+	require.True(t, ok)
+	depositID, _ := data["transactionId"].(string)
+	require.NotEmpty(t, depositID)
+
+	resp, err = reverse(depositID, 400)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	reversal := decodeReversal(t, resp)
+	assert.Equal(t, model.Money(400), reversal.Amount)
+	assert.Equal(t, model.Money(600), reversal.Remaining)
+	require.Len(t, reversal.Transactions, 1)
+	assert.Equal(t, "REVERSAL", reversal.Transactions[0].OperationType)
+	require.NotNil(t, reversal.Transactions[0].ReversalOf)
+	assert.Equal(t, depositID, *reversal.Transactions[0].ReversalOf)
+
+	balance, err := getBalance(walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(600), balance)
+
+	// больше остатка сторнировать нельзя
+	resp, err = reverse(depositID, 700)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeInvalidOperation, decodeResponse(t, resp).Code)
+
+	resp, err = reverse(depositID, 0)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, model.Money(0), decodeReversal(t, resp).Remaining)
+
+	resp, err = reverse(depositID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeAlreadyReversed, decodeResponse(t, resp).Code)
+
+	balance, err = getBalance(walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), balance)
+}
+
+// Тест: сторно перевода возвращает средства, но не больше, чем доступно получателю
+func TestAPI_Reverse_Transfer(t *testing.T) {
+	from := createWallet(t)
+	to := createWallet(t)
+
+	resp, err := updateBalance(from, "DEPOSIT", 1000)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = transfer(from, to, 400)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result struct {
+		Data model.TransferResult `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	require.NoError(t, err)
+
+	resp, err = updateBalance(to, "WITHDRAW", 300)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// у получателя осталось 100, вернуть все 400 нельзя
+	resp, err = reverse(result.Data.Credit.Id, 0)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeInsufficientFunds, decodeResponse(t, resp).Code)
+
+	resp, err = reverse(result.Data.Credit.Id, 100)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	reversal := decodeReversal(t, resp)
+	assert.Len(t, reversal.Transactions, 2)
+	assert.Equal(t, model.Money(300), reversal.Remaining)
+
+	balance, err := getBalance(from)
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), balance)
+
+	balance, err = getBalance(to)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), balance)
+}
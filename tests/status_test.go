@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тест: замороженный кошелёк не отдаёт деньги, но принимает их, пока зачисления не закрыты;
+// после разморозки всё снова работает, а каждая смена статуса попадает в историю
+func TestMemory_WalletFreeze(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID, other := memoryWallet(t, server), memoryWallet(t, server)
+
+	for _, id := range []string{walletID, other} {
+		status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+			model.UpdateBalance{WalletId: id, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000})
+		require.Equal(t, http.StatusOK, status)
+	}
+
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/freeze", model.FreezeWallet{})
+	assert.Equal(t, http.StatusBadRequest, status, "reason is required")
+	assert.Equal(t, model.ErrCodeInvalidRequest, resp.Code)
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/freeze",
+		model.FreezeWallet{Reason: "AML review"})
+	require.Equal(t, http.StatusOK, status)
+	change := resp.Data.(map[string]any)
+	assert.Equal(t, "ACTIVE", change["previousStatus"])
+	assert.Equal(t, "FROZEN", change["status"])
+	assert.Equal(t, "AML review", change["reason"])
+	assert.NotEmpty(t, change["actor"])
+
+	frozen := []struct {
+		path string
+		body any
+	}{
+		{"/v1/wallet", model.UpdateBalance{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 100}},
+		{"/v1/transfer", model.Transfer{FromWalletId: walletID, ToWalletId: other, Currency: testCurrency, Amount: 100}},
+		{"/v1/wallets/" + walletID + "/holds", model.CreateHold{Currency: testCurrency, Amount: 100}},
+	}
+	for _, op := range frozen {
+		status, resp = memoryRequest(t, server, http.MethodPost, op.path, op.body)
+		assert.Equal(t, http.StatusConflict, status, op.path)
+		assert.Equal(t, model.ErrCodeWalletFrozen, resp.Code, op.path)
+	}
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusOK, status, "deposits are allowed while not blocked")
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: other, ToWalletId: walletID, Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusOK, status, "incoming transfers are allowed while deposits are not blocked")
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/freeze",
+		model.FreezeWallet{Reason: "again"})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeInvalidTransition, resp.Code)
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/unfreeze",
+		model.WalletStatusReason{Reason: "Review completed"})
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/freeze",
+		model.FreezeWallet{Reason: "Court order", BlockDeposits: true})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, resp.Data.(map[string]any)["depositsBlocked"])
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeWalletFrozen, resp.Code)
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/transfer",
+		model.Transfer{FromWalletId: other, ToWalletId: walletID, Currency: testCurrency, Amount: 100})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeWalletFrozen, resp.Code)
+
+	assert.Equal(t, int64(1200), memoryBalance(t, server, walletID))
+	assert.Equal(t, int64(900), memoryBalance(t, server, other))
+
+	status, resp = memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID+"/status-history", nil)
+	require.Equal(t, http.StatusOK, status)
+	history := resp.Data.([]any)
+	require.Len(t, history, 3)
+	var statuses []string
+	for _, item := range history {
+		statuses = append(statuses, item.(map[string]any)["status"].(string))
+	}
+	assert.Equal(t, []string{"FROZEN", "ACTIVE", "FROZEN"}, statuses)
+}
+
+// Тест: закрыть можно только активный кошелёк с нулевым балансом, закрытый отклоняет любые операции
+func TestMemory_WalletClose(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID, other := memoryWallet(t, server), memoryWallet(t, server)
+
+	status, _ := memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 500})
+	require.Equal(t, http.StatusOK, status)
+
+	closeWallet := model.WalletStatusReason{Reason: "Customer request"}
+	status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/close", closeWallet)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeWalletNotEmpty, resp.Code)
+
+	status, _ = memoryRequest(t, server, http.MethodPost, "/v1/wallet",
+		model.UpdateBalance{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 500})
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/close", closeWallet)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "CLOSED", resp.Data.(map[string]any)["status"])
+
+	closed := []struct {
+		path string
+		body any
+	}{
+		{"/v1/wallet", model.UpdateBalance{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 100}},
+		{"/v1/transfer", model.Transfer{FromWalletId: other, ToWalletId: walletID, Currency: testCurrency, Amount: 100}},
+		{"/v1/wallets/" + walletID + "/holds", model.CreateHold{Currency: testCurrency, Amount: 100}},
+	}
+	for _, op := range closed {
+		status, resp = memoryRequest(t, server, http.MethodPost, op.path, op.body)
+		assert.Equal(t, http.StatusConflict, status, op.path)
+		assert.Equal(t, model.ErrCodeWalletClosed, resp.Code, op.path)
+	}
+
+	// закрытие окончательное
+	for _, path := range []string{"/unfreeze", "/close"} {
+		status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+path, closeWallet)
+		assert.Equal(t, http.StatusConflict, status, path)
+		assert.Equal(t, model.ErrCodeInvalidTransition, resp.Code, path)
+	}
+	status, resp = memoryRequest(t, server, http.MethodPost, "/v1/wallets/"+walletID+"/freeze",
+		model.FreezeWallet{Reason: "too late"})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, model.ErrCodeInvalidTransition, resp.Code)
+
+	// баланс закрытого кошелька по-прежнему можно прочитать
+	assert.Equal(t, int64(0), memoryBalance(t, server, walletID))
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTransactions(t *testing.T, walletID, query string) model.TransactionsPage {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/v1/wallets/%s/transactions?%s", baseURL, walletID, query))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Success bool                   `json:"success"`
+		Data    model.TransactionsPage `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	require.True(t, result.Success)
+
+	return result.Data
+}
+
+// Тест: история операций от новых к старым с пагинацией и фильтрами
+func TestAPI_TransactionsHistory(t *testing.T) {
+	walletID := createWallet(t)
+
+	amounts := []model.Money{100, 200, 300, 400, 500}
+	for _, amount := range amounts {
+		resp, err := updateBalance(walletID, "DEPOSIT", amount)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	resp, err := updateBalance(walletID, "WITHDRAW", 250)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	page := getTransactions(t, walletID, "limit=4")
+	require.Len(t, page.Transactions, 4)
+	require.NotEmpty(t, page.NextCursor)
+	assert.Equal(t, "WITHDRAW", page.Transactions[0].OperationType)
+	assert.Equal(t, model.Money(1500), page.Transactions[0].BalanceBefore)
+	assert.Equal(t, model.Money(1250), page.Transactions[0].BalanceAfter)
+
+	next := getTransactions(t, walletID, "limit=4&cursor="+page.NextCursor)
+	require.Len(t, next.Transactions, 2)
+	assert.Empty(t, next.NextCursor)
+	assert.Equal(t, model.Money(100), next.Transactions[1].Amount)
+
+	deposits := getTransactions(t, walletID, "operationType=DEPOSIT&minAmount=200&maxAmount=400")
+	require.Len(t, deposits.Transactions, 3)
+	for _, tr := range deposits.Transactions {
+		assert.Equal(t, "DEPOSIT", tr.OperationType)
+	}
+}
+
+func TestAPI_TransactionsHistory_InvalidCursor(t *testing.T) {
+	walletID := createWallet(t)
+
+	resp, err := httpClient.Get(fmt.Sprintf("%s/v1/wallets/%s/transactions?cursor=not-a-cursor", baseURL, walletID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// Тест: transactionId из ответа /v1/wallet - id записи журнала операций с теми же данными
+func TestMemory_UpdateTransactionId(t *testing.T) {
+	server := newMemoryServer(t)
+	walletID := memoryWallet(t, server)
+
+	var ids []string
+	for _, op := range []model.UpdateBalance{
+		{WalletId: walletID, OperationType: "DEPOSIT", Currency: testCurrency, Amount: 1000},
+		{WalletId: walletID, OperationType: "WITHDRAW", Currency: testCurrency, Amount: 300},
+	} {
+		status, resp := memoryRequest(t, server, http.MethodPost, "/v1/wallet", op)
+		require.Equal(t, http.StatusOK, status)
+		id, ok := resp.Data.(map[string]any)["transactionId"].(string)
+		require.True(t, ok, "response has transactionId")
+		ids = append(ids, id)
+	}
+
+	status, resp := memoryRequest(t, server, http.MethodGet, "/v1/wallets/"+walletID+"/transactions", nil)
+	require.Equal(t, http.StatusOK, status)
+	stored := resp.Data.(map[string]any)["transactions"].([]any)
+	require.Len(t, stored, 2)
+
+	// история - от новых к старым
+	withdraw, deposit := stored[0].(map[string]any), stored[1].(map[string]any)
+	assert.Equal(t, ids[0], deposit["id"])
+	assert.Equal(t, "DEPOSIT", deposit["operationType"])
+	assert.Equal(t, "1000", deposit["balanceAfter"])
+	assert.Equal(t, ids[1], withdraw["id"])
+	assert.Equal(t, "WITHDRAW", withdraw["operationType"])
+	assert.Equal(t, "300", withdraw["amount"])
+	assert.Equal(t, "700", withdraw["balanceAfter"])
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"WalletAPI/m/internal/model"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func transfer(fromWalletID, toWalletID string, amount model.Money) (*http.Response, error) {
+	reqBody := model.Transfer{
+		FromWalletId: fromWalletID,
+		ToWalletId:   toWalletID,
+		Currency:     testCurrency,
+		Amount:       amount,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	return httpClient.Post(baseURL+"/v1/transfer", "application/json", bytes.NewBuffer(body))
+}
+
+func TestAPI_Transfer(t *testing.T) {
+	from := createWallet(t)
+	to := createWallet(t)
+
+	resp, err := updateBalance(from, "DEPOSIT", 1000)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = transfer(from, to, 400)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Success bool                 `json:"success"`
+		Data    model.TransferResult `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Data.TransferId)
+	assert.Equal(t, "TRANSFER_OUT", result.Data.Debit.OperationType)
+	assert.Equal(t, "TRANSFER_IN", result.Data.Credit.OperationType)
+
+	balance, err := getBalance(from)
+	require.NoError(t, err)
+	assert.Equal(t, int64(600), balance)
+
+	balance, err = getBalance(to)
+	require.NoError(t, err)
+	assert.Equal(t, int64(400), balance)
+
+	// перевод больше баланса не должен ничего менять
+	resp, err = transfer(from, to, 10000)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	balance, err = getBalance(from)
+	require.NoError(t, err)
+	assert.Equal(t, int64(600), balance)
+}
+
+// Тест: встречные переводы A->B и B->A не должны приводить к дедлокам
+func TestAPI_Transfer_Concurrent_Opposite(t *testing.T) {
+	a := createWallet(t)
+	b := createWallet(t)
+
+	for _, walletID := range []string{a, b} {
+		resp, err := updateBalance(walletID, "DEPOSIT", 100000)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	const numRequests = 500
+
+	var wg sync.WaitGroup
+	var serverErrors atomic.Int64
+
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+
+			from, to := a, b
+			if index%2 == 0 {
+				from, to = b, a
+			}
+
+			resp, err := transfer(from, to, 10)
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode >= 500 {
+				serverErrors.Add(1)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int64(0), serverErrors.Load(),
+		"FAILED: Got %d server errors (50x)", serverErrors.Load())
+
+	balanceA, err := getBalance(a)
+	require.NoError(t, err)
+	balanceB, err := getBalance(b)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100000), balanceA)
+	assert.Equal(t, int64(100000), balanceB)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -17,10 +18,86 @@ import (
 
 const (
 	baseURL = "http://localhost:8080"
+
+	// ключ администратора по умолчанию, как в config.env.example
+	defaultAdminKey = "change_me_to_secure_admin_key"
+
+	// валюта операций в тестах, где валюта не важна
+	testCurrency = "USD"
 )
 
+// Ключ администратора сервера, с которым запускаются тесты: ADMIN_API_KEY или значение из примера конфига
+func adminKey() string {
+	if key := os.Getenv("ADMIN_API_KEY"); key != "" {
+		return key
+	}
+	return defaultAdminKey
+}
+
+// Все запросы тестов идут с ключом клиента, созданного один раз на прогон
 var httpClient = &http.Client{
-	Timeout: 10 * time.Second,
+	Timeout:   10 * time.Second,
+	Transport: &apiKeyTransport{key: lazyClientKey},
+}
+
+// Подставляет X-API-Key в запросы, где не задан ни он, ни Bearer токен
+type apiKeyTransport struct {
+	key  func() (string, error)
+	base http.RoundTripper
+}
+
+func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Header.Get("X-API-Key") != "" || req.Header.Get("Authorization") != "" {
+		return base.RoundTrip(req)
+	}
+
+	key, err := t.key()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("X-API-Key", key)
+	return base.RoundTrip(req)
+}
+
+var lazyClientKey = sync.OnceValues(func() (string, error) {
+	client, err := createClient(http.DefaultClient, baseURL, adminKey(), "integration-tests")
+	if err != nil {
+		return "", err
+	}
+	return client.ApiKey, nil
+})
+
+// Создаёт клиента API через админскую ручку, в ответе его id и ключ
+func createClient(client *http.Client, url, adminKey, name string) (*model.CreatedApiClient, error) {
+	body, _ := json.Marshal(model.CreateApiClient{Name: name})
+	req, err := http.NewRequest(http.MethodPost, url+"/v1/admin/clients", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", adminKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("creating api client: status %d, check ADMIN_API_KEY", resp.StatusCode)
+	}
+
+	var result struct {
+		Data model.CreatedApiClient `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result.Data, nil
 }
 
 func createWallet(t *testing.T) string {
@@ -42,10 +119,11 @@ func createWallet(t *testing.T) string {
 	return walletID
 }
 
-func updateBalance(walletID, opType string, amount int64) (*http.Response, error) {
+func updateBalance(walletID, opType string, amount model.Money) (*http.Response, error) {
 	reqBody := model.UpdateBalance{
 		WalletId:      walletID,
 		OperationType: opType,
+		Currency:      testCurrency,
 		Amount:        amount,
 	}
 
@@ -53,8 +131,9 @@ func updateBalance(walletID, opType string, amount int64) (*http.Response, error
 	return httpClient.Post(baseURL+"/v1/wallet", "application/json", bytes.NewBuffer(body))
 }
 
+// Баланс кошелька в testCurrency
 func getBalance(walletID string) (int64, error) {
-	resp, err := httpClient.Get(fmt.Sprintf("%s/v1/wallets/%s", baseURL, walletID))
+	resp, err := httpClient.Get(fmt.Sprintf("%s/v1/wallets/%s?currency=%s", baseURL, walletID, testCurrency))
 	if err != nil {
 		return 0, err
 	}
@@ -64,15 +143,18 @@ func getBalance(walletID string) (int64, error) {
 		return 0, fmt.Errorf("status code: %d", resp.StatusCode)
 	}
 
-	var result model.Response
+	var result struct {
+		Data model.WalletBalances `json:"data"`
+	}
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
 		return 0, err
 	}
+	if len(result.Data.Balances) != 1 {
+		return 0, fmt.Errorf("expected one balance, got %d", len(result.Data.Balances))
+	}
 
-	data := result.Data.(map[string]interface{})
-	balance := int64(data["balance"].(float64))
-	return balance, nil
+	return int64(result.Data.Balances[0].Balance), nil
 }
 
 func TestAPI_CreateWallet(t *testing.T) {
@@ -359,3 +441,123 @@ func TestAPI_Stress_1000RPS(t *testing.T) {
 	assert.Greater(t, successRate, 99.0,
 		"Success rate %.2f%% is below 99%%", successRate)
 }
+
+func updateBalanceIdempotent(walletID, opType string, amount model.Money, key string) (*http.Response, error) {
+	reqBody := model.UpdateBalance{
+		WalletId:      walletID,
+		OperationType: opType,
+		Currency:      testCurrency,
+		Amount:        amount,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/wallet", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	return httpClient.Do(req)
+}
+
+// Тест: повтор запроса с тем же Idempotency-Key не выполняет операцию второй раз
+func TestAPI_IdempotentUpdate(t *testing.T) {
+	walletID := createWallet(t)
+	key := "test-" + walletID
+
+	var transactionIds []string
+	for i := 0; i < 3; i++ {
+		resp, err := updateBalanceIdempotent(walletID, "DEPOSIT", 700, key)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result model.Response
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		data := result.Data.(map[string]interface{})
+		transactionIds = append(transactionIds, data["transactionId"].(string))
+	}
+
+	assert.Equal(t, transactionIds[0], transactionIds[1])
+	assert.Equal(t, transactionIds[0], transactionIds[2])
+
+	balance, err := getBalance(walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), balance)
+
+	// тот же ключ, но другая сумма
+	resp, err := updateBalanceIdempotent(walletID, "DEPOSIT", 800, key)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func decodeResponse(t *testing.T, resp *http.Response) model.Response {
+	defer resp.Body.Close()
+
+	var result model.Response
+	err := json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	return result
+}
+
+// Тест: доменные ошибки возвращаются со своими статусами и кодами
+func TestAPI_ErrorCodes(t *testing.T) {
+	walletID := createWallet(t)
+
+	resp, err := updateBalance(walletID, "WITHDRAW", 100)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeInsufficientFunds, decodeResponse(t, resp).Code)
+
+	resp, err = updateBalance(walletID, "STEAL", 100)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeInvalidOperation, decodeResponse(t, resp).Code)
+
+	resp, err = updateBalance("00000000-0000-0000-0000-000000000000", "DEPOSIT", 100)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeWalletNotFound, decodeResponse(t, resp).Code)
+
+	resp, err = httpClient.Get(baseURL + "/v1/wallets/not-a-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, model.ErrCodeInvalidWalletId, decodeResponse(t, resp).Code)
+}
+
+// Тест: с Accept: application/problem+json ошибки приходят в формате RFC 7807
+func TestAPI_ProblemJSON(t *testing.T) {
+	walletID := createWallet(t)
+
+	body, _ := json.Marshal(model.UpdateBalance{
+		WalletId:      walletID,
+		OperationType: "WITHDRAW",
+		Currency:      testCurrency,
+		Amount:        100,
+	})
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/wallet", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/problem+json")
+
+	resp, err := httpClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+	var problem model.Problem
+	err = json.NewDecoder(resp.Body).Decode(&problem)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, problem.Status)
+	assert.Equal(t, model.ErrCodeInsufficientFunds, problem.Code)
+	assert.Equal(t, "/v1/wallet", problem.Instance)
+	require.NotNil(t, problem.CurrentBalance)
+	require.NotNil(t, problem.RequestedAmount)
+	assert.Equal(t, model.Money(0), *problem.CurrentBalance)
+	assert.Equal(t, model.Money(100), *problem.RequestedAmount)
+}